@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sreio/go-novel/internal/cover"
+	"github.com/sreio/go-novel/internal/fonts"
+	"github.com/sreio/go-novel/internal/format"
+)
+
+func cmdConvert() *cobra.Command {
+	var formatName, from, output, coverPath string
+	var importArgs []string
+	var meta format.Meta
+	cmd := &cobra.Command{
+		Use:   "convert <文件>",
+		Short: "把已有的电子书（TXT/EPUB）转换为其它格式，不访问网络",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src := args[0]
+			imp, err := importer(src, from)
+			if err != nil {
+				return err
+			}
+			importOpts, err := format.ParseOptions(importArgs)
+			if err != nil {
+				return err
+			}
+			if err := format.ValidateImport(imp, importOpts); err != nil {
+				return err
+			}
+			exp, err := exporter(formatName)
+			if err != nil {
+				return err
+			}
+			dst := output
+			if dst == "" {
+				base := strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+				dst = filepath.Join(outputDir, base+"."+exp.Extension())
+			}
+			if same(src, dst) {
+				return fmt.Errorf("output %s would overwrite the input, use --output", dst)
+			}
+
+			b, err := format.Load(src, imp, importOpts)
+			if err != nil {
+				return err
+			}
+			applyMeta(cmd, b, meta)
+			if b.Title == "" {
+				b.Title = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+			}
+			if err := convertCover(b, exp, coverPath); err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return err
+			}
+			file, err := format.Create(dst, exp, b, exportOpts)
+			if err != nil {
+				return err
+			}
+			volumes, chapters := 0, 0
+			for _, v := range b.Volumes {
+				if v.Title != "" {
+					volumes++
+					err = file.BeginVolume(v.Title)
+				}
+				for _, ch := range v.Chapters {
+					if err == nil {
+						chapters++
+						err = file.WriteChapter(ch)
+					}
+				}
+				if err != nil {
+					file.Abort()
+					return err
+				}
+			}
+			if err := closeBook(file); err != nil {
+				return err
+			}
+			if volumes > 0 {
+				fmt.Printf("已转换 %d 卷 %d 章：%s\n", volumes, chapters, dst)
+			} else {
+				fmt.Printf("已转换 %d 章：%s\n", chapters, dst)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&formatName, "format", "f", "epub", formatUsage())
+	cmd.Flags().StringVar(&from, "from", "", "输入格式："+strings.Join(format.ImporterNames(), "|")+"，默认按扩展名识别")
+	cmd.Flags().StringArrayVar(&importArgs, "import-option", nil, "导入选项 key=value，可重复；如 title-pattern=^第.+章，见 novel formats")
+	cmd.Flags().StringVar(&output, "output", "", "输出文件（默认 <--out>/<输入文件名>.<扩展名>）")
+	cmd.Flags().StringVar(&meta.Title, "title", "", "书籍标题（默认取书中元数据，TXT 取文件名）")
+	cmd.Flags().StringVar(&meta.Author, "author", "", "书籍作者")
+	cmd.Flags().StringVar(&meta.Description, "description", "", "简介")
+	cmd.Flags().StringVar(&meta.Language, "language", "", "语言，如 zh、zh-Hant")
+	cmd.Flags().StringVar(&coverPath, "cover", "", "封面图片文件（默认取书中封面，没有时生成）")
+	return cmd
+}
+
+// importer 按 --from 或文件扩展名选择导入器。
+func importer(path, name string) (format.Importer, error) {
+	if name != "" {
+		i, ok := format.LookupImporter(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown input format: %s (%s)", name, strings.Join(format.ImporterNames(), "|"))
+		}
+		return i, nil
+	}
+	i, ok := format.ImporterFor(path)
+	if !ok {
+		return nil, fmt.Errorf("cannot detect input format of %s, use --from (%s)", filepath.Base(path), strings.Join(format.ImporterNames(), "|"))
+	}
+	return i, nil
+}
+
+// applyMeta 用命令行显式给出的元数据覆盖书中读到的值。
+func applyMeta(cmd *cobra.Command, b *format.Book, m format.Meta) {
+	set := func(flag string, dst *string, v string) {
+		if cmd.Flags().Changed(flag) {
+			*dst = v
+		}
+	}
+	set("title", &b.Title, m.Title)
+	set("author", &b.Author, m.Author)
+	set("description", &b.Description, m.Description)
+	set("language", &b.Language, m.Language)
+}
+
+// convertCover 为使用封面的格式准备封面：--cover > 书中封面 > 生成封面；书中封面无法使用时改为生成。
+// 需要生成封面却找不到中文字体时返回错误。
+func convertCover(b *format.Book, e format.Exporter, path string) error {
+	if !format.UsesCover(e) {
+		return nil
+	}
+	var data []byte
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if data, err = cover.Normalize(raw); err != nil {
+			return fmt.Errorf("cover %s: %w", path, err)
+		}
+	} else if b.Cover != nil {
+		var err error
+		if data, err = cover.Normalize(b.Cover.Data); err != nil {
+			fmt.Fprintf(os.Stderr, "书中封面无法使用（%v），改为生成封面\n", err)
+		}
+	}
+	if data == nil {
+		// 没有中文字体时生成的封面上书名无法显示，直接报错，由用户指定字体或封面
+		font := fonts.Find(exportOpts["font"])
+		if font == "" {
+			return fmt.Errorf("generate cover: %w, or use --cover", fonts.ErrNotFound)
+		}
+		var err error
+		if data, err = cover.Generate(b.Title, b.Author, font); err != nil {
+			fmt.Fprintf(os.Stderr, "生成封面失败: %v\n", err)
+			b.Cover = nil
+			return nil
+		}
+	}
+	b.Cover = &format.Image{Name: "cover", MIME: http.DetectContentType(data), Data: data}
+	return nil
+}
+
+// same 判断两个路径是否指向同一个文件。
+func same(a, b string) bool {
+	if fa, err := os.Stat(a); err == nil {
+		if fb, err := os.Stat(b); err == nil {
+			return os.SameFile(fa, fb)
+		}
+	}
+	return filepath.Clean(a) == filepath.Clean(b)
+}
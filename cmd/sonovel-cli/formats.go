@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/sreio/go-novel/internal/format"
+)
+
+func cmdFormats() *cobra.Command {
+	return &cobra.Command{
+		Use:   "formats",
+		Short: "列出支持的导出格式及其选项（--option key=value）与 convert 支持的输入格式",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range format.Names() {
+				e, _ := format.Lookup(name)
+				fmt.Printf("%-8s .%-8s %s\n", e.Name(), e.Extension(), e.MIMEType())
+				printOptions(e.Options())
+			}
+			fmt.Println("\n输入格式（novel convert，选项通过 --import-option key=value 指定）：")
+			for _, name := range format.ImporterNames() {
+				i, _ := format.LookupImporter(name)
+				fmt.Printf("%-8s .%s\n", i.Name(), strings.Join(i.Extensions(), " ."))
+				printOptions(i.Options())
+			}
+			return nil
+		},
+	}
+}
+
+func printOptions(opts []format.Option) {
+	for _, o := range opts {
+		usage := o.Usage
+		if o.Default != "" {
+			usage += "（默认 " + o.Default + "）"
+		}
+		if len(o.Values) > 0 {
+			usage += "，可选 " + strings.Join(o.Values, "|")
+		}
+		fmt.Printf("  %-14s %s\n", o.Name, usage)
+	}
+}
+
+// formatUsage 是 -f 参数的说明，列出已注册的格式。
+func formatUsage() string {
+	return "输出格式：" + strings.Join(format.Names(), "|")
+}
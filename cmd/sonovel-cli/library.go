@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/downloader"
+	"github.com/sreio/go-novel/internal/export"
+	"github.com/sreio/go-novel/internal/format"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+func cmdLibrary() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "library",
+		Short: "本地书架：记录书籍、目录、已抓取章节与导出历史",
+	}
+	cmd.AddCommand(cmdLibraryList(), cmdLibraryAdd(), cmdLibraryRemove(), cmdLibraryShow())
+	return cmd
+}
+
+func openLibrary() (*library.Library, error) {
+	return library.Open(libraryDir, workDir)
+}
+
+func cmdLibraryList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "列出书架上的书",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lib, err := openLibrary()
+			if err != nil {
+				return err
+			}
+			books, err := lib.List()
+			if err != nil {
+				return err
+			}
+			if len(books) == 0 {
+				fmt.Println("书架为空，使用 novel library add --url 添加")
+				return nil
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\t书源\t书名\t作者\t已抓取\t导出\t更新时间")
+			for _, b := range books {
+				m, fetched, err := lib.Status(b)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d/%d\t%d\t%s\n", b.ID, b.Source, b.Title, b.Author, fetched, len(m.Chapters), len(b.Exports), b.UpdatedAt.Format("2006-01-02 15:04"))
+			}
+			return tw.Flush()
+		},
+	}
+}
+
+func cmdLibraryAdd() *cobra.Command {
+	var bookURL, bookTitle, bookAuthor, category string
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "添加书籍到书架并获取目录（不下载正文）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ss, err := loadAllSources(sourcesDir)
+			if err != nil {
+				return err
+			}
+			src := export.SourceForURL(ss, bookURL)
+			if src == nil {
+				return fmt.Errorf("no source available")
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			chs, err := src.Chapters(ctx, bookURL, bookURL)
+			if err != nil {
+				return err
+			}
+			store, err := checkpoint.Open(workDir, bookURL)
+			if err != nil {
+				return err
+			}
+			if err := store.SaveManifest(checkpoint.Manifest{BookURL: bookURL, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Chapters: chs}); err != nil {
+				return err
+			}
+			lib, err := openLibrary()
+			if err != nil {
+				return err
+			}
+			b, err := lib.Put(library.Book{URL: bookURL, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Category: category, Chapters: len(chs)})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("已添加 %s %s（%d 章）\n", b.ID, b.Title, len(chs))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&bookURL, "url", "", "书籍详情页 URL")
+	cmd.Flags().StringVar(&bookTitle, "title", "", "书籍标题")
+	cmd.Flags().StringVar(&bookAuthor, "author", "", "书籍作者")
+	cmd.Flags().StringVar(&category, "category", "", "分类")
+	_ = cmd.MarkFlagRequired("url")
+	return cmd
+}
+
+func cmdLibraryRemove() *cobra.Command {
+	var purge bool
+	cmd := &cobra.Command{
+		Use:   "remove <id|url>",
+		Short: "从书架移除",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lib, err := openLibrary()
+			if err != nil {
+				return err
+			}
+			b, err := lib.Remove(args[0], purge)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("已移除 %s %s\n", b.ID, b.Title)
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&purge, "purge", false, "同时删除检查点（目录与已抓取正文）")
+	return cmd
+}
+
+func cmdLibraryShow() *cobra.Command {
+	var showChapters bool
+	cmd := &cobra.Command{
+		Use:   "show <id|url>",
+		Short: "查看书籍详情、抓取进度与导出历史",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lib, err := openLibrary()
+			if err != nil {
+				return err
+			}
+			b, err := lib.Get(args[0])
+			if err != nil {
+				return err
+			}
+			m, fetched, err := lib.Status(b)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("ID：%s\n书名：%s\n作者：%s\n书源：%s\nURL：%s\n", b.ID, b.Title, b.Author, b.Source, b.URL)
+			if b.Category != "" {
+				fmt.Printf("分类：%s\n", b.Category)
+			}
+			fmt.Printf("章节：已抓取 %d/%d\n加入时间：%s\n", fetched, len(m.Chapters), b.AddedAt.Format("2006-01-02 15:04"))
+
+			if len(b.Exports) > 0 {
+				fmt.Println("导出历史：")
+				tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				for _, e := range b.Exports {
+					fmt.Fprintf(tw, "  %s\t%s\t%d 章\t失败 %d\t%s\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Format, e.Chapters, e.Failed, e.Path)
+				}
+				tw.Flush()
+			}
+			if showChapters {
+				store, err := lib.Checkpoint(b)
+				if err != nil {
+					return err
+				}
+				for i, c := range m.Chapters {
+					mark := " "
+					if store.Has(c.URL) {
+						mark = "✓"
+					}
+					fmt.Printf("%s %4d. %s\n", mark, i+1, c.Title)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&showChapters, "chapters", false, "列出章节及是否已抓取")
+	return cmd
+}
+
+// recordBook 把下载的书记入书架；书架出错只提示，不影响下载结果。
+func recordBook(b library.Book) {
+	lib, err := openLibrary()
+	if err == nil {
+		_, err = lib.Put(b)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "更新书架失败: %v\n", err)
+	}
+}
+
+// recordedOptions 返回书架中该输出文件最近一次导出时使用的选项，没有记录时返回 nil。
+func recordedOptions(bookURL, path string) format.Options {
+	lib, err := openLibrary()
+	if err != nil {
+		return nil
+	}
+	b, err := lib.Get(bookURL)
+	if err != nil {
+		return nil
+	}
+	for i := len(b.Exports) - 1; i >= 0; i-- {
+		if e := b.Exports[i]; e.Path == path {
+			return e.Options
+		}
+	}
+	return nil
+}
+
+// recordExport 在书架中追加一条导出记录；书不在书架上时忽略。
+func recordExport(bookURL string, e library.Export) {
+	lib, err := openLibrary()
+	if err == nil {
+		err = lib.AddExport(bookURL, e)
+	}
+	if err != nil && !errors.Is(err, library.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "更新书架失败: %v\n", err)
+	}
+}
+
+func cmdUpdate() *cobra.Command {
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "update [id|url]",
+		Short: "检查书架上连载中的书，只抓取新增章节并刷新已有导出",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("specify a book id/url or --all")
+			}
+			lib, err := openLibrary()
+			if err != nil {
+				return err
+			}
+			var books []library.Book
+			if all {
+				if books, err = lib.List(); err != nil {
+					return err
+				}
+			} else {
+				b, err := lib.Get(args[0])
+				if err != nil {
+					return err
+				}
+				books = []library.Book{b}
+			}
+			ss, err := loadAllSources(sourcesDir)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			failed := 0
+			for _, b := range books {
+				if err := updateBook(ctx, lib, ss, b); err != nil {
+					fmt.Fprintf(os.Stderr, "[%s] %s 更新失败: %v\n", b.ID, b.Title, err)
+					failed++
+					if ctx.Err() != nil {
+						break
+					}
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d book(s) failed to update", failed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "更新书架上的全部书籍")
+	return cmd
+}
+
+// updateBook 更新一本书并打印变化。
+func updateBook(ctx context.Context, lib *library.Library, ss []*sources.ConfigSource, b library.Book) error {
+	src := export.SourceForBook(ss, b)
+	if src == nil {
+		return fmt.Errorf("no source available")
+	}
+	progressed := false
+	u, err := lib.Update(ctx, b.ID, library.UpdateOptions{Source: src, Sources: ss, Concurrency: concurrency, Progress: func(e downloader.Event) {
+		progressed = progressed || e.Kind != downloader.EventSkipped
+		printProgress(e)
+	}})
+	if progressed {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[%s] %s：%d → %d 章，新增 %d 章\n", b.ID, u.Book.Title, u.Before, u.After, len(u.Added))
+	for _, c := range u.Added {
+		fmt.Printf("  + %4d. %s\n", c.Index+1, c.Title)
+	}
+	for _, f := range u.Failures {
+		fmt.Printf("  ! %4d. %s 抓取失败：%s\n", f.Index+1, f.Title, f.Error)
+	}
+	refreshed, err := export.Refresh(ctx, lib, u, src, exportOpts)
+	for _, r := range refreshed {
+		if r.Mode == export.ModeAppend {
+			fmt.Printf("  追加 %d 章 → %s\n", len(u.Fetched), r.Path)
+		} else {
+			fmt.Printf("  重新生成 → %s\n", r.Path)
+		}
+	}
+	return err
+}
@@ -5,33 +5,84 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
-	fepub "github.com/sreio/go-novel/internal/format/epub"
-	fpdf "github.com/sreio/go-novel/internal/format/pdf"
-	ftxt "github.com/sreio/go-novel/internal/format/txt"
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/downloader"
+	"github.com/sreio/go-novel/internal/export"
+	"github.com/sreio/go-novel/internal/format"
+	_ "github.com/sreio/go-novel/internal/format/all"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/search"
 	"github.com/sreio/go-novel/internal/sources"
-	"golang.org/x/sync/errgroup"
 )
 
 var (
 	sourcesDir  string
 	outputDir   string
+	workDir     string
+	libraryDir  string
 	concurrency int
+	fontPath    string
+	optionArgs  []string
+	exportOpts  format.Options
 )
 
+// shorthands 是常用导出选项的简写参数。
+var shorthands = map[string]string{
+	"font":             "font",
+	"pdf-page":         "page",
+	"pdf-margin":       "margin",
+	"pdf-font-size":    "font-size",
+	"pdf-line-spacing": "line-spacing",
+}
+
 func main() {
-	root := &cobra.Command{Use: "novel"}
+	root := &cobra.Command{
+		Use: "novel",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			opts, err := format.ParseOptions(optionArgs)
+			if err != nil {
+				return err
+			}
+			// 显式给出的简写参数作为默认值，--option 中的同名选项优先
+			base := format.Options{}
+			for flag, key := range shorthands {
+				if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+					base[key] = f.Value.String()
+				}
+			}
+			exportOpts = base.Merge(opts)
+			return nil
+		},
+	}
 	root.PersistentFlags().StringVar(&sourcesDir, "sources", "./configs/sources", "书源配置目录")
 	root.PersistentFlags().StringVar(&outputDir, "out", "./outputs", "输出目录")
-	root.PersistentFlags().IntVar(&concurrency, "concurrency", 8, "章节并发下载数")
+	root.PersistentFlags().StringVar(&workDir, "work-dir", "./outputs/.checkpoints", "下载检查点目录（断点续传）")
+	root.PersistentFlags().StringVar(&libraryDir, "library", "./outputs/library", "本地书架目录")
+	root.PersistentFlags().IntVar(&concurrency, "concurrency", 8, "章节并发下载上限（书源未配置 concurrency.max 时使用）")
+	root.PersistentFlags().StringVar(&fontPath, "font", "", "中文字体文件（TTF/OTF/TTC），用于封面与 PDF；默认取环境变量 NOVEL_FONT 或系统字体")
+	root.PersistentFlags().StringArrayVar(&optionArgs, "option", nil, "导出选项 key=value，可重复；各格式支持的选项见 novel formats")
+	root.PersistentFlags().String("pdf-page", "a4", "PDF 页面：a4|a5|6in（6 寸墨水屏），即 --option page=")
+	root.PersistentFlags().Float64("pdf-margin", 0, "PDF 页边距（毫米），0 使用页面预设，即 --option margin=")
+	root.PersistentFlags().Float64("pdf-font-size", 0, "PDF 正文字号（pt），0 使用页面预设，即 --option font-size=")
+	root.PersistentFlags().Float64("pdf-line-spacing", 1.6, "PDF 行距（字号的倍数），即 --option line-spacing=")
 
 	root.AddCommand(cmdSearch())
 	root.AddCommand(cmdDownload())
+	root.AddCommand(cmdRetry())
+	root.AddCommand(cmdExport())
+	root.AddCommand(cmdLibrary())
+	root.AddCommand(cmdUpdate())
+	root.AddCommand(cmdWebhook())
+	root.AddCommand(cmdFormats())
+	root.AddCommand(cmdConvert())
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -54,10 +105,13 @@ func loadAllSources(dir string) ([]*sources.ConfigSource, error) {
 }
 
 func cmdSearch() *cobra.Command {
-	var keyword string
+	var keyword, sortBy string
 	cmd := &cobra.Command{
 		Use: "search",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !search.ValidSort(sortBy) {
+				return fmt.Errorf("unknown sort: %s", sortBy)
+			}
 			ss, err := loadAllSources(sourcesDir)
 			if err != nil {
 				return err
@@ -70,55 +124,71 @@ func cmdSearch() *cobra.Command {
 			}
 			var res []pair
 			for _, s := range ss {
+				if !s.Available() {
+					fmt.Fprintf(os.Stderr, "[%s]%s 熔断中，已跳过\n", s.ID(), s.Name())
+					continue
+				}
 				items, _ := s.Search(ctx, keyword, 1)
 				res = append(res, pair{src: s, items: items})
 			}
+			if sortBy == search.SortSource {
+				for _, p := range res {
+					fmt.Printf("[%s]%s\n", p.src.ID(), p.src.Name())
+					for i, b := range p.items {
+						fmt.Printf("  %d. %s — %s (%s)\n", i+1, b.Title, b.Author, b.ID)
+					}
+				}
+				return nil
+			}
+
+			// 跨书源合并后统一排序
+			var all []search.Result
 			for _, p := range res {
-				fmt.Printf("[%s]%s\n", p.src.ID(), p.src.Name())
-				for i, b := range p.items {
-					fmt.Printf("  %d. %s — %s (%s)\n", i+1, b.Title, b.Author, b.ID)
+				for _, b := range p.items {
+					all = append(all, search.Result{Book: b, Source: p.src.Name()})
 				}
 			}
+			search.Rank(keyword, all, sortBy)
+			for i, r := range all {
+				fmt.Printf("%d. [%s] %s — %s %s (%s)\n", i+1, r.Source, r.Title, r.Author, r.Update, r.ID)
+			}
 			return nil
 		},
 	}
 	cmd.Flags().StringVarP(&keyword, "keyword", "k", "", "关键词")
+	cmd.Flags().StringVar(&sortBy, "sort", search.SortRelevance, "排序方式：relevance|source|update")
 	_ = cmd.MarkFlagRequired("keyword")
 	return cmd
 }
 
-func chooseSourceByURL(all []*sources.ConfigSource, u string) *sources.ConfigSource {
-	sort.SliceStable(all, func(i, j int) bool { return len(all[i].Name()) > len(all[j].Name()) })
-	for _, s := range all {
-		if s == nil || u == "" {
-			continue
-		}
-		if strings.Contains(u, s.Name()) || strings.Contains(u, s.ID()) {
-			return s
-		}
-	}
-	if len(all) > 0 {
-		return all[0]
-	}
-	return nil
-}
-
 func cmdDownload() *cobra.Command {
 	var bookURL, format, bookTitle, bookAuthor string
+	var tolerant bool
+	var timeout time.Duration
 	cmd := &cobra.Command{
 		Use: "download",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			exp, err := exporter(format)
+			if err != nil {
+				return err
+			}
 			ss, err := loadAllSources(sourcesDir)
 			if err != nil {
 				return err
 			}
-			src := chooseSourceByURL(ss, bookURL)
+			src := export.SourceForURL(ss, bookURL)
 			if src == nil {
 				return fmt.Errorf("no source available")
 			}
 
-			ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
-			defer cancel()
+			// Ctrl-C / 超时后已抓取的章节都在检查点里，重新运行即可续传
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
 
 			chs, err := src.Chapters(ctx, bookURL, bookURL)
 			if err != nil {
@@ -128,90 +198,355 @@ func cmdDownload() *cobra.Command {
 				return fmt.Errorf("no chapters found")
 			}
 
-			type text struct{ Title, Content string }
-			out := make([]text, len(chs))
-
-			sem := make(chan struct{}, concurrency)
-			g, gctx := errgroup.WithContext(ctx)
-			for i := range chs {
-				i := i
-				g.Go(func() error {
-					select {
-					case sem <- struct{}{}:
-					case <-gctx.Done():
-						return gctx.Err()
-					}
-					defer func() { <-sem }()
-					content, err := src.Content(gctx, chs[i])
-					if err != nil {
-						return err
-					}
-					out[i] = text{Title: chs[i].Title, Content: content}
-					return nil
-				})
+			store, err := checkpoint.Open(workDir, bookURL)
+			if err != nil {
+				return err
 			}
-			if err := g.Wait(); err != nil {
+			if err := store.SaveManifest(checkpoint.Manifest{BookURL: bookURL, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Chapters: chs}); err != nil {
 				return err
 			}
+			recordBook(library.Book{URL: bookURL, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Chapters: len(chs)})
+
+			if n := len(store.Missing(chs)); n < len(chs) {
+				fmt.Printf("检查点已有 %d/%d 章，继续下载剩余 %d 章\n", len(chs)-n, len(chs), n)
+			}
 
 			if err := os.MkdirAll(outputDir, 0o755); err != nil {
 				return err
 			}
-			// 构建文件名: 渠道名_书名_作者.格式
-		fname := fmt.Sprintf("%s_%s_%s.%s", src.Name(), bookTitle, bookAuthor, strings.ToLower(format))
-		if bookTitle == "" {
-			fname = "book." + strings.ToLower(format)
-		}
-		
-		// 清理文件名中的非法字符
-		fname = strings.ReplaceAll(fname, "/", "_")
-		fname = strings.ReplaceAll(fname, "\\", "_")
-		fname = strings.ReplaceAll(fname, ":", "_")
-		fname = strings.ReplaceAll(fname, "*", "_")
-		fname = strings.ReplaceAll(fname, "?", "_")
-		fname = strings.ReplaceAll(fname, "\"", "_")
-		fname = strings.ReplaceAll(fname, "<", "_")
-		fname = strings.ReplaceAll(fname, ">", "_")
-		fname = strings.ReplaceAll(fname, "|", "_")
-			if u, e := url.Parse(bookURL); e == nil {
-				base := filepath.Base(u.Path)
-				if base != "" && base != "/" {
-					bookTitle = base
-					fname = fmt.Sprintf("%s_%s_%s.%s", src.Name(), bookTitle, bookAuthor, strings.ToLower(format))
-				}
+			dst := filepath.Join(outputDir, bookFileName(src.Name(), bookTitle, bookAuthor, exp.Extension(), bookURL))
+			title := displayTitle(bookTitle, bookURL)
+			// 支持流式导出的格式边下载边写文件，内存中不保留整本书
+			file, err := export.Create(dst, exp, export.WithCover(ctx, export.Meta{Title: title, Author: bookAuthor, Source: src.Name(), URL: bookURL}, exp, exportOpts, store, src), exportOpts)
+			if err != nil {
+				return err
 			}
-			dst := filepath.Join(outputDir, fname)
 
-			switch strings.ToLower(format) {
-			case "txt":
-				conv := make([]ftxt.Chapter, len(out))
-				for i, c := range out {
-					conv[i] = ftxt.Chapter{Title: c.Title, Content: c.Content}
+			// 主书源失败或返回占位内容时，按书名+作者到其它书源找同一章
+			fb := sources.NewFallback(ctx, src, ss, bookTitle, bookAuthor)
+			initial, ceiling := src.Concurrency(concurrency)
+			res, err := downloader.Run(ctx, chs, downloader.Options{
+				Source:             src.Name(),
+				Fetcher:            fb,
+				Concurrency:        ceiling,
+				InitialConcurrency: initial,
+				Tolerant:           tolerant,
+				Store:              store,
+				Progress:           printProgress,
+			}, func(i int, t report.Text) error {
+				return export.WriteText(file, t)
+			})
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				if part, perr := file.Interrupt(); perr == nil && part != "" {
+					fmt.Fprintf(os.Stderr, "已下载的章节导出到 %s\n", part)
+				}
+				if ctx.Err() != nil {
+					fmt.Fprintf(os.Stderr, "下载中断，已抓取的章节保存在 %s，重新运行即可续传\n", store.Path())
 				}
-				return ftxt.Save(dst, conv)
-			case "epub":
-				chapters := make([]fepub.Chapter, len(out))
-				for i, c := range out {
-					chapters[i] = fepub.Chapter{Title: c.Title, Content: c.Content}
+				return err
+			}
+			if err := closeBook(file); err != nil {
+				return err
+			}
+			failures, origins := res.Failures, res.Origins
+
+			if len(origins) > 0 {
+				if err := export.WriteOrigins(dst, src.Name(), origins); err != nil {
+					return err
 				}
-				meta := fepub.Meta{Title: bookTitle, Author: bookAuthor}
-				return fepub.Save(dst, meta, chapters)
-			case "pdf":
-				chapters := make([]fpdf.Chapter, len(out))
-				for i, c := range out {
-					chapters[i] = fpdf.Chapter{Title: c.Title, Content: c.Content}
+				for _, o := range origins {
+					fmt.Printf("第 %d 章 %s 来自备用书源 %s\n", o.Index+1, o.Title, o.Source)
 				}
-				meta := fpdf.Meta{Title: bookTitle, Author: bookAuthor}
-				return fpdf.Save(dst, meta, chapters)
-			default:
-				return fmt.Errorf("unknown format: %s", format)
 			}
+
+			recordExport(bookURL, library.Export{Format: exp.Name(), Path: dst, Chapters: len(chs), Failed: len(failures), Options: exportOpts.For(exp)})
+			if !tolerant {
+				return nil
+			}
+			rep := &report.Report{
+				BookURL:   bookURL,
+				Source:    src.Name(),
+				Title:     title,
+				Author:    bookAuthor,
+				Format:    exp.Name(),
+				Output:    dst,
+				Options:   exportOpts.For(exp),
+				Total:     len(chs),
+				Failures:  failures,
+				CreatedAt: time.Now(),
+			}
+			rep.PrintTable(os.Stdout)
+			return rep.Save()
 		},
 	}
 	cmd.Flags().StringVar(&bookURL, "url", "", "书籍详情页 URL")
-	cmd.Flags().StringVarP(&format, "format", "f", "txt", "输出格式：txt|epub|pdf")
+	cmd.Flags().StringVarP(&format, "format", "f", "txt", formatUsage())
 	cmd.Flags().StringVar(&bookTitle, "title", "", "书籍标题")
 	cmd.Flags().StringVar(&bookAuthor, "author", "", "书籍作者")
+	cmd.Flags().BoolVar(&tolerant, "tolerant", false, "容错模式：失败章节末尾重试，仍失败则写入占位内容并生成报告")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "整体超时（如 15m），0 表示不限制；中断后可续传")
+	_ = cmd.MarkFlagRequired("url")
+	return cmd
+}
+
+func cmdExport() *cobra.Command {
+	var bookURL, format, bookTitle, bookAuthor string
+	var tolerant bool
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "仅根据检查点导出（不访问网络）",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			exp, err := exporter(format)
+			if err != nil {
+				return err
+			}
+			store, err := checkpoint.Open(workDir, bookURL)
+			if err != nil {
+				return err
+			}
+			m, err := store.LoadManifest()
+			if err != nil {
+				if checkpoint.IsNotExist(err) {
+					return fmt.Errorf("no checkpoint for %s", bookURL)
+				}
+				return err
+			}
+			if bookTitle == "" {
+				bookTitle = m.Title
+			}
+			if bookAuthor == "" {
+				bookAuthor = m.Author
+			}
+
+			if !tolerant {
+				if missing := store.Missing(m.Chapters); len(missing) > 0 {
+					i := missing[0]
+					return fmt.Errorf("chapter %d (%s) not in checkpoint, rerun download or use --tolerant", i+1, m.Chapters[i].Title)
+				}
+			}
+
+			if err := os.MkdirAll(outputDir, 0o755); err != nil {
+				return err
+			}
+			dst := filepath.Join(outputDir, bookFileName(m.Source, bookTitle, bookAuthor, exp.Extension(), bookURL))
+			title := displayTitle(bookTitle, bookURL)
+			file, err := export.Create(dst, exp, export.WithCover(cmd.Context(), export.Meta{Title: title, Author: bookAuthor, Source: m.Source, URL: bookURL}, exp, exportOpts, store, nil), exportOpts)
+			if err != nil {
+				return err
+			}
+			// 逐章从检查点读出并写入，不把整本书读进内存
+			var failures []report.Failure
+			for i, c := range m.Chapters {
+				content, err := store.Get(c.URL)
+				if err != nil && !tolerant {
+					file.Abort()
+					return err
+				}
+				if err != nil {
+					f := report.Failure{Index: i, Title: c.Title, URL: c.URL, Error: "not downloaded"}
+					failures = append(failures, f)
+					content = report.Placeholder(f)
+				}
+				if err := export.WriteText(file, report.Text{Title: c.Title, Content: content}); err != nil {
+					file.Abort()
+					return err
+				}
+			}
+			if err := closeBook(file); err != nil {
+				return err
+			}
+			recordExport(bookURL, library.Export{Format: exp.Name(), Path: dst, Chapters: len(m.Chapters), Failed: len(failures), Options: exportOpts.For(exp)})
+			if !tolerant {
+				return nil
+			}
+			rep := &report.Report{
+				BookURL:   bookURL,
+				Source:    m.Source,
+				Title:     title,
+				Author:    bookAuthor,
+				Format:    exp.Name(),
+				Output:    dst,
+				Options:   exportOpts.For(exp),
+				Total:     len(m.Chapters),
+				Failures:  failures,
+				CreatedAt: time.Now(),
+			}
+			rep.PrintTable(os.Stdout)
+			return rep.Save()
+		},
+	}
+	cmd.Flags().StringVar(&bookURL, "url", "", "书籍详情页 URL（与下载时一致）")
+	cmd.Flags().StringVarP(&format, "format", "f", "txt", formatUsage())
+	cmd.Flags().StringVar(&bookTitle, "title", "", "书籍标题（默认取检查点记录）")
+	cmd.Flags().StringVar(&bookAuthor, "author", "", "书籍作者（默认取检查点记录）")
+	cmd.Flags().BoolVar(&tolerant, "tolerant", false, "缺失章节写入占位内容而不是报错")
 	_ = cmd.MarkFlagRequired("url")
 	return cmd
 }
+
+func cmdRetry() *cobra.Command {
+	var reportPath string
+	cmd := &cobra.Command{
+		Use:   "retry",
+		Short: "根据容错下载的报告重新抓取失败章节并重新导出",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rep, err := report.Load(reportPath)
+			if err != nil {
+				return err
+			}
+			store, err := checkpoint.Open(workDir, rep.BookURL)
+			if err != nil {
+				return err
+			}
+			m, err := store.LoadManifest()
+			if err != nil {
+				return fmt.Errorf("load checkpoint: %w", err)
+			}
+			ss, err := loadAllSources(sourcesDir)
+			if err != nil {
+				return err
+			}
+			src := export.SourceForURL(ss, rep.BookURL)
+			if src == nil {
+				return fmt.Errorf("no source available")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			exp, err := exporter(rep.Format)
+			if err != nil {
+				return err
+			}
+			// 沿用原导出的选项（较早的报告没有记录时取书架中的记录），命令行显式给出的选项优先
+			recorded := format.Options(rep.Options)
+			if recorded == nil {
+				recorded = recordedOptions(rep.BookURL, rep.Output)
+			}
+			opts := recorded.Merge(exportOpts)
+			if err := format.Validate(exp, opts); err != nil {
+				return err
+			}
+			// 早先的报告里书名可能是 URL 最后一段，检查点中有 --title 给出的书名时以它为准（封面与元数据都用它）
+			if m.Title != "" {
+				rep.Title = m.Title
+			}
+			file, err := export.Create(rep.Output, exp, export.WithCover(ctx, export.Meta{Title: rep.Title, Author: rep.Author, Source: rep.Source, URL: rep.BookURL}, exp, opts, store, src), opts)
+			if err != nil {
+				return err
+			}
+
+			// 报告里的失败章节，以及检查点中缺失的章节都重新抓取
+			fb := sources.NewFallback(ctx, src, ss, m.Title, m.Author)
+			initial, ceiling := src.Concurrency(concurrency)
+			res, err := downloader.Run(ctx, m.Chapters, downloader.Options{
+				Source:             src.Name(),
+				Fetcher:            fb,
+				Concurrency:        ceiling,
+				InitialConcurrency: initial,
+				Tolerant:           true,
+				Store:              store,
+				Progress:           printProgress,
+			}, func(i int, t report.Text) error {
+				return export.WriteText(file, t)
+			})
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				file.Abort()
+				return err
+			}
+			if err := closeBook(file); err != nil {
+				return err
+			}
+			for _, o := range res.Origins {
+				fmt.Printf("第 %d 章 %s 来自备用书源 %s\n", o.Index+1, o.Title, o.Source)
+			}
+
+			recordExport(rep.BookURL, library.Export{Format: rep.Format, Path: rep.Output, Chapters: len(m.Chapters), Failed: len(res.Failures), Options: opts.For(exp)})
+			rep.Options = opts.For(exp)
+			rep.Total = len(m.Chapters)
+			rep.Failures = res.Failures
+			rep.CreatedAt = time.Now()
+			rep.PrintTable(os.Stdout)
+			return rep.Save()
+		},
+	}
+	cmd.Flags().StringVar(&reportPath, "report", "", "容错下载生成的 <输出文件>.report.json")
+	_ = cmd.MarkFlagRequired("report")
+	return cmd
+}
+
+// printProgress 在终端同一行刷新下载进度。
+func printProgress(e downloader.Event) {
+	if e.Kind == downloader.EventSkipped {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r下载进度: %d/%d (%.1f%%) 失败 %d 活跃线程 %d/%d", e.Completed, e.Total, e.Percentage, e.Failed, e.Active, e.Level)
+}
+
+// bookFileName 构建文件名: 渠道名_书名_作者.格式；书籍 URL 有路径时书名部分取路径最后一段。
+func bookFileName(srcName, bookTitle, bookAuthor, ext, bookURL string) string {
+	fname := fmt.Sprintf("%s_%s_%s.%s", srcName, bookTitle, bookAuthor, ext)
+	if bookTitle == "" {
+		fname = "book." + ext
+	}
+	if base := urlBase(bookURL); base != "" {
+		fname = fmt.Sprintf("%s_%s_%s.%s", srcName, base, bookAuthor, ext)
+	}
+
+	// 清理文件名中的非法字符
+	fname = strings.ReplaceAll(fname, "/", "_")
+	fname = strings.ReplaceAll(fname, "\\", "_")
+	fname = strings.ReplaceAll(fname, ":", "_")
+	fname = strings.ReplaceAll(fname, "*", "_")
+	fname = strings.ReplaceAll(fname, "?", "_")
+	fname = strings.ReplaceAll(fname, "\"", "_")
+	fname = strings.ReplaceAll(fname, "<", "_")
+	fname = strings.ReplaceAll(fname, ">", "_")
+	fname = strings.ReplaceAll(fname, "|", "_")
+	return fname
+}
+
+// displayTitle 返回写入导出文件与报告的书名：--title 或检查点中的书名，都没有时取 URL 路径最后一段。
+func displayTitle(bookTitle, bookURL string) string {
+	if bookTitle != "" {
+		return bookTitle
+	}
+	return urlBase(bookURL)
+}
+
+// urlBase 返回 URL 路径的最后一段，没有路径时返回空串。
+func urlBase(bookURL string) string {
+	u, err := url.Parse(bookURL)
+	if err != nil {
+		return ""
+	}
+	if base := filepath.Base(u.Path); base != "" && base != "/" && base != "." {
+		return base
+	}
+	return ""
+}
+
+// exporter 返回格式对应的导出器，并检查导出选项；下载前调用，避免抓完整本书才发现参数有误。
+func exporter(name string) (format.Exporter, error) {
+	e, ok := format.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %s (%s)", name, strings.Join(format.Names(), "|"))
+	}
+	return e, format.Validate(e, exportOpts)
+}
+
+// closeBook 完成导出；按选项拆分为多个文件时列出各个文件。
+func closeBook(f *format.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if paths := f.Paths(); len(paths) > 1 {
+		fmt.Printf("已拆分为 %d 个文件：\n", len(paths))
+		for _, p := range paths {
+			fmt.Println("  " + p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/sreio/go-novel/internal/webhook"
+)
+
+func cmdWebhook() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "webhook 工具",
+	}
+	cmd.AddCommand(cmdWebhookListen())
+	return cmd
+}
+
+// cmdWebhookListen 启动本地接收端，打印收到的事件并校验签名，用于调试 sonovel-web 的 webhook 配置。
+func cmdWebhookListen() *cobra.Command {
+	var addr, secret string
+	var status int
+	cmd := &cobra.Command{
+		Use:   "listen",
+		Short: "启动本地 webhook 接收端并打印收到的事件",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				sig := r.Header.Get(webhook.HeaderSignature)
+				verdict := "未签名"
+				switch {
+				case secret != "" && !webhook.Verify(secret, body, sig):
+					verdict = "签名无效"
+				case secret != "":
+					verdict = "签名有效"
+				case sig != "":
+					verdict = "未校验（未指定 --secret）"
+				}
+				fmt.Printf("[%s] %s %s  事件=%s  投递=%s  %s\n", time.Now().Format("15:04:05"), r.Method, r.URL.Path,
+					r.Header.Get(webhook.HeaderEvent), r.Header.Get(webhook.HeaderDelivery), verdict)
+				var pretty bytes.Buffer
+				if json.Indent(&pretty, body, "  ", "  ") == nil {
+					fmt.Printf("  %s\n", pretty.String())
+				} else {
+					fmt.Printf("  %s\n", body)
+				}
+				if secret != "" && verdict == "签名无效" {
+					http.Error(w, "invalid signature", http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(status)
+			})
+			log.Printf("webhook 接收端监听 %s", addr)
+			return http.ListenAndServe(addr, nil)
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", ":9000", "监听地址")
+	cmd.Flags().StringVar(&secret, "secret", "", "校验 X-GoNovel-Signature 的密钥")
+	cmd.Flags().IntVar(&status, "status", http.StatusNoContent, "返回的状态码（可用于测试重试，如 500）")
+	return cmd
+}
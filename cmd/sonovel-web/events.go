@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SSE 事件类型
+const (
+	EventSnapshot      = "snapshot"       // 任务当前完整状态，新连接或无法补发时发送
+	EventPhase         = "phase"          // 阶段变化
+	EventChapterDone   = "chapter_done"   // 章节抓取成功
+	EventChapterRetry  = "chapter_retry"  // 章节失败，稍后重试
+	EventChapterFailed = "chapter_failed" // 章节重试后仍失败
+	EventFinished      = "finished"       // 任务结束（done/failed/canceled），随后关闭流
+)
+
+const (
+	streamHistory = 256 // 每个任务保留的最近事件数，用于断线重连补发
+	streamBuffer  = 64  // 每个订阅者的缓冲，写满说明客户端过慢，断开后由其重连补齐
+)
+
+type sseEvent struct {
+	ID   int64
+	Type string
+	Data []byte
+}
+
+// eventStream 把一个任务的事件广播给所有订阅者，并保留最近的事件供重连补发。
+type eventStream struct {
+	mu      sync.Mutex
+	seq     int64
+	history []sseEvent
+	subs    map[chan sseEvent]struct{}
+	closed  bool
+}
+
+func newEventStream() *eventStream {
+	return &eventStream{subs: make(map[chan sseEvent]struct{})}
+}
+
+// publish 发送一个事件，不会因订阅者过慢而阻塞。
+func (s *eventStream) publish(typ string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.seq++
+	e := sseEvent{ID: s.seq, Type: typ, Data: data}
+	s.history = append(s.history, e)
+	if len(s.history) > streamHistory {
+		s.history = s.history[len(s.history)-streamHistory:]
+	}
+	for ch := range s.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// close 结束广播，订阅者在收完缓冲中的事件后退出。
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// subscribe 订阅 lastID 之后的事件，返回需补发的事件。历史中接不上时，补发的是由 snapshot 生成的
+// 一条快照事件（ID 为当前序号）。调用方应保证生成快照的状态与事件发布互斥，快照与之后收到的事件才能不重不漏。
+// 流已关闭时 ch 为 nil。
+func (s *eventStream) subscribe(lastID int64, snapshot func() any) (replay []sseEvent, ch chan sseEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	full := lastID <= 0 || lastID > s.seq || len(s.history) == 0 || s.history[0].ID > lastID+1
+	switch {
+	case full:
+		data, err := json.Marshal(snapshot())
+		if err == nil {
+			replay = []sseEvent{{ID: s.seq, Type: EventSnapshot, Data: data}}
+		}
+	case lastID < s.seq:
+		for _, e := range s.history {
+			if e.ID > lastID {
+				replay = append(replay, e)
+			}
+		}
+	}
+	if !s.closed {
+		ch = make(chan sseEvent, streamBuffer)
+		s.subs[ch] = struct{}{}
+	}
+	return replay, ch
+}
+
+func (s *eventStream) unsubscribe(ch chan sseEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subs[ch]; ok {
+		delete(s.subs, ch)
+		close(ch)
+	}
+}
+
+// handleJobEvents 以 SSE 推送单个任务的进度。
+// 重连时浏览器会带上 Last-Event-ID（也可用 ?lastEventId=），服务端补发之后的事件，
+// 补不上时先发送 snapshot。任务已结束且没有新事件时返回 204，EventSource 收到后不再重连。
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+		return
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = r.URL.Query().Get("lastEventId")
+	}
+	lastID, _ := strconv.ParseInt(last, 10, 64)
+	stream, replay, ch, ok := s.jobs.Subscribe(id, lastID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrJobNotFound.Error()})
+		return
+	}
+	if ch != nil {
+		defer stream.unsubscribe(ch)
+	} else if len(replay) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	for _, e := range replay {
+		writeSSE(w, e)
+	}
+	flusher.Flush()
+	if ch == nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSE(w, e)
+			flusher.Flush()
+		case <-heartbeat.C:
+			// 发送心跳保持连接
+			fmt.Fprintf(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, e sseEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/downloader"
+)
+
+// 任务执行中反复订阅：快照之后收到的第一个事件必须紧接快照，既不重复也不遗漏
+func TestJobQueueSubscribeSnapshot(t *testing.T) {
+	const total = 2000
+	gate := make(chan struct{})
+	run := func(ctx context.Context, req DownloadRequest, rep Reporter) (*DownloadResult, error) {
+		<-gate
+		for i := 1; i <= total; i++ {
+			rep.Progress(downloader.Event{Kind: downloader.EventDone, Index: i - 1, Total: total, Completed: i})
+		}
+		return &DownloadResult{}, nil
+	}
+	q := NewJobQueue(1, 1, run, nil)
+	job, err := q.Submit(DownloadRequest{URL: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(gate)
+
+	for {
+		stream, replay, ch, ok := q.Subscribe(job.ID, 0)
+		if !ok {
+			t.Fatal("job not found")
+		}
+		if len(replay) != 1 || replay[0].Type != EventSnapshot {
+			t.Fatalf("replay = %+v, want a single snapshot", replay)
+		}
+		if ch == nil {
+			break
+		}
+		var snap Job
+		if err := json.Unmarshal(replay[0].Data, &snap); err != nil {
+			t.Fatal(err)
+		}
+		completed := 0
+		if snap.Progress != nil {
+			completed = snap.Progress.Completed
+		}
+		e, open := <-ch
+		stream.unsubscribe(ch)
+		if !open {
+			continue
+		}
+		if e.ID != replay[0].ID+1 {
+			t.Fatalf("first event id = %d after snapshot %d", e.ID, replay[0].ID)
+		}
+		if e.Type == EventChapterDone {
+			var ev downloader.Event
+			if err := json.Unmarshal(e.Data, &ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Completed != completed+1 {
+				t.Fatalf("snapshot completed = %d, next event completed = %d", completed, ev.Completed)
+			}
+		}
+	}
+
+	// 已结束的任务：带着最后一个事件 ID 重连时没有可补发的内容
+	_, replay, ch, _ := q.Subscribe(job.ID, 0)
+	if _, replay2, ch2, _ := q.Subscribe(job.ID, replay[0].ID); ch != nil || ch2 != nil || len(replay2) != 0 {
+		t.Fatalf("resubscribe to finished job: replay = %d events, want none", len(replay2))
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sreio/go-novel/internal/downloader"
+)
+
+// 任务状态
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+// 任务阶段
+const (
+	PhaseQueued      = "queued"      // 排队中
+	PhaseChapters    = "chapters"    // 获取目录
+	PhaseDownloading = "downloading" // 抓取章节
+	PhaseExporting   = "exporting"   // 导出文件
+)
+
+// 保留的已结束任务数，超出后淘汰最早结束的任务
+const maxFinishedJobs = 200
+
+var (
+	ErrQueueFull   = errors.New("job queue is full")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// Job 是一个后台下载任务。
+type Job struct {
+	ID         string            `json:"id"`
+	Request    DownloadRequest   `json:"request"`
+	Status     string            `json:"status"`
+	Phase      string            `json:"phase,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Progress   *downloader.Event `json:"progress,omitempty"`
+	Result     *DownloadResult   `json:"result,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	StartedAt  time.Time         `json:"startedAt,omitzero"`
+	FinishedAt time.Time         `json:"finishedAt,omitzero"`
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	events *eventStream
+}
+
+func (j *Job) finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// Reporter 接收任务执行中的阶段变化与章节进度。
+type Reporter interface {
+	Phase(phase string)
+	Progress(e downloader.Event)
+}
+
+// JobRunner 执行一个任务，通过 rep 上报进度。
+type JobRunner func(ctx context.Context, req DownloadRequest, rep Reporter) (*DownloadResult, error)
+
+// JobQueue 是进程内的有界任务队列：所有用户共享固定数量的执行协程，排队数超过容量时拒绝新任务。
+type JobQueue struct {
+	mu       sync.Mutex
+	ready    *sync.Cond // pending 非空时唤醒执行协程
+	jobs     map[string]*Job
+	order    []*Job // 按创建时间排列
+	pending  []*Job // 排队中的任务，取消时立即移出，不占用容量
+	capacity int
+	run      JobRunner
+	onFinish func(Job) // 可选：任务结束回调，在独立协程中调用
+}
+
+// NewJobQueue 创建任务队列并启动 workers 个执行协程；onFinish 可为 nil。
+func NewJobQueue(workers, capacity int, run JobRunner, onFinish func(Job)) *JobQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = 1
+	}
+	q := &JobQueue{jobs: make(map[string]*Job), capacity: capacity, run: run, onFinish: onFinish}
+	q.ready = sync.NewCond(&q.mu)
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+// Submit 提交任务；队列已满时返回 ErrQueueFull。
+func (q *JobQueue) Submit(req DownloadRequest) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.submitLocked(req)
+}
+
+// SubmitOnce 与 Submit 相同，但同一本书、同一格式与选项已有排队或执行中的任务时直接返回该任务。
+func (q *JobQueue) SubmitOnce(req DownloadRequest) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.order {
+		if (j.Status == JobQueued || j.Status == JobRunning) && j.Request.URL == req.URL && j.Request.Format == req.Format && maps.Equal(j.Request.Options, req.Options) {
+			return j, nil
+		}
+	}
+	return q.submitLocked(req)
+}
+
+func (q *JobQueue) submitLocked(req DownloadRequest) (*Job, error) {
+	if len(q.pending) >= q.capacity {
+		return nil, ErrQueueFull
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Job{ID: newJobID(), Request: req, Status: JobQueued, Phase: PhaseQueued, CreatedAt: time.Now(), ctx: ctx, cancel: cancel, done: make(chan struct{}), events: newEventStream()}
+	q.pending = append(q.pending, j)
+	q.ready.Signal()
+	q.jobs[j.ID] = j
+	q.order = append(q.order, j)
+	q.prune()
+	j.events.publish(EventPhase, map[string]string{"phase": PhaseQueued})
+	return j, nil
+}
+
+// Get 返回任务的快照。
+func (q *JobQueue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Subscribe 订阅任务 lastID 之后的事件，见 eventStream.subscribe。任务状态的修改与事件发布都在 q.mu 内，
+// 这里持有 q.mu 生成快照，快照之后的变化都会作为事件收到，不会重复。
+func (q *JobQueue) Subscribe(id string, lastID int64) (stream *eventStream, replay []sseEvent, ch chan sseEvent, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	replay, ch = j.events.subscribe(lastID, func() any { return *j })
+	return j.events, replay, ch, true
+}
+
+// List 按创建时间倒序返回所有任务的快照。
+func (q *JobQueue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]Job, 0, len(q.order))
+	for i := len(q.order) - 1; i >= 0; i-- {
+		out = append(out, *q.order[i])
+	}
+	return out
+}
+
+// Cancel 取消排队中或执行中的任务。
+func (q *JobQueue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if j.finished() {
+		return ErrJobFinished
+	}
+	j.cancel()
+	if j.Status == JobQueued {
+		// 还没开始执行：移出队列并直接结束，腾出的容量可以接收新任务
+		q.pending = slices.DeleteFunc(q.pending, func(p *Job) bool { return p == j })
+		q.finish(j, JobCanceled, context.Canceled)
+	}
+	return nil
+}
+
+func (q *JobQueue) worker() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.ready.Wait()
+		}
+		j := q.pending[0]
+		q.pending = q.pending[1:]
+		j.Status = JobRunning
+		j.StartedAt = time.Now()
+		q.mu.Unlock()
+
+		res, err := q.run(j.ctx, j.Request, &jobReporter{q: q, j: j})
+
+		q.mu.Lock()
+		switch {
+		case j.ctx.Err() != nil:
+			q.finish(j, JobCanceled, context.Canceled)
+		case err != nil:
+			q.finish(j, JobFailed, err)
+		default:
+			j.Result = res
+			q.finish(j, JobDone, nil)
+		}
+		q.mu.Unlock()
+	}
+}
+
+// finish 结束任务，调用方需持有 q.mu。
+func (q *JobQueue) finish(j *Job, status string, err error) {
+	j.Status = status
+	if err != nil {
+		j.Error = err.Error()
+	}
+	j.FinishedAt = time.Now()
+	j.cancel()
+	close(j.done)
+	j.events.publish(EventFinished, *j)
+	j.events.close()
+	if q.onFinish != nil {
+		go q.onFinish(*j)
+	}
+}
+
+// jobReporter 把执行进度写入任务状态并广播给订阅者。
+type jobReporter struct {
+	q *JobQueue
+	j *Job
+}
+
+// 状态修改与事件发布在同一把锁内完成，见 JobQueue.Subscribe
+func (r *jobReporter) Phase(phase string) {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+	r.j.Phase = phase
+	r.j.events.publish(EventPhase, map[string]string{"phase": phase})
+}
+
+func (r *jobReporter) Progress(e downloader.Event) {
+	r.q.mu.Lock()
+	defer r.q.mu.Unlock()
+	r.j.Progress = &e
+	switch e.Kind {
+	case downloader.EventDone:
+		r.j.events.publish(EventChapterDone, e)
+	case downloader.EventRetry:
+		r.j.events.publish(EventChapterRetry, e)
+	case downloader.EventFailed:
+		r.j.events.publish(EventChapterFailed, e)
+	}
+}
+
+// prune 淘汰过多的已结束任务，调用方需持有 q.mu。
+func (q *JobQueue) prune() {
+	finished := 0
+	for _, j := range q.order {
+		if j.finished() {
+			finished++
+		}
+	}
+	if finished <= maxFinishedJobs {
+		return
+	}
+	kept := q.order[:0]
+	for _, j := range q.order {
+		if finished > maxFinishedJobs && j.finished() {
+			delete(q.jobs, j.ID)
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	q.order = kept
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req DownloadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json body"})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	req.Format = strings.ToLower(strings.TrimSpace(req.Format))
+	req.Title = strings.TrimSpace(req.Title)
+	req.Author = strings.TrimSpace(req.Author)
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	job, err := s.jobs.Submit(req)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+		return
+	}
+	snap, _ := s.jobs.Get(job.ID)
+	writeJSON(w, http.StatusAccepted, snap)
+}
+
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": s.jobs.List()})
+}
+
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(chi.URLParam(r, "id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrJobNotFound.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	switch err := s.jobs.Cancel(id); {
+	case errors.Is(err, ErrJobNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, ErrJobFinished):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	default:
+		job, _ := s.jobs.Get(id)
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) handleJobFile(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.Get(chi.URLParam(r, "id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": ErrJobNotFound.Error()})
+		return
+	}
+	if job.Status != JobDone {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "job is " + job.Status})
+		return
+	}
+	w.Header().Set("Content-Type", job.Result.MIMEType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+job.Result.Name)
+	http.ServeFile(w, r, job.Result.Path)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobQueueCancelQueuedFreesCapacity(t *testing.T) {
+	started := make(chan string, 4)
+	run := func(ctx context.Context, req DownloadRequest, rep Reporter) (*DownloadResult, error) {
+		started <- req.URL
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	q := NewJobQueue(1, 1, run, nil)
+
+	running, err := q.Submit(DownloadRequest{URL: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-started; got != "a" {
+		t.Fatalf("started %q, want a", got)
+	}
+	queued, err := q.Submit(DownloadRequest{URL: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Submit(DownloadRequest{URL: "c"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit with a full queue: err = %v, want ErrQueueFull", err)
+	}
+
+	// 取消排队中的任务后立即腾出容量
+	if err := q.Cancel(queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j, _ := q.Get(queued.ID); j.Status != JobCanceled {
+		t.Fatalf("canceled job status = %q, want %q", j.Status, JobCanceled)
+	}
+	if _, err := q.Submit(DownloadRequest{URL: "c"}); err != nil {
+		t.Fatalf("Submit after canceling a queued job: %v", err)
+	}
+
+	// 被取消的任务不会再执行，下一个执行的是 c
+	if err := q.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-started:
+		if got != "c" {
+			t.Fatalf("started %q, want c", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued job c did not start")
+	}
+}
+
+func TestClaimOutput(t *testing.T) {
+	s := &Server{writing: make(map[string]bool)}
+	release, err := s.claimOutput("outputs/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.claimOutput("outputs/a.txt"); !errors.Is(err, ErrOutputBusy) {
+		t.Fatalf("second claim: err = %v, want ErrOutputBusy", err)
+	}
+	if _, err := s.claimOutput("outputs/b.txt"); err != nil {
+		t.Fatalf("claim of another file: %v", err)
+	}
+	release()
+	if _, err := s.claimOutput("outputs/a.txt"); err != nil {
+		t.Fatalf("claim after release: %v", err)
+	}
+}
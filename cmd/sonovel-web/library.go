@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/export"
+	"github.com/sreio/go-novel/internal/library"
+)
+
+// LibraryBook 是书架条目及其抓取进度。
+type LibraryBook struct {
+	library.Book
+	Total   int `json:"total"`   // 目录章节数
+	Fetched int `json:"fetched"` // 已抓取章节数
+}
+
+// LibraryChapter 是书架详情中的章节。
+type LibraryChapter struct {
+	Index   int    `json:"index"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Fetched bool   `json:"fetched"`
+}
+
+func (s *Server) handleLibraryList(w http.ResponseWriter, r *http.Request) {
+	books, err := s.library.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]LibraryBook, 0, len(books))
+	for _, b := range books {
+		m, fetched, err := s.library.Status(b)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		out = append(out, LibraryBook{Book: b, Total: len(m.Chapters), Fetched: fetched})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"books": out})
+}
+
+// handleLibraryAdd 添加书籍并获取目录（不下载正文）。
+func (s *Server) handleLibraryAdd(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL      string `json:"url"`
+		Title    string `json:"title"`
+		Author   string `json:"author"`
+		Category string `json:"category"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json body"})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing url"})
+		return
+	}
+	src := export.SourceForURL(s.sources, req.URL)
+	if src == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no source"})
+		return
+	}
+	chs, err := src.Chapters(r.Context(), req.URL, req.URL)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	store, err := checkpoint.Open(s.workDir, req.URL)
+	if err == nil {
+		err = store.SaveManifest(checkpoint.Manifest{BookURL: req.URL, Source: src.Name(), Title: req.Title, Author: req.Author, Chapters: chs})
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	b, err := s.library.Put(library.Book{URL: req.URL, Source: src.Name(), Title: strings.TrimSpace(req.Title), Author: strings.TrimSpace(req.Author), Category: strings.TrimSpace(req.Category), Chapters: len(chs)})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	_, fetched, _ := s.library.Status(b)
+	writeJSON(w, http.StatusCreated, LibraryBook{Book: b, Total: len(chs), Fetched: fetched})
+}
+
+func (s *Server) handleLibraryShow(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	m, fetched, err := s.library.Status(b)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	store, err := s.library.Checkpoint(b)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	chs := make([]LibraryChapter, len(m.Chapters))
+	for i, c := range m.Chapters {
+		chs[i] = LibraryChapter{Index: i, Title: c.Title, URL: c.URL, Fetched: store.Has(c.URL)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"book":     LibraryBook{Book: b, Total: len(m.Chapters), Fetched: fetched},
+		"chapters": chs,
+	})
+}
+
+// handleLibraryRemove 从书架移除，?purge=1 时同时删除检查点。
+func (s *Server) handleLibraryRemove(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Remove(chi.URLParam(r, "id"), r.URL.Query().Get("purge") == "1")
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	s.unsubscribeRemoved(b)
+	writeJSON(w, http.StatusOK, b)
+}
+
+func writeLibraryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, library.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// recordBook 把下载的书记入书架；书架出错只记录日志，不影响下载结果。
+func (s *Server) recordBook(b library.Book) {
+	if _, err := s.library.Put(b); err != nil {
+		log.Printf("更新书架失败: %v", err)
+	}
+}
+
+// recordExport 在书架中追加一条导出记录。
+func (s *Server) recordExport(bookURL string, e library.Export) {
+	if err := s.library.AddExport(bookURL, e); err != nil && !errors.Is(err, library.ErrNotFound) {
+		log.Printf("更新书架失败: %v", err)
+	}
+}
+
+// handleLibraryUpdate 重新获取目录，只抓取新增章节，并刷新这本书已有的导出文件。
+func (s *Server) handleLibraryUpdate(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	u, refreshed, err := s.updateBook(r.Context(), b)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"book":     u.Book,
+		"before":   u.Before,
+		"after":    u.After,
+		"added":    u.Added,
+		"failures": u.Failures,
+		"exports":  refreshed,
+	})
+}
+
+// updateBook 更新一本书并刷新其导出文件。
+func (s *Server) updateBook(ctx context.Context, b library.Book) (*library.Update, []export.Refreshed, error) {
+	src := export.SourceForBook(s.sources, b)
+	if src == nil {
+		return nil, nil, errors.New("no source")
+	}
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+	u, err := s.library.Update(ctx, b.ID, library.UpdateOptions{Source: src, Sources: s.sources, Concurrency: s.concurrency})
+	if err != nil {
+		return nil, nil, err
+	}
+	s.notifyChapters(u)
+	refreshed, err := export.Refresh(ctx, s.library, u, src, exportOptions)
+	return u, refreshed, err
+}
@@ -1,50 +1,119 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync/atomic"
+	"sync"
 	"time"
 	"unicode/utf8"
 
-	fepub "github.com/sreio/go-novel/internal/format/epub"
-	fpdf "github.com/sreio/go-novel/internal/format/pdf"
-	ftxt "github.com/sreio/go-novel/internal/format/txt"
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/downloader"
+	"github.com/sreio/go-novel/internal/export"
+	"github.com/sreio/go-novel/internal/fonts"
+	"github.com/sreio/go-novel/internal/format"
+	_ "github.com/sreio/go-novel/internal/format/all"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/scheduler"
+	"github.com/sreio/go-novel/internal/search"
 	"github.com/sreio/go-novel/internal/sources"
+	"github.com/sreio/go-novel/internal/webhook"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"golang.org/x/sync/errgroup"
 )
 
 type Server struct {
 	router      *chi.Mux
 	sourcesDir  string
+	workDir     string
 	concurrency int
 	sources     []*sources.ConfigSource
-	progressCh  chan ProgressEvent
+	jobs        *JobQueue
+	library     *library.Library
+
+	scheduler       *scheduler.Scheduler
+	defaultInterval time.Duration // 订阅书籍的默认检查间隔
+	updateMu        sync.Mutex    // 手动更新与定时更新串行执行
+
+	webhooks *webhook.Notifier
+
+	outputMu sync.Mutex
+	writing  map[string]bool // 正在写入的输出文件，见 claimOutput
 }
 
-type ProgressEvent struct {
-	TotalChapters int     `json:"totalChapters"`
-	Completed     int     `json:"completed"`
-	ActiveThreads int     `json:"activeThreads"`
-	Percentage    float64 `json:"percentage"`
+// exportOptions 是默认导出选项，启动时从环境变量读取；请求中的 options 优先。
+var exportOptions format.Options
+
+// optionEnv 是常用导出选项对应的环境变量。
+var optionEnv = map[string]string{
+	"PDF_PAGE_SIZE":    "page",
+	"PDF_MARGIN":       "margin",
+	"PDF_FONT_SIZE":    "font-size",
+	"PDF_LINE_SPACING": "line-spacing",
 }
 
 func main() {
 	srv := &Server{
 		router:      chi.NewRouter(),
 		sourcesDir:  getEnv("SOURCES_DIR", "./configs/sources"),
+		workDir:     getEnv("WORK_DIR", "./outputs/.checkpoints"),
 		concurrency: atoi(getEnv("CONCURRENCY", "8"), 8),
-		progressCh:  make(chan ProgressEvent, 100),
+		writing:     make(map[string]bool),
+	}
+	libraryDir := getEnv("LIBRARY_DIR", "./outputs/library")
+	// EXPORT_OPTIONS 形如 "page=a5,font-size=11"；PDF_* 等环境变量是其中常用选项的简写
+	opts, err := format.ParseOptions(splitList(getEnv("EXPORT_OPTIONS", "")))
+	if err != nil {
+		log.Fatalf("EXPORT_OPTIONS: %v", err)
+	}
+	exportOptions = format.Options{}
+	for env, key := range optionEnv {
+		if v := os.Getenv(env); v != "" {
+			exportOptions[key] = v
+		}
+	}
+	exportOptions = exportOptions.Merge(opts)
+	for _, name := range format.Names() {
+		e, _ := format.Lookup(name)
+		if err := format.Validate(e, exportOptions); errors.Is(err, fonts.ErrNotFound) {
+			// 官方镜像不带字体：服务照常启动，该格式的下载请求在开始前返回 400
+			log.Printf("%s export unavailable: %v", name, err)
+		} else if err != nil {
+			log.Fatalf("export options: %v", err)
+		}
+		if format.Splits(e, exportOptions) {
+			log.Fatalf("export options: %s: split is not supported by the web server", name)
+		}
+	}
+	lib, err := library.Open(libraryDir, srv.workDir)
+	if err != nil {
+		log.Fatalf("open library: %v", err)
+	}
+	srv.library = lib
+	// 订阅更新：状态保存在书架目录，重启后按原定时间继续
+	srv.defaultInterval = time.Duration(atoi(getEnv("UPDATE_INTERVAL_MINUTES", "360"), 360)) * time.Minute
+	srv.scheduler, err = scheduler.Open(filepath.Join(libraryDir, "schedule.json"), srv.runScheduledUpdate, srv.updateInterval)
+	if err != nil {
+		log.Fatalf("open scheduler: %v", err)
+	}
+	hooks, err := webhook.Load(getEnv("WEBHOOKS_FILE", "./configs/webhooks.yaml"))
+	if err != nil {
+		log.Fatalf("load webhooks: %v", err)
 	}
+	srv.webhooks = webhook.New(hooks.Webhooks)
+	// 下载任务队列：JOB_WORKERS 个任务同时执行，最多 JOB_QUEUE 个排队
+	srv.jobs = NewJobQueue(atoi(getEnv("JOB_WORKERS", "2"), 2), atoi(getEnv("JOB_QUEUE", "100"), 100), srv.runDownload, srv.notifyJob)
 
 	srv.router.Use(middleware.RealIP)
 	srv.router.Use(middleware.Logger)
@@ -53,10 +122,43 @@ func main() {
 
 	srv.router.Get("/api/health", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
 	srv.router.Get("/api/search", srv.handleSearch)
+	srv.router.Get("/api/sources", srv.handleSources)
+	srv.router.Get("/api/formats", srv.handleFormats)
 	srv.router.Get("/api/books/chapters", srv.handleChapters)
 	srv.router.Get("/api/chapter", srv.handleChapter)
 	srv.router.Get("/api/download", srv.handleDownload)
-	srv.router.Get("/api/progress", srv.handleProgress)
+	srv.router.Get("/api/download/report", srv.handleDownloadReport)
+	srv.router.Post("/api/jobs", srv.handleCreateJob)
+	srv.router.Get("/api/jobs", srv.handleListJobs)
+	srv.router.Get("/api/jobs/{id}", srv.handleGetJob)
+	srv.router.Delete("/api/jobs/{id}", srv.handleCancelJob)
+	srv.router.Get("/api/jobs/{id}/file", srv.handleJobFile)
+	srv.router.Get("/api/jobs/{id}/events", srv.handleJobEvents)
+	srv.router.Get("/api/library", srv.handleLibraryList)
+	srv.router.Post("/api/library", srv.handleLibraryAdd)
+	srv.router.Get("/api/library/{id}", srv.handleLibraryShow)
+	srv.router.Delete("/api/library/{id}", srv.handleLibraryRemove)
+	srv.router.Post("/api/library/{id}/update", srv.handleLibraryUpdate)
+	srv.router.Get("/api/library/{id}/subscription", srv.handleSubscriptionGet)
+	srv.router.Put("/api/library/{id}/subscription", srv.handleSubscribe)
+	srv.router.Delete("/api/library/{id}/subscription", srv.handleUnsubscribe)
+	srv.router.Post("/api/library/{id}/subscription/run", srv.handleSubscriptionRun)
+	srv.router.Get("/api/schedule", srv.handleScheduleList)
+	srv.router.Get("/api/webhooks", srv.handleWebhooks)
+	srv.router.Post("/api/webhooks/test", srv.handleWebhookTest)
+
+	// OPDS 1.2 目录（KOReader、Moon+ Reader 等阅读器）
+	srv.router.Get("/opds", srv.handleOPDSRoot)
+	srv.router.Get("/opds/recent", srv.handleOPDSRecent)
+	srv.router.Get("/opds/all", srv.handleOPDSAll)
+	srv.router.Get("/opds/authors", srv.handleOPDSAuthors)
+	srv.router.Get("/opds/author", srv.handleOPDSAuthor)
+	srv.router.Get("/opds/sources", srv.handleOPDSSources)
+	srv.router.Get("/opds/source", srv.handleOPDSSource)
+	srv.router.Get("/opds/search", srv.handleOPDSSearch)
+	srv.router.Get("/opds/fetch", srv.handleOPDSFetch)
+	srv.router.Get("/opds/opensearch.xml", srv.handleOPDSOpenSearch)
+	srv.router.Get("/opds/books/{id}/{format}", srv.handleOPDSFile)
 
 	fs := http.FileServer(http.Dir("./web/dist"))
 	srv.router.Handle("/*", fs)
@@ -64,6 +166,7 @@ func main() {
 	if err := srv.reloadSources(); err != nil {
 		log.Fatalf("load sources: %v", err)
 	}
+	srv.scheduler.Start(context.Background())
 
 	log.Println("listen :8080")
 	http.ListenAndServe(":8080", srv.router)
@@ -80,6 +183,7 @@ func (s *Server) reloadSources() error {
 		if err != nil {
 			return err
 		}
+		s.watchSource(sc)
 		out = append(out, sc)
 	}
 	s.sources = out
@@ -92,24 +196,66 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing q"})
 		return
 	}
+	sortBy := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("sort")))
+	if !search.ValidSort(sortBy) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sort"})
+		return
+	}
 
-	type row struct {
-		Title    string `json:"title"`
-		Author   string `json:"author"`
-		ID       string `json:"id"`
-		Source   string `json:"source"`
-		Category string `json:"category"`
-		Update   string `json:"update"`
-	}
-	var result []row
-	ctx := r.Context()
+	result, skipped := s.searchAll(r.Context(), q, sortBy)
+	writeJSON(w, http.StatusOK, map[string]any{"items": result, "skipped": skipped})
+}
+
+// searchAll 在全部书源中搜索并排序，返回结果与因熔断跳过的书源。
+func (s *Server) searchAll(ctx context.Context, q, sortBy string) (result []search.Result, skipped []string) {
 	for _, src := range s.sources {
+		// 熔断中的书源直接跳过，不再等待其重试
+		if !src.Available() {
+			skipped = append(skipped, src.Name())
+			continue
+		}
 		items, _ := src.Search(ctx, q, 1)
 		for _, it := range items {
-			result = append(result, row{Title: it.Title, Author: it.Author, ID: it.ID, Source: src.Name(), Category: it.Category, Update: it.Update})
+			result = append(result, search.Result{Book: it, Source: src.Name()})
+		}
+	}
+	search.Rank(q, result, sortBy)
+	return result, skipped
+}
+
+// handleSources 返回各书源的熔断状态与健康统计。
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	type row struct {
+		ID      string         `json:"id"`
+		Name    string         `json:"name"`
+		BaseURL string         `json:"baseUrl"`
+		Health  sources.Health `json:"health"`
+	}
+	out := make([]row, 0, len(s.sources))
+	for _, src := range s.sources {
+		out = append(out, row{ID: src.ID(), Name: src.Name(), BaseURL: src.BaseURL(), Health: src.Health()})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sources": out})
+}
+
+// handleFormats 列出已注册的导出格式及其选项，前端据此生成格式下拉框。
+func (s *Server) handleFormats(w http.ResponseWriter, r *http.Request) {
+	type row struct {
+		Name      string          `json:"name"`
+		Extension string          `json:"extension"`
+		MIMEType  string          `json:"mimeType"`
+		Options   []format.Option `json:"options"`
+	}
+	out := []row{}
+	for _, name := range format.Names() {
+		e, _ := format.Lookup(name)
+		opts := e.Options()
+		if opts == nil {
+			opts = []format.Option{}
 		}
+		out = append(out, row{Name: e.Name(), Extension: e.Extension(), MIMEType: e.MIMEType(), Options: opts})
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"items": result})
+	writeJSON(w, http.StatusOK, map[string]any{"formats": out, "defaults": exportOptions})
 }
 
 func (s *Server) handleChapters(w http.ResponseWriter, r *http.Request) {
@@ -118,7 +264,7 @@ func (s *Server) handleChapters(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing url"})
 		return
 	}
-	src := chooseSourceByURL(s.sources, u)
+	src := export.SourceForURL(s.sources, u)
 	if src == nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no source"})
 		return
@@ -152,7 +298,7 @@ func (s *Server) handleChapter(w http.ResponseWriter, r *http.Request) {
 	limit := atoi(r.URL.Query().Get("limit"), 1000)
 	full := r.URL.Query().Get("full") == "1"
 
-	src := chooseSourceByURL(s.sources, u)
+	src := export.SourceForURL(s.sources, u)
 	if src == nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no source"})
 		return
@@ -182,113 +328,155 @@ func (s *Server) handleChapter(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
-	u := strings.TrimSpace(r.URL.Query().Get("url"))
-	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
-	bookTitle := strings.TrimSpace(r.URL.Query().Get("title"))
-	bookAuthor := strings.TrimSpace(r.URL.Query().Get("author"))
-	if u == "" || (format != "txt" && format != "epub" && format != "pdf") {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing or invalid url/format"})
+	req := DownloadRequest{
+		URL:      strings.TrimSpace(r.URL.Query().Get("url")),
+		Format:   strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))),
+		Title:    strings.TrimSpace(r.URL.Query().Get("title")),
+		Author:   strings.TrimSpace(r.URL.Query().Get("author")),
+		Tolerant: r.URL.Query().Get("tolerant") == "1",
+	}
+	// 导出选项：option=key=value，可重复
+	opts, err := format.ParseOptions(r.URL.Query()["option"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-
-	src := chooseSourceByURL(s.sources, u)
-	if src == nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no source"})
+	if len(opts) > 0 {
+		req.Options = opts
+	}
+	if err := req.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	ctx := r.Context()
-	chs, err := src.Chapters(ctx, u, u)
+	// 同步下载也走任务队列：与其它下载共享并发上限，浏览器断开后任务继续，可通过 /api/jobs 取回
+	job, err := s.jobs.Submit(req)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
 		return
 	}
-	if len(chs) == 0 {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no chapters"})
+	select {
+	case <-job.done:
+	case <-r.Context().Done():
+		return
+	}
+	snap, _ := s.jobs.Get(job.ID)
+	if snap.Status != JobDone {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": snap.Error, "job": snap.ID})
 		return
 	}
 
-	type text struct{ Title, Content string }
-	out := make([]text, len(chs))
-
-	// 记录完成章节数和线程信息
-	var completed int32 = 0
-	var activeThreads int32 = 0
-	maxThreads := s.concurrency
-
-	// 创建进度通道
-	progress := make(chan int, 100)
-	defer close(progress)
-
-	// 启动进度推送协程
-	go func() {
-		for p := range progress {
-			percentage := float64(p) * 100 / float64(len(chs))
-			log.Printf("下载进度: %d/%d (%.1f%%)", p, len(chs), percentage)
-
-			// 推送进度到SSE
-			select {
-			case s.progressCh <- ProgressEvent{
-				TotalChapters: len(chs),
-				Completed:     p,
-				ActiveThreads: int(atomic.LoadInt32(&activeThreads)),
-				Percentage:    percentage,
-			}:
-			default:
-				// 如果通道满，丢弃旧消息
-			}
-		}
-	}()
-
-	sem := make(chan struct{}, s.concurrency)
-	g, gctx := errgroup.WithContext(ctx)
-
-	for i := range chs {
-		i := i
-		g.Go(func() error {
-			select {
-			case sem <- struct{}{}:
-				atomic.AddInt32(&activeThreads, 1)
-				log.Printf("线程启动: 当前活跃线程 %d/%d", atomic.LoadInt32(&activeThreads), maxThreads)
-			case <-gctx.Done():
-				return gctx.Err()
-			}
-			defer func() {
-				<-sem
-				atomic.AddInt32(&activeThreads, -1)
-				log.Printf("线程完成: 当前活跃线程 %d/%d", atomic.LoadInt32(&activeThreads), maxThreads)
-			}()
-
-			content, err := src.Content(gctx, chs[i])
-			if err != nil {
-				return err
-			}
-			out[i] = text{Title: chs[i].Title, Content: content}
+	res := snap.Result
+	w.Header().Set("X-Job-ID", snap.ID)
+	if res.Fallback > 0 {
+		w.Header().Set("X-Fallback-Chapters", strconv.Itoa(res.Fallback))
+	}
+	if req.Tolerant {
+		// 失败详情通过 /api/download/report?name=<文件名> 获取
+		w.Header().Set("X-Failed-Chapters", strconv.Itoa(len(res.Failures)))
+		w.Header().Set("X-Download-Name", res.Name)
+	}
+	w.Header().Set("Content-Type", res.MIMEType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+res.Name)
+	http.ServeFile(w, r, res.Path)
+}
 
-			// 更新进度（使用原子操作确保顺序）
-			current := atomic.AddInt32(&completed, 1)
-			select {
-			case progress <- int(current):
-			default:
-			}
+// DownloadRequest 描述一次整本下载。
+type DownloadRequest struct {
+	URL      string `json:"url"`
+	Format   string `json:"format"`
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Tolerant bool   `json:"tolerant,omitempty"`
+	// Options 是导出选项（见 /api/formats），覆盖服务端的默认选项
+	Options format.Options `json:"options,omitempty"`
+}
 
-			return nil
-		})
+func (req *DownloadRequest) validate() error {
+	e, ok := format.Lookup(req.Format)
+	if req.URL == "" || !ok {
+		return errors.New("missing or invalid url/format")
 	}
-	if err := g.Wait(); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+	opts := exportOptions.Merge(req.Options)
+	if err := format.Validate(e, opts); err != nil {
+		return err
+	}
+	// 下载接口只返回一个文件
+	if format.Splits(e, opts) {
+		return errors.New("option split is not supported by the web server, use the CLI")
+	}
+	return nil
+}
+
+// ErrOutputBusy 表示另一个任务正在写同一个输出文件（同一本书、同一格式）。
+var ErrOutputBusy = errors.New("another job is writing the same output file")
+
+// claimOutput 登记正在写入的输出文件并返回释放函数：同名文件同时只允许一个任务写入，
+// 否则两个任务会写坏同一个未完成文件，或互相覆盖对方的结果。
+func (s *Server) claimOutput(dst string) (release func(), err error) {
+	s.outputMu.Lock()
+	defer s.outputMu.Unlock()
+	if s.writing[dst] {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(dst), ErrOutputBusy)
+	}
+	s.writing[dst] = true
+	return func() {
+		s.outputMu.Lock()
+		delete(s.writing, dst)
+		s.outputMu.Unlock()
+	}, nil
+}
+
+// DownloadResult 是一次下载的产物。
+type DownloadResult struct {
+	Name     string           `json:"name"` // 输出文件名（位于 ./outputs）
+	Path     string           `json:"-"`
+	MIMEType string           `json:"mimeType"` // 按导出选项确定，如 TXT 的实际编码
+	Total    int              `json:"total"`
+	Failures []report.Failure `json:"failures,omitempty"` // 容错模式下仍失败的章节
+	Fallback int              `json:"fallbackChapters,omitempty"`
+}
+
+// runDownload 抓取整本书并导出到 ./outputs。
+func (s *Server) runDownload(ctx context.Context, req DownloadRequest, rep Reporter) (*DownloadResult, error) {
+	u, bookTitle, bookAuthor := req.URL, req.Title, req.Author
+	exp, ok := format.Lookup(req.Format)
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %s", req.Format)
+	}
+	src := export.SourceForURL(s.sources, u)
+	if src == nil {
+		return nil, errors.New("no source")
+	}
+
+	rep.Phase(PhaseChapters)
+	chs, err := src.Chapters(ctx, u, u)
+	if err != nil {
+		return nil, err
+	}
+	if len(chs) == 0 {
+		return nil, errors.New("no chapters")
 	}
 
+	// 每章抓到即落盘，同一本书再次下载时跳过已有章节
+	store, err := checkpoint.Open(s.workDir, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SaveManifest(checkpoint.Manifest{BookURL: u, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Chapters: chs}); err != nil {
+		return nil, err
+	}
+	s.recordBook(library.Book{URL: u, Source: src.Name(), Title: bookTitle, Author: bookAuthor, Chapters: len(chs)})
+
 	if err := os.MkdirAll("./outputs", 0o755); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
+		return nil, err
 	}
-	// 构建文件名: 渠道名_书名_作者.格式
-	if bookTitle == "" {
-		bookTitle = safeNameFromURL(u)
+	// 构建文件名: 渠道名_书名_作者.格式；没有书名时用 URL 最后一段，只用于文件名与导出元数据，备用书源仍按真实书名查找
+	title := bookTitle
+	if title == "" {
+		title = safeNameFromURL(u)
 	}
-	name := fmt.Sprintf("%s_%s_%s.%s", src.Name(), bookTitle, bookAuthor, format)
+	name := fmt.Sprintf("%s_%s_%s.%s", src.Name(), title, bookAuthor, exp.Extension())
 
 	// 清理文件名中的非法字符
 	name = strings.ReplaceAll(name, "/", "_")
@@ -302,74 +490,96 @@ func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
 	name = strings.ReplaceAll(name, "|", "_")
 
 	dst := filepath.Join("./outputs", name)
+	release, err := s.claimOutput(dst)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-	switch format {
-	case "txt":
-		conv := make([]ftxt.Chapter, len(out))
-		for i, c := range out {
-			conv[i] = struct{ Title, Content string }{Title: c.Title, Content: c.Content}
-		}
-		if err := ftxt.Save(dst, conv); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-			return
-		}
-	case "epub":
-		chapters := make([]fepub.Chapter, len(out))
-		for i, c := range out {
-			chapters[i] = fepub.Chapter{Title: c.Title, Content: c.Content}
+	// 支持流式导出的格式边下载边写文件，内存中不保留整本书
+	opts := exportOptions.Merge(req.Options)
+	file, err := export.Create(dst, exp, export.WithCover(ctx, export.Meta{Title: title, Author: bookAuthor, Source: src.Name(), URL: u}, exp, opts, store, src), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// 主书源失败或返回占位内容时，按书名+作者到其它书源找同一章
+	fb := sources.NewFallback(ctx, src, s.sources, bookTitle, bookAuthor)
+	initial, ceiling := src.Concurrency(s.concurrency)
+	rep.Phase(PhaseDownloading)
+	res, err := downloader.Run(ctx, chs, downloader.Options{
+		Source:             src.Name(),
+		Fetcher:            fb,
+		Concurrency:        ceiling,
+		InitialConcurrency: initial,
+		Tolerant:           req.Tolerant,
+		Store:              store,
+		Progress: func(e downloader.Event) {
+			if e.Kind == downloader.EventSkipped {
+				return
+			}
+			log.Printf("下载进度: %d/%d (%.1f%%) 活跃线程 %d/%d（上限 %d）", e.Completed, e.Total, e.Percentage, e.Active, e.Level, ceiling)
+			rep.Progress(e)
+		},
+	}, func(i int, t report.Text) error {
+		return export.WriteText(file, t)
+	})
+	if err != nil {
+		if part, perr := file.Interrupt(); perr == nil && part != "" {
+			log.Printf("下载中断，已下载的章节导出到 %s", part)
 		}
-		if err := fepub.Save(dst, fepub.Meta{Title: bookTitle, Author: bookAuthor}, chapters); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-			return
+		return nil, err
+	}
+	rep.Phase(PhaseExporting)
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	if len(res.Origins) > 0 {
+		if err := export.WriteOrigins(dst, src.Name(), res.Origins); err != nil {
+			return nil, err
 		}
-	case "pdf":
-		chapters := make([]fpdf.Chapter, len(out))
-		for i, c := range out {
-			chapters[i] = fpdf.Chapter{Title: c.Title, Content: c.Content}
+	}
+
+	s.recordExport(u, library.Export{Format: exp.Name(), Path: dst, Chapters: len(chs), Failed: len(res.Failures), Options: opts.For(exp)})
+	if req.Tolerant {
+		rep := &report.Report{
+			BookURL:   u,
+			Source:    src.Name(),
+			Title:     title,
+			Author:    bookAuthor,
+			Format:    exp.Name(),
+			Output:    dst,
+			Options:   opts.For(exp),
+			Total:     len(chs),
+			Failures:  res.Failures,
+			CreatedAt: time.Now(),
 		}
-		if err := fpdf.Save(dst, fpdf.Meta{Title: bookTitle, Author: bookAuthor}, chapters); err != nil {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-			return
+		if err := rep.Save(); err != nil {
+			return nil, err
 		}
 	}
-
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", "attachment; filename="+name)
-	http.ServeFile(w, r, dst)
+	return &DownloadResult{Name: name, Path: dst, MIMEType: format.MIMEType(exp, opts), Total: len(chs), Failures: res.Failures, Fallback: len(res.Origins)}, nil
 }
 
-func (s *Server) handleProgress(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
-	w.Header().Set("Access-Control-Expose-Headers", "Cache-Control")
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "SSE not supported", http.StatusInternalServerError)
+// handleDownloadReport 返回容错下载生成的失败报告（JSON）。
+func (s *Server) handleDownloadReport(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" || name != filepath.Base(name) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing or invalid name"})
 		return
 	}
-
-	// 发送初始消息
-	fmt.Fprintf(w, "event: connected\ndata: {\"message\":\"SSE connected\"}\n\n")
-	flusher.Flush()
-
-	for {
-		select {
-		case event := <-s.progressCh:
-			data, _ := json.Marshal(event)
-			fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
-			flusher.Flush()
-		case <-r.Context().Done():
+	rep, err := report.Load(report.Path(filepath.Join("./outputs", name)))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// 没有报告说明全部章节下载成功
+			writeJSON(w, http.StatusOK, map[string]any{"name": name, "failures": []report.Failure{}})
 			return
-		case <-time.After(30 * time.Second):
-			// 发送心跳保持连接
-			fmt.Fprintf(w, ": heartbeat\n\n")
-			flusher.Flush()
 		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
+	writeJSON(w, http.StatusOK, rep)
 }
 
 func writeJSON(w http.ResponseWriter, code int, v any) {
@@ -391,19 +601,15 @@ func atoi(s string, def int) int {
 	return def
 }
 
-func chooseSourceByURL(all []*sources.ConfigSource, u string) *sources.ConfigSource {
-	for _, s := range all {
-		if s == nil || u == "" {
-			continue
+// splitList 按逗号拆分并去掉空白项。
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
 		}
-		if strings.Contains(u, s.ID()) || strings.Contains(u, s.Name()) {
-			return s
-		}
-	}
-	if len(all) > 0 {
-		return all[0]
 	}
-	return nil
+	return out
 }
 
 func safeNameFromURL(u string) string {
@@ -438,7 +644,8 @@ func cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Disposition,X-Fallback-Chapters,X-Failed-Chapters,X-Download-Name,X-Job-ID")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
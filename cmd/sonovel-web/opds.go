@@ -0,0 +1,367 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/opds"
+	"github.com/sreio/go-novel/internal/search"
+)
+
+const opdsPageSize = 50
+
+// opdsBooks 返回书架上已有导出文件的书，按加入时间倒序。
+func (s *Server) opdsBooks() ([]library.Book, error) {
+	books, err := s.library.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []library.Book
+	for _, b := range books {
+		if len(b.Files()) > 0 {
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AddedAt.After(out[j].AddedAt) })
+	return out, nil
+}
+
+// opdsEntry 把书籍转换为带获取链接的 OPDS 条目，每种导出格式一个链接。
+func opdsEntry(b library.Book) opds.Entry {
+	files := b.Files()
+	e := opds.Entry{
+		Title:    b.Title,
+		ID:       "urn:go-novel:book:" + b.ID,
+		Updated:  b.UpdatedAt,
+		Language: "zh",
+		Summary:  "书源：" + b.Source + "，共 " + strconv.Itoa(b.Chapters) + " 章",
+	}
+	if e.Title == "" {
+		e.Title = b.URL
+	}
+	if b.Author != "" {
+		e.Authors = []opds.Author{{Name: b.Author, URI: "/opds/author?name=" + url.QueryEscape(b.Author)}}
+	}
+	if b.Category != "" {
+		e.Categories = []opds.Category{{Term: b.Category, Label: b.Category}}
+	}
+	for _, f := range files {
+		if f.CreatedAt.After(e.Updated) {
+			e.Updated = f.CreatedAt
+		}
+		e.Links = append(e.Links, opds.Link{
+			Rel:   opds.RelAcquisition,
+			Href:  "/opds/books/" + b.ID + "/" + f.Format,
+			Type:  opds.MimeType(f.Format, f.Options),
+			Title: strings.ToUpper(f.Format),
+		})
+	}
+	return e
+}
+
+// newOPDSFeed 创建带 start / search 链接的目录页。
+func newOPDSFeed(id, title, self, typ string) *opds.Feed {
+	f := opds.NewFeed("urn:go-novel:"+id, title, time.Now())
+	f.AddLink(opds.RelSelf, self, typ)
+	f.AddLink(opds.RelStart, "/opds", opds.TypeNavigation)
+	f.AddLink(opds.RelSearch, "/opds/opensearch.xml", opds.TypeOpenSearch)
+	return f
+}
+
+func writeFeed(w http.ResponseWriter, f *opds.Feed, typ string) {
+	w.Header().Set("Content-Type", typ)
+	_ = f.Write(w)
+}
+
+// writeBookFeed 分页输出书籍目录，?page= 从 1 开始。
+func writeBookFeed(w http.ResponseWriter, r *http.Request, id, title string, books []library.Book) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	q := r.URL.Query()
+	q.Del("page")
+	base := r.URL.Path
+	if enc := q.Encode(); enc != "" {
+		base += "?" + enc + "&"
+	} else {
+		base += "?"
+	}
+	f := newOPDSFeed(id, title, base+"page="+strconv.Itoa(page), opds.TypeAcquisition)
+	f.AddLink(opds.RelUp, "/opds", opds.TypeNavigation)
+	f.Paginate(len(books), page, opdsPageSize, opds.TypeAcquisition, func(p int) string { return base + "page=" + strconv.Itoa(p) })
+	start := min((page-1)*opdsPageSize, len(books))
+	end := min(start+opdsPageSize, len(books))
+	for _, b := range books[start:end] {
+		f.Entries = append(f.Entries, opdsEntry(b))
+	}
+	writeFeed(w, f, opds.TypeAcquisition)
+}
+
+// handleOPDSRoot 是目录入口：最近添加、按作者、按书源、全部书籍。
+func (s *Server) handleOPDSRoot(w http.ResponseWriter, r *http.Request) {
+	f := newOPDSFeed("root", "go-novel 书库", "/opds", opds.TypeNavigation)
+	now := time.Now()
+	nav := func(id, title, href, typ, rel, summary string) {
+		f.Entries = append(f.Entries, opds.Entry{
+			Title: title, ID: "urn:go-novel:" + id, Updated: now, Summary: summary,
+			Links: []opds.Link{{Rel: rel, Href: href, Type: typ}},
+		})
+	}
+	nav("recent", "最近添加", "/opds/recent", opds.TypeAcquisition, opds.RelSortNew, "按加入书架的时间倒序")
+	nav("authors", "按作者", "/opds/authors", opds.TypeNavigation, opds.RelSubsection, "按作者浏览")
+	nav("sources", "按书源", "/opds/sources", opds.TypeNavigation, opds.RelSubsection, "按书源浏览")
+	nav("all", "全部书籍", "/opds/all", opds.TypeAcquisition, opds.RelSubsection, "按书名排序")
+	writeFeed(w, f, opds.TypeNavigation)
+}
+
+func (s *Server) handleOPDSRecent(w http.ResponseWriter, r *http.Request) {
+	books, err := s.opdsBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBookFeed(w, r, "recent", "最近添加", books)
+}
+
+func (s *Server) handleOPDSAll(w http.ResponseWriter, r *http.Request) {
+	books, err := s.opdsBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(books, func(i, j int) bool { return books[i].Title < books[j].Title })
+	writeBookFeed(w, r, "all", "全部书籍", books)
+}
+
+// opdsGroups 按 key 分组输出导航目录，每组链接到 href?name=组名。
+func (s *Server) opdsGroups(w http.ResponseWriter, id, title, href string, key func(library.Book) string) {
+	books, err := s.opdsBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	count := make(map[string]int)
+	updated := make(map[string]time.Time)
+	for _, b := range books {
+		k := key(b)
+		count[k]++
+		if b.UpdatedAt.After(updated[k]) {
+			updated[k] = b.UpdatedAt
+		}
+	}
+	names := make([]string, 0, len(count))
+	for k := range count {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	f := newOPDSFeed(id, title, "/opds/"+id, opds.TypeNavigation)
+	f.AddLink(opds.RelUp, "/opds", opds.TypeNavigation)
+	for _, k := range names {
+		f.Entries = append(f.Entries, opds.Entry{
+			Title:   k,
+			ID:      "urn:go-novel:" + id + ":" + url.QueryEscape(k),
+			Updated: updated[k],
+			Summary: strconv.Itoa(count[k]) + " 本",
+			Links:   []opds.Link{{Rel: opds.RelSubsection, Href: href + "?name=" + url.QueryEscape(k), Type: opds.TypeAcquisition}},
+		})
+	}
+	writeFeed(w, f, opds.TypeNavigation)
+}
+
+// opdsFiltered 输出 key 等于 ?name= 的书籍。
+func (s *Server) opdsFiltered(w http.ResponseWriter, r *http.Request, id string, key func(library.Book) string) {
+	name := r.URL.Query().Get("name")
+	books, err := s.opdsBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var out []library.Book
+	for _, b := range books {
+		if key(b) == name {
+			out = append(out, b)
+		}
+	}
+	writeBookFeed(w, r, id+":"+url.QueryEscape(name), name, out)
+}
+
+func opdsAuthor(b library.Book) string {
+	if b.Author == "" {
+		return "未知作者"
+	}
+	return b.Author
+}
+
+func opdsSource(b library.Book) string {
+	if b.Source == "" {
+		return "未知书源"
+	}
+	return b.Source
+}
+
+func (s *Server) handleOPDSAuthors(w http.ResponseWriter, r *http.Request) {
+	s.opdsGroups(w, "authors", "按作者", "/opds/author", opdsAuthor)
+}
+
+func (s *Server) handleOPDSAuthor(w http.ResponseWriter, r *http.Request) {
+	s.opdsFiltered(w, r, "author", opdsAuthor)
+}
+
+func (s *Server) handleOPDSSources(w http.ResponseWriter, r *http.Request) {
+	s.opdsGroups(w, "sources", "按书源", "/opds/source", opdsSource)
+}
+
+func (s *Server) handleOPDSSource(w http.ResponseWriter, r *http.Request) {
+	s.opdsFiltered(w, r, "source", opdsSource)
+}
+
+// handleOPDSSearch 先列出书架中书名、作者匹配的书，再在全部书源中实时搜索；
+// 书源结果的获取链接指向 /opds/fetch，点击后创建下载任务，完成后返回 EPUB。
+func (s *Server) handleOPDSSearch(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimSpace(r.URL.Query().Get("q"))
+	f := newOPDSFeed("search:"+url.QueryEscape(raw), "搜索："+raw, "/opds/search?q="+url.QueryEscape(raw), opds.TypeAcquisition)
+	f.AddLink(opds.RelUp, "/opds", opds.TypeNavigation)
+	if raw == "" {
+		writeFeed(w, f, opds.TypeAcquisition)
+		return
+	}
+	books, err := s.opdsBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := strings.ToLower(raw)
+	byURL := make(map[string]library.Book)
+	listed := make(map[string]bool)
+	for _, b := range books {
+		byURL[b.URL] = b
+		if strings.Contains(strings.ToLower(b.Title), q) || strings.Contains(strings.ToLower(b.Author), q) {
+			f.Entries = append(f.Entries, opdsEntry(b))
+			listed[b.URL] = true
+		}
+	}
+	result, _ := s.searchAll(r.Context(), raw, "")
+	for _, it := range result {
+		if it.ID == "" || listed[it.ID] {
+			continue
+		}
+		listed[it.ID] = true
+		// 已下载过的书直接给出书架中的文件
+		if b, ok := byURL[it.ID]; ok {
+			f.Entries = append(f.Entries, opdsEntry(b))
+			continue
+		}
+		f.Entries = append(f.Entries, opdsRemoteEntry(it))
+	}
+	f.TotalResults = len(f.Entries)
+	writeFeed(w, f, opds.TypeAcquisition)
+}
+
+// opdsRemoteEntry 把书源搜索结果转换为 OPDS 条目，获取链接触发下载任务。
+func opdsRemoteEntry(it search.Result) opds.Entry {
+	e := opds.Entry{
+		Title:    it.Title,
+		ID:       "urn:go-novel:remote:" + library.ID(it.ID),
+		Updated:  time.Now(),
+		Language: "zh",
+	}
+	summary := []string{"书源：" + it.Source}
+	if it.Update != "" {
+		summary = append(summary, "更新："+it.Update)
+	}
+	e.Summary = strings.Join(summary, "，") + "（尚未下载，获取时在服务器上抓取，可能需要几分钟）"
+	if it.Author != "" {
+		e.Authors = []opds.Author{{Name: it.Author}}
+	}
+	if it.Category != "" {
+		e.Categories = []opds.Category{{Term: it.Category, Label: it.Category}}
+	}
+	v := url.Values{"url": {it.ID}, "title": {it.Title}, "author": {it.Author}}
+	e.Links = []opds.Link{{Rel: opds.RelAcquisition, Href: "/opds/fetch?" + v.Encode(), Type: opds.TypeEPUB, Title: "EPUB"}}
+	return e
+}
+
+// handleOPDSFetch 获取书源搜索结果的 EPUB：书架中已有导出时直接返回；
+// 否则创建（或复用进行中的）下载任务并等待完成。阅读器断开后任务继续执行，再次获取时直接返回结果。
+func (s *Server) handleOPDSFetch(w http.ResponseWriter, r *http.Request) {
+	req := DownloadRequest{
+		URL:      strings.TrimSpace(r.URL.Query().Get("url")),
+		Format:   "epub",
+		Title:    strings.TrimSpace(r.URL.Query().Get("title")),
+		Author:   strings.TrimSpace(r.URL.Query().Get("author")),
+		Tolerant: true,
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if b, err := s.library.Get(req.URL); err == nil {
+		for _, f := range b.Files() {
+			if f.Format == req.Format {
+				serveOPDSFile(w, r, opds.MimeType(f.Format, f.Options), f.Path)
+				return
+			}
+		}
+	}
+	job, err := s.jobs.SubmitOnce(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	select {
+	case <-job.done:
+	case <-r.Context().Done():
+		return
+	}
+	snap, _ := s.jobs.Get(job.ID)
+	if snap.Status != JobDone {
+		http.Error(w, "download "+snap.Status+": "+snap.Error, http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("X-Job-ID", snap.ID)
+	serveOPDSFile(w, r, snap.Result.MIMEType, snap.Result.Path)
+}
+
+func (s *Server) handleOPDSOpenSearch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", opds.TypeOpenSearch)
+	_ = opds.NewOpenSearch("go-novel", "搜索书架与全部书源", "/opds/search?q={searchTerms}").Write(w)
+}
+
+// handleOPDSFile 提供书籍某种格式的最新导出文件。
+func (s *Server) handleOPDSFile(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, library.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	format := chi.URLParam(r, "format")
+	for _, f := range b.Files() {
+		if f.Format != format {
+			continue
+		}
+		serveOPDSFile(w, r, opds.MimeType(format, f.Options), f.Path)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func serveOPDSFile(w http.ResponseWriter, r *http.Request, typ, path string) {
+	w.Header().Set("Content-Type", typ)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)}))
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sreio/go-novel/internal/export"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/scheduler"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// ScheduleEntry 是订阅状态及对应的书籍信息。
+type ScheduleEntry struct {
+	scheduler.Entry
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Source string `json:"source"`
+}
+
+// runScheduledUpdate 是调度器的执行函数：书源熔断中时跳过本次检查。
+func (s *Server) runScheduledUpdate(ctx context.Context, bookID string) (scheduler.Result, error) {
+	b, err := s.library.Get(bookID)
+	if err != nil {
+		return scheduler.Result{}, err
+	}
+	if src := export.SourceForBook(s.sources, b); src != nil && !src.Available() {
+		return scheduler.Result{}, sources.ErrCircuitOpen
+	}
+	u, refreshed, err := s.updateBook(ctx, b)
+	if err != nil {
+		return scheduler.Result{}, err
+	}
+	return scheduler.Result{Added: len(u.Added), Exports: len(refreshed)}, nil
+}
+
+// updateInterval 返回书籍的默认检查间隔：书源配置了 update_interval_minutes 时优先使用。
+func (s *Server) updateInterval(bookID string) time.Duration {
+	if b, err := s.library.Get(bookID); err == nil {
+		if src := export.SourceForBook(s.sources, b); src != nil && src.UpdateInterval() > 0 {
+			return src.UpdateInterval()
+		}
+	}
+	return s.defaultInterval
+}
+
+func (s *Server) scheduleEntry(e scheduler.Entry) ScheduleEntry {
+	out := ScheduleEntry{Entry: e}
+	if b, err := s.library.Get(e.BookID); err == nil {
+		out.Title, out.Author, out.Source = b.Title, b.Author, b.Source
+	}
+	return out
+}
+
+func (s *Server) handleScheduleList(w http.ResponseWriter, r *http.Request) {
+	entries := s.scheduler.List()
+	out := make([]ScheduleEntry, len(entries))
+	for i, e := range entries {
+		out[i] = s.scheduleEntry(e)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entries": out})
+}
+
+func (s *Server) handleSubscriptionGet(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	e, ok := s.scheduler.Get(b.ID)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": scheduler.ErrNotSubscribed.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.scheduleEntry(e))
+}
+
+// handleSubscribe 订阅书籍的定时更新，请求体 {"intervalMinutes": N}，省略或 0 时使用书源/全局默认间隔。
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	var req struct {
+		IntervalMinutes int `json:"intervalMinutes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json body"})
+			return
+		}
+	}
+	if req.IntervalMinutes < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid intervalMinutes"})
+		return
+	}
+	e, err := s.scheduler.Subscribe(b.ID, time.Duration(req.IntervalMinutes)*time.Minute)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.scheduleEntry(e))
+}
+
+func (s *Server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	if err := s.scheduler.Unsubscribe(b.ID); err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSubscriptionRun 立即检查一次更新（在后台执行，之后按间隔继续）。
+func (s *Server) handleSubscriptionRun(w http.ResponseWriter, r *http.Request) {
+	b, err := s.library.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		writeLibraryError(w, err)
+		return
+	}
+	e, err := s.scheduler.RunNow(b.ID)
+	if err != nil {
+		writeScheduleError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.scheduleEntry(e))
+}
+
+func writeScheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, scheduler.ErrNotSubscribed) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// unsubscribeRemoved 在书籍移出书架后取消其订阅。
+func (s *Server) unsubscribeRemoved(b library.Book) {
+	if err := s.scheduler.Unsubscribe(b.ID); err != nil && !errors.Is(err, scheduler.ErrNotSubscribed) {
+		log.Printf("取消订阅失败: %v", err)
+	}
+}
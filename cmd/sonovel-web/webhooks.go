@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/sources"
+	"github.com/sreio/go-novel/internal/webhook"
+)
+
+// WebhookStatus 是 webhook 配置及最近一次投递结果。
+type WebhookStatus struct {
+	webhook.Hook
+	Last *webhook.Delivery `json:"last,omitempty"`
+}
+
+// notifyJob 在下载任务结束时发送 download.finished / download.failed（取消的任务不通知）。
+func (s *Server) notifyJob(j Job) {
+	switch j.Status {
+	case JobDone:
+		s.webhooks.Emit(webhook.EventDownloadFinished, j)
+	case JobFailed:
+		s.webhooks.Emit(webhook.EventDownloadFailed, j)
+	}
+}
+
+// notifyChapters 在连载更新发现新章节时发送 chapters.new。
+func (s *Server) notifyChapters(u *library.Update) {
+	if len(u.Added) == 0 {
+		return
+	}
+	s.webhooks.Emit(webhook.EventChaptersNew, map[string]any{
+		"book":   u.Book,
+		"before": u.Before,
+		"after":  u.After,
+		"added":  u.Added,
+	})
+}
+
+// watchSource 在书源由正常转为熔断时发送 source.unhealthy；半开探测失败重新熔断不重复通知。
+func (s *Server) watchSource(src *sources.ConfigSource) {
+	src.OnStateChange(func(from, to string) {
+		if from != sources.StateClosed || to != sources.StateOpen {
+			return
+		}
+		s.webhooks.Emit(webhook.EventSourceUnhealthy, map[string]any{
+			"id":      src.ID(),
+			"name":    src.Name(),
+			"baseUrl": src.BaseURL(),
+			"from":    from,
+			"health":  src.Health(),
+		})
+	})
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	out := []WebhookStatus{}
+	for _, h := range s.webhooks.Hooks() {
+		st := WebhookStatus{Hook: h}
+		if d, ok := s.webhooks.Last(h.Name); ok {
+			st.Last = &d
+		}
+		out = append(out, st)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"webhooks": out})
+}
+
+// handleWebhookTest 同步发送 ping（?name= 指定 hook，省略时发送给全部），返回每个 hook 的投递结果。
+func (s *Server) handleWebhookTest(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	res := s.webhooks.Test(r.Context(), name)
+	if len(res) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no webhook configured"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"deliveries": res})
+}
@@ -4,10 +4,9 @@ go 1.25
 
 require (
 	github.com/PuerkitoBio/goquery v1.9.2
-	github.com/bmaupin/go-epub v1.1.0
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/spf13/cobra v1.8.0
-	golang.org/x/sync v0.16.0
+	golang.org/x/image v0.30.0
 	golang.org/x/text v0.28.0
 	golang.org/x/time v0.6.0
 	gopkg.in/yaml.v3 v3.0.1
@@ -15,12 +14,9 @@ require (
 
 require (
 	github.com/andybalholm/cascadia v1.3.2 // indirect
-	github.com/gabriel-vasile/mimetype v1.3.1 // indirect
-	github.com/gofrs/uuid v3.1.0+incompatible // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/jung-kurt/gofpdf v1.16.2
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/vincent-petithory/dataurl v0.0.0-20191104211930-d1553a71de50 // indirect
 )
 
 require golang.org/x/net v0.43.0 // indirect
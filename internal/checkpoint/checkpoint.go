@@ -0,0 +1,143 @@
+package checkpoint
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// Manifest 描述一本书的下载进度：书籍信息与完整章节目录。
+type Manifest struct {
+	BookURL   string            `json:"bookUrl"`
+	Source    string            `json:"source"`
+	Title     string            `json:"title"`
+	Author    string            `json:"author"`
+	Chapters  []sources.Chapter `json:"chapters"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Store 是一本书的检查点目录：
+//
+//	<root>/<hash(bookURL)>/manifest.json
+//	<root>/<hash(bookURL)>/chapters/<hash(chapterURL)>.txt
+//	<root>/<hash(bookURL)>/cover（封面图片，可选）
+//
+// 每章正文抓到后立即落盘，重新运行时跳过已存在的章节。
+type Store struct {
+	dir string
+}
+
+// Open 打开（必要时创建）bookURL 对应的检查点目录。
+func Open(root, bookURL string) (*Store, error) {
+	dir := Dir(root, bookURL)
+	if err := os.MkdirAll(filepath.Join(dir, "chapters"), 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir 返回 bookURL 对应的检查点目录路径。
+func Dir(root, bookURL string) string {
+	return filepath.Join(root, key(bookURL))
+}
+
+// Path 返回检查点目录。
+func (s *Store) Path() string { return s.dir }
+
+// SaveManifest 写入书籍信息与章节目录。
+func (s *Store) SaveManifest(m Manifest) error {
+	m.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, "manifest.json"), b)
+}
+
+// LoadManifest 读取书籍信息与章节目录。
+func (s *Store) LoadManifest() (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(filepath.Join(s.dir, "manifest.json"))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+// Has 判断章节正文是否已落盘。
+func (s *Store) Has(chapterURL string) bool {
+	_, err := os.Stat(s.chapterPath(chapterURL))
+	return err == nil
+}
+
+// Get 读取已落盘的章节正文；不存在时返回 fs.ErrNotExist。
+func (s *Store) Get(chapterURL string) (string, error) {
+	b, err := os.ReadFile(s.chapterPath(chapterURL))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Put 落盘章节正文（先写临时文件再改名，中断时不会留下半章）。
+func (s *Store) Put(chapterURL, content string) error {
+	return writeFileAtomic(s.chapterPath(chapterURL), []byte(content))
+}
+
+// Missing 返回目录中尚未落盘的章节下标。
+func (s *Store) Missing(chs []sources.Chapter) []int {
+	var out []int
+	for i, c := range chs {
+		if !s.Has(c.URL) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// SaveCover 缓存书籍封面（已规范化为 JPEG/PNG），重新导出或更新时不必再次请求书源。
+func (s *Store) SaveCover(b []byte) error {
+	return writeFileAtomic(filepath.Join(s.dir, "cover"), b)
+}
+
+// LoadCover 读取缓存的封面；不存在时返回 fs.ErrNotExist。
+func (s *Store) LoadCover() ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, "cover"))
+}
+
+// IsNotExist 判断错误是否为检查点/章节不存在。
+func IsNotExist(err error) bool { return errors.Is(err, fs.ErrNotExist) }
+
+func (s *Store) chapterPath(chapterURL string) string {
+	return filepath.Join(s.dir, "chapters", key(chapterURL)+".txt")
+}
+
+func key(s string) string {
+	h := sha1.Sum([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
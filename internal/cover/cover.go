@@ -0,0 +1,262 @@
+// Package cover 处理书籍封面：规范化书源提供的图片，没有封面时用书名与作者在本地生成。
+package cover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // 注册解码器
+	"image/png"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp" // 注册解码器
+)
+
+// 生成封面的尺寸（3:4）
+const (
+	Width  = 600
+	Height = 800
+)
+
+// Format 返回封面数据的格式：jpeg、png，无法识别时返回空串。
+func Format(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	}
+	return ""
+}
+
+// Normalize 校验封面图片：JPEG、PNG 原样返回，GIF、WebP 等转为 PNG（EPUB 阅读器与 PDF 都支持这两种格式）。
+func Normalize(data []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if b := img.Bounds(); b.Dx() < 50 || b.Dy() < 50 {
+		return nil, errors.New("cover image too small") // 站点的占位小图
+	}
+	if Format(data) != "" {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Resolve 返回书籍封面：检查点中缓存的封面 > fetch 从书源获取的封面（获取后缓存）> 用书名与作者生成。
+// fetch 可为 nil（只有检查点时）；书源封面获取失败只记录日志。fontPath 为中文字体，见 fonts.Find；
+// 需要生成封面而 fontPath 为空时返回 ErrNoFont。
+func Resolve(ctx context.Context, store *checkpoint.Store, fetch func(context.Context) ([]byte, error), title, author, fontPath string) ([]byte, error) {
+	if store != nil {
+		if b, err := store.LoadCover(); err == nil {
+			return b, nil
+		}
+	}
+	if fetch != nil {
+		b, err := fetch(ctx)
+		if err == nil {
+			b, err = Normalize(b)
+		}
+		if err == nil {
+			if store != nil {
+				if err := store.SaveCover(b); err != nil {
+					log.Printf("保存封面失败: %v", err)
+				}
+			}
+			return b, nil
+		}
+		log.Printf("获取封面失败，使用生成的封面: %v", err)
+	}
+	return Generate(title, author, fontPath)
+}
+
+// ErrNoFont 表示生成封面时没有可用的中文字体；西文字体显示不了中文书名，不能代替。
+var ErrNoFont = errors.New("no CJK font for the generated cover, set --font or NOVEL_FONT")
+
+// Generate 生成 PNG 封面：按书名取色的渐变底色、细边框，居中排列书名与作者。
+// fontPath 为中文字体，为空时返回 ErrNoFont。
+func Generate(title, author, fontPath string) ([]byte, error) {
+	f, err := loadFont(fontPath)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+
+	// 底色：色相由书名决定，同一本书的封面保持一致
+	h := fnv.New32a()
+	h.Write([]byte(title))
+	hue := float64(h.Sum32()%360) / 360
+	top, bottom := hsl(hue, 0.45, 0.38), hsl(hue, 0.55, 0.16)
+	for y := 0; y < Height; y++ {
+		t := float64(y) / Height
+		c := color.RGBA{lerp(top.R, bottom.R, t), lerp(top.G, bottom.G, t), lerp(top.B, bottom.B, t), 255}
+		draw.Draw(img, image.Rect(0, y, Width, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	light := color.RGBA{240, 230, 210, 255}
+	frame(img, 28, 2, light)
+	frame(img, 38, 1, light)
+
+	// 书名：逐步缩小字号直到最多四行放得下
+	maxW := Width - 2*70
+	var lines []string
+	var face font.Face
+	size := 72.0
+	for ; ; size -= 4 {
+		if face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull}); err != nil {
+			return nil, err
+		}
+		if lines = wrap(face, title, maxW); len(lines) <= 4 || size <= 32 {
+			break
+		}
+	}
+	if len(lines) > 4 {
+		lines = lines[:4]
+		rs := []rune(lines[3])
+		lines[3] = string(rs[:len(rs)-1]) + "…"
+	}
+	lineH := int(size * 1.35)
+	y := 300 - len(lines)*lineH/2 + int(size)
+	for _, l := range lines {
+		text(img, face, l, y, light)
+		y += lineH
+	}
+	// 书名下的分隔线
+	y += lineH/2 - int(size)
+	draw.Draw(img, image.Rect(Width/2-60, y, Width/2+60, y+2), image.NewUniform(light), image.Point{}, draw.Src)
+
+	if author = strings.TrimSpace(author); author != "" {
+		af, err := opentype.NewFace(f, &opentype.FaceOptions{Size: 30, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		if al := wrap(af, author, maxW); len(al) > 0 {
+			text(img, af, al[0], Height-130, light)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// loadFont 解析字体文件（TTF/OTF，或 TTC 中的第一个字体）。
+func loadFont(path string) (*opentype.Font, error) {
+	if path == "" {
+		return nil, ErrNoFont
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := opentype.ParseCollection(b)
+	if err != nil {
+		return nil, fmt.Errorf("font %s: %w", path, err)
+	}
+	if c.NumFonts() == 0 {
+		return nil, fmt.Errorf("font %s: no fonts in file", path)
+	}
+	return c.Font(0)
+}
+
+// wrap 按宽度折行：中文逐字断行，西文尽量在空格处断开；字体中没有的字符跳过。
+func wrap(face font.Face, s string, maxW int) []string {
+	var lines []string
+	var cur []rune
+	width := func(rs []rune) int { return font.MeasureString(face, string(rs)).Ceil() }
+	for _, r := range strings.TrimSpace(s) {
+		if r == '\n' {
+			continue
+		}
+		if _, ok := face.GlyphAdvance(r); !ok {
+			continue
+		}
+		cur = append(cur, r)
+		if width(cur) <= maxW {
+			continue
+		}
+		// 超宽：西文单词整体移到下一行
+		cut := len(cur) - 1
+		if r != ' ' && r < 0x2E80 {
+			for i := len(cur) - 2; i > 0; i-- {
+				if cur[i] == ' ' {
+					cut = i + 1
+					break
+				}
+			}
+		}
+		lines = append(lines, strings.TrimSpace(string(cur[:cut])))
+		cur = append([]rune(nil), cur[cut:]...)
+	}
+	if s := strings.TrimSpace(string(cur)); s != "" {
+		lines = append(lines, s)
+	}
+	return lines
+}
+
+// text 在基线 y 处水平居中绘制一行文字，带轻微阴影。
+func text(img draw.Image, face font.Face, s string, y int, c color.Color) {
+	w := font.MeasureString(face, s)
+	x := (fixed.I(Width) - w) / 2
+	shadow := &font.Drawer{Dst: img, Src: image.NewUniform(color.RGBA{0, 0, 0, 110}), Face: face, Dot: fixed.Point26_6{X: x + fixed.I(2), Y: fixed.I(y + 2)}}
+	shadow.DrawString(s)
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.Point26_6{X: x, Y: fixed.I(y)}}
+	d.DrawString(s)
+}
+
+// frame 绘制距边缘 inset、宽 w 的矩形边框。
+func frame(img draw.Image, inset, w int, c color.Color) {
+	u := image.NewUniform(c)
+	r := image.Rect(inset, inset, Width-inset, Height-inset)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+w), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-w, r.Max.X, r.Max.Y), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+w, r.Max.Y), u, image.Point{}, draw.Over)
+	draw.Draw(img, image.Rect(r.Max.X-w, r.Min.Y, r.Max.X, r.Max.Y), u, image.Point{}, draw.Over)
+}
+
+func lerp(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// hsl 把 HSL（均为 0~1）转换为 RGB。
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := h * 6
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g = c, x
+	case hp < 2:
+		r, g = x, c
+	case hp < 3:
+		g, b = c, x
+	case hp < 4:
+		g, b = x, c
+	case hp < 5:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := l - c/2
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
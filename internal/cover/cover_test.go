@@ -0,0 +1,42 @@
+package cover
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 没有可用的中文字体时不生成封面，而不是用西文字体画出空白的书名
+func TestGenerateFont(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.ttf")
+	if err := os.WriteFile(bad, []byte("not a font"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		wantErr error
+	}{
+		{"no font", "", ErrNoFont},
+		{"missing file", filepath.Join(t.TempDir(), "missing.ttf"), os.ErrNotExist},
+		{"not a font", bad, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Generate("测试之书", "某作者", tt.path)
+			if err == nil || b != nil {
+				t.Fatalf("Generate() = %d bytes, %v, want an error", len(b), err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Generate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolveNoFont(t *testing.T) {
+	if _, err := Resolve(t.Context(), nil, nil, "测试之书", "某作者", ""); !errors.Is(err, ErrNoFont) {
+		t.Fatalf("Resolve() error = %v, want ErrNoFont", err)
+	}
+}
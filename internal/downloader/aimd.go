@@ -0,0 +1,86 @@
+package downloader
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+const (
+	// 两次减半之间的最短间隔，避免同一波 503 把并发一路砍到底
+	cutCooldown = 2 * time.Second
+	// 成功请求延迟超过基线的倍数时视为站点变慢
+	latencyFactor = 3
+	// 延迟低于该值时不因延迟减速
+	latencyFloor = time.Second
+)
+
+// aimd 按“加性增、乘性减”调整并发度：每个健康响应让并发增加 1/level（约每轮 +1），
+// 遇到 429/5xx/超时或延迟明显升高时减半。与书源 HTTPClient 的 rate.Limiter 配合：
+// 限速器约束每秒请求数，aimd 约束同时在途的请求数。
+type aimd struct {
+	mu      sync.Mutex
+	level   float64
+	max     float64
+	lastCut time.Time
+	ewma    time.Duration // 成功请求延迟的指数滑动平均
+	base    time.Duration // 观察到的最低 ewma，作为延迟基线
+}
+
+func newAIMD(initial, max int) *aimd {
+	if max < 1 {
+		max = 1
+	}
+	if initial < 1 || initial > max {
+		initial = max
+	}
+	return &aimd{level: float64(initial), max: float64(max)}
+}
+
+// Level 返回当前允许的并发数。
+func (a *aimd) Level() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.level)
+}
+
+// observe 接收每一次 HTTP 尝试的结果。
+func (a *aimd) observe(o sources.Observation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if o.Overloaded() {
+		a.cut()
+		return
+	}
+	if o.Err != nil {
+		return
+	}
+	if a.ewma == 0 {
+		a.ewma = o.Latency
+	} else {
+		a.ewma = (a.ewma*7 + o.Latency) / 8
+	}
+	if a.base == 0 || a.ewma < a.base {
+		a.base = a.ewma
+	}
+	if a.ewma > latencyFloor && a.ewma > a.base*latencyFactor {
+		a.cut()
+		return
+	}
+	a.level += 1 / a.level
+	if a.level > a.max {
+		a.level = a.max
+	}
+}
+
+func (a *aimd) cut() {
+	if time.Since(a.lastCut) < cutCooldown {
+		return
+	}
+	a.lastCut = time.Now()
+	a.level /= 2
+	if a.level < 1 {
+		a.level = 1
+	}
+}
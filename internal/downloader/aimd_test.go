@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+func TestAIMD(t *testing.T) {
+	ok := func(d time.Duration) sources.Observation { return sources.Observation{Status: 200, Latency: d} }
+	repeat := func(o sources.Observation, n int) []sources.Observation {
+		out := make([]sources.Observation, n)
+		for i := range out {
+			out[i] = o
+		}
+		return out
+	}
+	tests := []struct {
+		name         string
+		initial, max int
+		obs          []sources.Observation
+		want         int
+	}{
+		{"initial defaults to max", 0, 8, nil, 8},
+		{"initial capped at max", 20, 8, nil, 8},
+		{"503 halves", 8, 8, []sources.Observation{{Status: 503}}, 4},
+		{"429 halves", 6, 8, []sources.Observation{{Status: 429}}, 3},
+		{"timeout halves", 8, 8, []sources.Observation{{Err: context.DeadlineExceeded}}, 4},
+		{"cooldown between cuts", 8, 8, []sources.Observation{{Status: 503}, {Status: 502}, {Status: 429}}, 4},
+		{"never below one", 1, 8, []sources.Observation{{Status: 503}}, 1},
+		{"other errors ignored", 4, 8, []sources.Observation{{Err: errors.New("parse error")}}, 4},
+		{"404 is healthy", 2, 8, []sources.Observation{{Status: 404, Latency: 50 * time.Millisecond}}, 2},
+		{"additive increase", 2, 8, repeat(ok(100*time.Millisecond), 4), 3},
+		{"capped at max", 1, 4, repeat(ok(100*time.Millisecond), 100), 4},
+		{"latency spike halves", 8, 8, append(repeat(ok(100*time.Millisecond), 3), ok(5*time.Second), ok(5*time.Second)), 4},
+		{"slow but under floor", 8, 8, append(repeat(ok(10*time.Millisecond), 3), repeat(ok(900*time.Millisecond), 10)...), 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAIMD(tt.initial, tt.max)
+			for _, o := range tt.obs {
+				a.observe(o)
+			}
+			if got := a.Level(); got != tt.want {
+				t.Errorf("Level() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// 进度事件类型
+const (
+	EventDone    = "done"    // 章节抓取成功
+	EventSkipped = "skipped" // 检查点中已有，跳过
+	EventRetry   = "retry"   // 抓取失败，稍后重试
+	EventFailed  = "failed"  // 重试后仍失败，写入占位内容
+)
+
+// maxAttempts 是容错模式下每章的最多尝试次数（首次 + 末尾重试一次）
+const maxAttempts = 2
+
+// Fetcher 抓取单章正文，from 为实际提供正文的书源名称。sources.Fallback 实现了该接口。
+type Fetcher interface {
+	Content(ctx context.Context, ch sources.Chapter) (content, from string, err error)
+}
+
+// Event 是一次进度更新。
+type Event struct {
+	Kind       string  `json:"kind"`
+	Index      int     `json:"index"`
+	Title      string  `json:"title"`
+	Error      string  `json:"error,omitempty"`
+	Total      int     `json:"totalChapters"`
+	Completed  int     `json:"completed"`
+	Failed     int     `json:"failed"`
+	Active     int     `json:"activeThreads"`
+	Level      int     `json:"concurrency"` // 当前自适应并发度
+	Percentage float64 `json:"percentage"`
+}
+
+// Options 控制一次下载。
+type Options struct {
+	Source             string            // 主书源名称，用于判断章节是否来自备用书源
+	Fetcher            Fetcher           // 章节抓取
+	Concurrency        int               // 并发上限（工作协程数），默认 8
+	InitialConcurrency int               // 起步并发，默认等于 Concurrency；之后按站点响应自适应调整
+	Window             int               // 已完成但尚未按序交付的章节上限（背压），默认 Concurrency*4
+	Tolerant           bool              // 容错：失败章节稍后重试，仍失败则写入占位内容
+	Store              *checkpoint.Store // 可选：已落盘章节直接复用，新抓取的章节立即落盘
+	Progress           func(Event)       // 可选：进度回调，在单个协程中串行调用
+}
+
+// Result 汇总一次下载。
+type Result struct {
+	Failures []report.Failure
+	Origins  []sources.ChapterOrigin
+}
+
+// Sink 按章节顺序接收正文；为 nil 时只抓取并落盘到 Options.Store。
+type Sink func(i int, t report.Text) error
+
+type job struct {
+	i       int
+	attempt int
+}
+
+type outcome struct {
+	i       int
+	attempt int
+	content string
+	from    string
+	err     error
+}
+
+// Run 用固定大小的工作池抓取章节，并按目录顺序把正文交给 sink。
+// 交付游标之后最多允许 Window 章处于抓取或待交付状态，超出时暂停派发新章节。
+func Run(ctx context.Context, chs []sources.Chapter, opt Options, sink Sink) (*Result, error) {
+	if opt.Fetcher == nil {
+		return nil, errors.New("downloader: no fetcher")
+	}
+	workers := opt.Concurrency
+	if workers <= 0 {
+		workers = 8
+	}
+	window := opt.Window
+	if window <= 0 {
+		window = workers * 4
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ctl := newAIMD(opt.InitialConcurrency, workers)
+	fetchCtx := sources.WithObserver(ctx, ctl.observe)
+
+	var active int32
+	jobs := make(chan job)
+	results := make(chan outcome)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				atomic.AddInt32(&active, 1)
+				content, from, err := opt.Fetcher.Content(fetchCtx, chs[j.i])
+				if err == nil && opt.Store != nil {
+					err = opt.Store.Put(chs[j.i].URL, content)
+				}
+				atomic.AddInt32(&active, -1)
+				select {
+				case results <- outcome{i: j.i, attempt: j.attempt, content: content, from: from, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		cancel()
+		wg.Wait()
+	}()
+
+	d := &dispatch{
+		chs:     chs,
+		opt:     opt,
+		sink:    sink,
+		active:  &active,
+		ctl:     ctl,
+		ready:   make(map[int]report.Text),
+		cached:  make(map[int]bool),
+		res:     &Result{},
+		pending: make([]int, 0, len(chs)),
+	}
+	for i, c := range chs {
+		if opt.Store != nil && opt.Store.Has(c.URL) {
+			d.cached[i] = true
+			d.completed++
+			d.emit(Event{Kind: EventSkipped, Index: i, Title: c.Title})
+			continue
+		}
+		d.pending = append(d.pending, i)
+	}
+
+	next := 0 // pending 中下一个待派发的位置
+	inflight := 0
+	var retries []job
+	for {
+		if err := d.flush(); err != nil {
+			return d.res, err
+		}
+		if d.cursor == len(chs) {
+			return d.res, nil
+		}
+
+		// 选出下一个要派发的任务：窗口未满时派发新章节，否则（或新章节已派完）派发重试；
+		// 在途请求数达到当前自适应并发度时暂不派发
+		var send chan job
+		var j job
+		windowFull := next < len(d.pending) && d.pending[next]-d.cursor >= window
+		switch {
+		case inflight >= ctl.Level():
+		case next < len(d.pending) && !windowFull:
+			send, j = jobs, job{i: d.pending[next], attempt: 1}
+		case len(retries) > 0:
+			send, j = jobs, retries[0]
+		}
+		if send == nil && inflight == 0 {
+			return d.res, fmt.Errorf("downloader: stalled at chapter %d", d.cursor)
+		}
+
+		select {
+		case send <- j:
+			inflight++
+			if j.attempt == 1 {
+				next++
+			} else {
+				retries = retries[1:]
+			}
+		case o := <-results:
+			inflight--
+			ch := chs[o.i]
+			if o.err == nil {
+				d.ready[o.i] = report.Text{Title: ch.Title, Content: o.content}
+				d.completed++
+				if o.from != "" && o.from != opt.Source {
+					d.res.Origins = append(d.res.Origins, sources.ChapterOrigin{Index: o.i, Title: ch.Title, URL: ch.URL, Source: o.from})
+				}
+				d.emit(Event{Kind: EventDone, Index: o.i, Title: ch.Title})
+				continue
+			}
+			if ctx.Err() != nil {
+				return d.res, ctx.Err()
+			}
+			if !opt.Tolerant {
+				return d.res, fmt.Errorf("chapter %d (%s): %w", o.i+1, ch.URL, o.err)
+			}
+			if o.attempt < maxAttempts {
+				retries = append(retries, job{i: o.i, attempt: o.attempt + 1})
+				d.emit(Event{Kind: EventRetry, Index: o.i, Title: ch.Title, Error: o.err.Error()})
+				continue
+			}
+			f := report.Failure{Index: o.i, Title: ch.Title, URL: ch.URL, Error: o.err.Error()}
+			d.res.Failures = append(d.res.Failures, f)
+			d.ready[o.i] = report.Text{Title: ch.Title, Content: report.Placeholder(f)}
+			d.failed++
+			d.emit(Event{Kind: EventFailed, Index: o.i, Title: ch.Title, Error: o.err.Error()})
+		case <-ctx.Done():
+			return d.res, ctx.Err()
+		}
+	}
+}
+
+// dispatch 保存 Run 主循环的交付状态。
+type dispatch struct {
+	chs     []sources.Chapter
+	opt     Options
+	sink    Sink
+	active  *int32
+	ctl     *aimd
+	ready   map[int]report.Text // 已完成、等待按序交付的章节
+	cached  map[int]bool        // 检查点中已有的章节，交付时再读盘
+	pending []int               // 需要抓取的章节下标（升序）
+	res     *Result
+
+	cursor    int // 下一个要交付的章节
+	completed int
+	failed    int
+}
+
+// flush 把游标处已就绪的章节按顺序交给 sink。
+func (d *dispatch) flush() error {
+	for d.cursor < len(d.chs) {
+		i := d.cursor
+		ch := d.chs[i]
+		var t report.Text
+		switch {
+		case d.cached[i]:
+			delete(d.cached, i)
+			if d.sink == nil {
+				break // 只需落盘时不必读回
+			}
+			content, err := d.opt.Store.Get(ch.URL)
+			if err != nil {
+				return err
+			}
+			t = report.Text{Title: ch.Title, Content: content}
+		default:
+			var ok bool
+			if t, ok = d.ready[i]; !ok {
+				return nil
+			}
+			delete(d.ready, i)
+		}
+		if d.sink != nil {
+			if err := d.sink(i, t); err != nil {
+				return err
+			}
+		}
+		d.cursor++
+	}
+	return nil
+}
+
+func (d *dispatch) emit(e Event) {
+	if d.opt.Progress == nil {
+		return
+	}
+	e.Total = len(d.chs)
+	e.Completed = d.completed
+	e.Failed = d.failed
+	e.Active = int(atomic.LoadInt32(d.active))
+	e.Level = d.ctl.Level()
+	if e.Total > 0 {
+		e.Percentage = float64(d.completed+d.failed) * 100 / float64(e.Total)
+	}
+	d.opt.Progress(e)
+}
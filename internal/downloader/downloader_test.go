@@ -0,0 +1,246 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+type fetchFunc func(ctx context.Context, ch sources.Chapter) (string, string, error)
+
+func (f fetchFunc) Content(ctx context.Context, ch sources.Chapter) (string, string, error) {
+	return f(ctx, ch)
+}
+
+func chapters(n int) []sources.Chapter {
+	chs := make([]sources.Chapter, n)
+	for i := range chs {
+		chs[i] = sources.Chapter{Title: fmt.Sprintf("第%d章", i+1), URL: fmt.Sprintf("https://example.com/%d", i), Index: i}
+	}
+	return chs
+}
+
+func content(ch sources.Chapter) string { return ch.Title + "正文" }
+
+// 后面的章节先抓完，交付仍按目录顺序；在途请求不超过并发上限，检查点中已有的章节直接读盘
+func TestRunOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		chapters    int
+		concurrency int
+		window      int
+		cached      []int
+	}{
+		{"serial", 20, 1, 0, nil},
+		{"parallel", 60, 8, 0, nil},
+		{"small window", 60, 8, 3, nil},
+		{"with checkpoint", 40, 4, 0, []int{0, 1, 7, 20, 39}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chs := chapters(tt.chapters)
+			store, err := checkpoint.Open(t.TempDir(), "https://example.com/book")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, i := range tt.cached {
+				if err := store.Put(chs[i].URL, content(chs[i])); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var cur, peak int32
+			fetch := fetchFunc(func(ctx context.Context, ch sources.Chapter) (string, string, error) {
+				n := atomic.AddInt32(&cur, 1)
+				defer atomic.AddInt32(&cur, -1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Duration(tt.chapters-ch.Index) * 50 * time.Microsecond)
+				return content(ch), "primary", nil
+			})
+
+			var got []int
+			skipped := 0
+			_, err = Run(context.Background(), chs, Options{
+				Source:      "primary",
+				Fetcher:     fetch,
+				Concurrency: tt.concurrency,
+				Window:      tt.window,
+				Store:       store,
+				Progress: func(e Event) {
+					if e.Kind == EventSkipped {
+						skipped++
+					}
+				},
+			}, func(i int, txt report.Text) error {
+				if want := content(chs[i]); txt.Content != want || txt.Title != chs[i].Title {
+					t.Errorf("chapter %d = %+v, want content %q", i, txt, want)
+				}
+				got = append(got, i)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.chapters {
+				t.Fatalf("delivered %d chapters, want %d", len(got), tt.chapters)
+			}
+			for i, idx := range got {
+				if idx != i {
+					t.Fatalf("delivery order = %v", got)
+				}
+			}
+			if peak > int32(tt.concurrency) {
+				t.Errorf("peak concurrency = %d, want <= %d", peak, tt.concurrency)
+			}
+			if skipped != len(tt.cached) {
+				t.Errorf("skipped = %d, want %d", skipped, len(tt.cached))
+			}
+			for _, ch := range chs {
+				if !store.Has(ch.URL) {
+					t.Errorf("chapter %s not saved to the checkpoint", ch.URL)
+				}
+			}
+		})
+	}
+}
+
+// failN 返回前 n 次抓取 url 时失败的抓取器。
+func failN(n map[string]int) Fetcher {
+	var mu sync.Mutex
+	return fetchFunc(func(ctx context.Context, ch sources.Chapter) (string, string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if n[ch.URL] > 0 {
+			n[ch.URL]--
+			return "", "", errors.New("boom")
+		}
+		return content(ch), "primary", nil
+	})
+}
+
+func TestRunRetry(t *testing.T) {
+	chs := chapters(5)
+	tests := []struct {
+		name         string
+		fails        map[string]int
+		tolerant     bool
+		wantErr      bool
+		wantRetries  int
+		wantFailures []int
+	}{
+		{"strict fails fast", map[string]int{chs[2].URL: 1}, false, true, 0, nil},
+		{"retry succeeds", map[string]int{chs[1].URL: 1, chs[3].URL: 1}, true, false, 2, nil},
+		{"retry exhausted", map[string]int{chs[2].URL: maxAttempts}, true, false, 1, []int{2}},
+		{"all attempts fail", map[string]int{chs[0].URL: 9, chs[4].URL: 9}, true, false, 2, []int{0, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retries := 0
+			texts := make(map[int]report.Text)
+			res, err := Run(context.Background(), chs, Options{
+				Fetcher:     failN(tt.fails),
+				Concurrency: 2,
+				Tolerant:    tt.tolerant,
+				Progress: func(e Event) {
+					if e.Kind == EventRetry {
+						retries++
+					}
+				},
+			}, func(i int, txt report.Text) error {
+				texts[i] = txt
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run: err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if retries != tt.wantRetries {
+				t.Errorf("retries = %d, want %d", retries, tt.wantRetries)
+			}
+			var failed []int
+			for _, f := range res.Failures {
+				failed = append(failed, f.Index)
+				if texts[f.Index].Content != report.Placeholder(f) {
+					t.Errorf("chapter %d content = %q, want placeholder", f.Index, texts[f.Index].Content)
+				}
+			}
+			slices.Sort(failed) // 按完成顺序记录
+			if !slices.Equal(failed, tt.wantFailures) {
+				t.Errorf("failures = %v, want %v", failed, tt.wantFailures)
+			}
+			if len(texts) != len(chs) {
+				t.Errorf("delivered %d chapters, want %d", len(texts), len(chs))
+			}
+		})
+	}
+}
+
+// 书源返回占位内容的章节记为失败：不写入检查点，下次下载会重新抓取
+func TestRunPlaceholder(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ch/1" {
+			fmt.Fprint(w, `<div class="content"><p>章节内容正在手打中</p></div>`)
+			return
+		}
+		fmt.Fprint(w, `<div class="content"><p>正文`+r.URL.Path+`</p></div>`)
+	}))
+	defer site.Close()
+	src, err := sources.NewFromConfig(sources.SourceConfig{
+		ID: "site", Name: "site", BaseURL: site.URL,
+		Search:   sources.SearchConfig{Path: "/search", Param: "q", ItemSelector: "li", TitleSelector: "a", LinkSelector: "a"},
+		Chapters: sources.ChaptersConfig{ListSelector: "li", TitleSelector: "a", URLSelector: "a"},
+		Content:  sources.ContentConfig{ContentSelector: ".content p"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chs := []sources.Chapter{{Title: "第1章", URL: site.URL + "/ch/0"}, {Title: "第2章", URL: site.URL + "/ch/1"}, {Title: "第3章", URL: site.URL + "/ch/2"}}
+	store, err := checkpoint.Open(t.TempDir(), site.URL+"/book")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	texts := make(map[int]report.Text)
+	res, err := Run(context.Background(), chs, Options{
+		Source:   "site",
+		Fetcher:  sources.NewFallback(context.Background(), src, []*sources.ConfigSource{src}, "测试之书", ""),
+		Tolerant: true,
+		Store:    store,
+	}, func(i int, txt report.Text) error {
+		texts[i] = txt
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Index != 1 || !strings.Contains(res.Failures[0].Error, sources.ErrPlaceholder.Error()) {
+		t.Fatalf("failures = %+v, want chapter 1 as placeholder", res.Failures)
+	}
+	if texts[1].Content != report.Placeholder(res.Failures[0]) {
+		t.Errorf("chapter 1 content = %q, want the failure placeholder", texts[1].Content)
+	}
+	if store.Has(chs[1].URL) {
+		t.Error("placeholder chapter was saved to the checkpoint")
+	}
+	if !store.Has(chs[0].URL) || !store.Has(chs[2].URL) {
+		t.Error("downloaded chapters missing from the checkpoint")
+	}
+}
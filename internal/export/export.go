@@ -0,0 +1,119 @@
+// Package export 把下载的章节组装成书并写出导出文件，命令行与 Web 服务共用：
+// 书籍元数据与封面、按章写入、备用书源记录，以及连载更新后刷新已有导出。
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/cover"
+	"github.com/sreio/go-novel/internal/fonts"
+	"github.com/sreio/go-novel/internal/format"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// Meta 是写入导出文件的书籍信息。
+type Meta struct {
+	Title, Author string
+	Source        string // 书源名称，作为 publisher
+	URL           string // 书籍详情页，用于生成稳定的 identifier
+	Cover         []byte // 封面，见 WithCover
+}
+
+// NewBook 组装导出用的书（不含章节）：identifier 由书籍 URL 生成，重新导出或更新后阅读器仍识别为同一本书。
+func NewBook(m Meta) *format.Book {
+	b := &format.Book{Meta: format.Meta{Title: m.Title, Author: m.Author, Language: "zh", Publisher: m.Source}}
+	if m.URL != "" {
+		b.Identifier = "urn:go-novel:" + library.ID(m.URL)
+		b.Description = "来源：" + m.URL
+	}
+	if len(m.Cover) > 0 {
+		b.Cover = &format.Image{Name: "cover", MIME: http.DetectContentType(m.Cover), Data: m.Cover}
+	}
+	b.Volumes = []format.Volume{{}}
+	return b
+}
+
+// WithCover 为使用封面的格式（EPUB/PDF 等）准备封面：检查点缓存 > 书源封面 > 生成封面，
+// 生成封面时使用 opts 中的 font 选项，找不到中文字体时不带封面。store、src 可为 nil；封面失败只记录日志，不影响导出。
+func WithCover(ctx context.Context, m Meta, e format.Exporter, opts format.Options, store *checkpoint.Store, src *sources.ConfigSource) Meta {
+	if !format.UsesCover(e) {
+		return m
+	}
+	var fetch func(context.Context) ([]byte, error)
+	if src != nil && m.URL != "" {
+		fetch = func(ctx context.Context) ([]byte, error) { return src.Cover(ctx, m.URL) }
+	}
+	b, err := cover.Resolve(ctx, store, fetch, m.Title, m.Author, fonts.Find(opts["font"]))
+	if errors.Is(err, cover.ErrNoFont) {
+		log.Printf("没有可用的中文字体，不生成封面（可用 --font 或 NOVEL_FONT 指定）")
+		return m
+	}
+	if err != nil {
+		log.Printf("生成封面失败: %v", err)
+		return m
+	}
+	m.Cover = b
+	return m
+}
+
+// Create 开始导出一本书，章节随后通过 WriteText 按顺序写入。
+func Create(dst string, e format.Exporter, m Meta, opts format.Options) (*format.File, error) {
+	return format.Create(dst, e, NewBook(m), opts)
+}
+
+// WriteText 把抓取到的一章写入导出文件。
+func WriteText(f *format.File, t report.Text) error {
+	return f.WriteChapter(format.NewChapter(t.Title, t.Content))
+}
+
+// WriteOrigins 在输出文件旁写入 <文件名>.sources.json，记录哪些章节来自备用书源。
+func WriteOrigins(dst, primary string, origins []sources.ChapterOrigin) error {
+	sort.Slice(origins, func(i, j int) bool { return origins[i].Index < origins[j].Index })
+	b, err := json.MarshalIndent(map[string]any{"primary": primary, "chapters": origins}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst+".sources.json", b, 0o644)
+}
+
+// SourceForURL 按书籍 URL 选择书源：URL 包含书源名称或 ID 即命中，名称较长的优先（避免短名误中）；
+// 都不命中时返回第一个书源。
+func SourceForURL(all []*sources.ConfigSource, u string) *sources.ConfigSource {
+	ss := make([]*sources.ConfigSource, 0, len(all))
+	for _, s := range all {
+		if s != nil {
+			ss = append(ss, s)
+		}
+	}
+	if len(ss) == 0 {
+		return nil
+	}
+	first := ss[0]
+	sort.SliceStable(ss, func(i, j int) bool { return len(ss[i].Name()) > len(ss[j].Name()) })
+	for _, s := range ss {
+		if u != "" && (strings.Contains(u, s.Name()) || strings.Contains(u, s.ID())) {
+			return s
+		}
+	}
+	return first
+}
+
+// SourceForBook 优先使用书架记录的书源，找不到时按 URL 选择。
+func SourceForBook(all []*sources.ConfigSource, b library.Book) *sources.ConfigSource {
+	for _, s := range all {
+		if s != nil && s.Name() == b.Source {
+			return s
+		}
+	}
+	return SourceForURL(all, b.URL)
+}
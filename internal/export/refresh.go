@@ -0,0 +1,97 @@
+package export
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sreio/go-novel/internal/format"
+	ftxt "github.com/sreio/go-novel/internal/format/txt"
+	"github.com/sreio/go-novel/internal/library"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// 刷新导出文件的方式
+const (
+	ModeAppend     = "append"     // 追加到 TXT 末尾
+	ModeRegenerate = "regenerate" // 重新生成整个文件
+)
+
+// Refreshed 是一次更新中刷新的导出文件。
+type Refreshed struct {
+	Format string `json:"format"`
+	Path   string `json:"path"`
+	Mode   string `json:"mode"` // ModeAppend 或 ModeRegenerate
+}
+
+// Refresh 按连载更新的结果刷新这本书已有的导出文件，并把新的章节数记录到书架。
+// 每个导出沿用当时记录的选项（编码、拆分等），与 defaults 合并，追加或重新生成的内容与原文件保持一致。
+// src 用于获取封面，可为 nil。
+func Refresh(ctx context.Context, lib *library.Library, u *library.Update, src *sources.ConfigSource, defaults format.Options) ([]Refreshed, error) {
+	refreshed := []Refreshed{}
+	if !u.Changed() {
+		return refreshed, nil
+	}
+	store, err := lib.Checkpoint(u.Book)
+	if err != nil {
+		return refreshed, err
+	}
+	meta := Meta{Title: u.Book.Title, Author: u.Book.Author, Source: u.Book.Source, URL: u.Book.URL}
+	for _, e := range u.Targets() {
+		exp, ok := format.Lookup(e.Format)
+		if !ok {
+			return refreshed, fmt.Errorf("%s: unknown format: %s", e.Path, e.Format)
+		}
+		opts := defaults.Merge(e.Options)
+		mode := ModeRegenerate
+		if u.CanAppend(e) && ftxt.Appendable(opts) {
+			mode = ModeAppend
+			err = appendTXT(e.Path, u, opts)
+		} else {
+			err = regenerate(ctx, e.Path, exp, WithCover(ctx, meta, exp, opts, store, src), u, opts)
+		}
+		if err != nil {
+			return refreshed, fmt.Errorf("%s: %w", e.Path, err)
+		}
+		if err := lib.AddExport(u.Book.URL, library.Export{Format: e.Format, Path: e.Path, Chapters: u.After, Failed: len(u.Failures), Options: e.Options}); err != nil {
+			return refreshed, err
+		}
+		refreshed = append(refreshed, Refreshed{Format: e.Format, Path: e.Path, Mode: mode})
+	}
+	return refreshed, nil
+}
+
+// appendTXT 把本次抓取的章节追加到 TXT 末尾。
+func appendTXT(path string, u *library.Update, opts format.Options) error {
+	chs := make([]format.Chapter, 0, len(u.Fetched))
+	for _, i := range u.Fetched {
+		t, err := u.Text(i)
+		if err != nil {
+			return err
+		}
+		chs = append(chs, format.NewChapter(t.Title, t.Content))
+	}
+	// 书源目录没有分卷，导出的 TXT 只有一个无名卷，新章节接在末尾
+	return ftxt.Append(path, []format.Volume{{Chapters: chs}}, ftxt.Position{Chapters: u.Before}, opts)
+}
+
+// regenerate 从检查点逐章读出整本书重新导出；流式格式写完才替换原文件，出错时原文件保持不变。
+func regenerate(ctx context.Context, path string, e format.Exporter, m Meta, u *library.Update, opts format.Options) error {
+	f, err := Create(path, e, m, opts)
+	if err != nil {
+		return err
+	}
+	for i := range u.Chapters {
+		t, err := u.Text(i)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = WriteText(f, t)
+		}
+		if err != nil {
+			f.Abort()
+			return err
+		}
+	}
+	return f.Close()
+}
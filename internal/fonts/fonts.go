@@ -0,0 +1,146 @@
+// Package fonts 查找本机的中文字体，供封面生成与 PDF 导出使用。
+package fonts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvVar 是指定中文字体文件的环境变量。
+const EnvVar = "NOVEL_FONT"
+
+// candidates 是常见系统自带或包管理器安装的中文字体。
+var candidates = []string{
+	// Linux
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-zenhei.ttc",
+	"/usr/share/fonts/wqy-microhei/wqy-microhei.ttc",
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	// macOS
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Medium.ttc",
+	"/Library/Fonts/Arial Unicode.ttf",
+	// Windows
+	`C:\Windows\Fonts\msyh.ttc`,
+	`C:\Windows\Fonts\simhei.ttf`,
+	`C:\Windows\Fonts\simsun.ttc`,
+}
+
+// Find 返回可用的中文字体路径：explicit（命令行参数）> 环境变量 NOVEL_FONT > 常见系统字体；都没有时返回空串。
+func Find(explicit string) string {
+	for _, p := range paths(explicit) {
+		if st, err := os.Stat(p); err == nil && !st.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// ErrNotFound 表示没有找到可以嵌入 PDF 的中文字体。
+var ErrNotFound = errors.New("no TrueType CJK font found, set --font or NOVEL_FONT")
+
+// TrueType 按 Find 的顺序返回第一个可以嵌入 PDF 的字体及其数据。PDF 只支持 TrueType 轮廓（glyf），
+// TTC 取其中第一个字体并转换为单独的 TTF；CFF 轮廓的 OpenType（如 Noto Sans CJK 的 .otf/.ttc）会被跳过。
+func TrueType(explicit string) (string, []byte, error) {
+	var errs []error
+	for _, p := range paths(explicit) {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		ttf, err := toTrueType(b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p, err))
+			continue
+		}
+		return p, ttf, nil
+	}
+	return "", nil, errors.Join(append([]error{ErrNotFound}, errs...)...)
+}
+
+// paths 返回按优先级排列、去掉空值的候选路径。
+func paths(explicit string) []string {
+	var out []string
+	for _, p := range append([]string{explicit, os.Getenv(EnvVar)}, candidates...) {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// toTrueType 校验字体是 TrueType 轮廓；TTC 时抽出第一个字体，重新排列表数据生成独立的 TTF。
+func toTrueType(b []byte) ([]byte, error) {
+	if len(b) < 12 {
+		return nil, errors.New("font file too short")
+	}
+	if string(b[:4]) != "ttcf" {
+		if err := checkTables(b, 0); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+	if binary.BigEndian.Uint32(b[8:]) == 0 || len(b) < 16 {
+		return nil, errors.New("empty font collection")
+	}
+	off := int(binary.BigEndian.Uint32(b[12:]))
+	if err := checkTables(b, off); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint16(b[off+4:]))
+	dir := b[off : off+12+16*n]
+	out := make([]byte, 12+16*n)
+	copy(out, dir[:12])
+	for i := 0; i < n; i++ {
+		rec := dir[12+16*i : 12+16*(i+1)]
+		start, length := int(binary.BigEndian.Uint32(rec[8:])), int(binary.BigEndian.Uint32(rec[12:]))
+		r := out[12+16*i : 12+16*(i+1)]
+		copy(r, rec)
+		binary.BigEndian.PutUint32(r[8:], uint32(len(out)))
+		out = append(out, b[start:start+length]...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out, nil
+}
+
+// checkTables 检查 off 处的表目录：版本必须是 TrueType，包含 glyf 表且各表都在文件范围内。
+func checkTables(b []byte, off int) error {
+	if off < 0 || off+12 > len(b) {
+		return errors.New("invalid font offset")
+	}
+	switch v := string(b[off : off+4]); v {
+	case "\x00\x01\x00\x00", "true":
+	case "OTTO":
+		return errors.New("CFF (PostScript) outlines are not supported")
+	default:
+		return fmt.Errorf("not a TrueType font: %q", v)
+	}
+	n := int(binary.BigEndian.Uint16(b[off+4:]))
+	if off+12+16*n > len(b) {
+		return errors.New("truncated table directory")
+	}
+	glyf := false
+	for i := 0; i < n; i++ {
+		rec := b[off+12+16*i:]
+		start, length := int(binary.BigEndian.Uint32(rec[8:])), int(binary.BigEndian.Uint32(rec[12:]))
+		if start+length > len(b) || start+length < start {
+			return fmt.Errorf("table %q out of range", rec[:4])
+		}
+		glyf = glyf || string(rec[:4]) == "glyf"
+	}
+	if !glyf {
+		return errors.New("no glyf table (not TrueType outlines)")
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package all 引入全部导出格式，使其注册到 format 注册表。新增格式时在这里加一行。
+package all
+
+import (
+	_ "github.com/sreio/go-novel/internal/format/epub"
+	_ "github.com/sreio/go-novel/internal/format/fb2"
+	_ "github.com/sreio/go-novel/internal/format/html"
+	_ "github.com/sreio/go-novel/internal/format/markdown"
+	_ "github.com/sreio/go-novel/internal/format/mobi"
+	_ "github.com/sreio/go-novel/internal/format/pdf"
+	_ "github.com/sreio/go-novel/internal/format/txt"
+)
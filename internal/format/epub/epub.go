@@ -1,20 +1,301 @@
 package epub
 
 import (
+    "archive/zip"
+    "crypto/rand"
     "fmt"
-    e "github.com/bmaupin/go-epub"
+    "html"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/sreio/go-novel/internal/format"
 )
 
-type Meta struct { Title, Author string }
-type Chapter struct { Title, Content string }
+func init() {
+    format.Register(Exporter{})
+    format.RegisterImporter(Importer{})
+}
+
+// Exporter 导出 EPUB 3：中文排版样式表、封面、按卷分级的目录，章节正文按段落转义。
+// 章节边到达边写入 zip，内存中只保留目录与清单，中断时仍会写出目录生成可以打开的不完整 EPUB。
+type Exporter struct{}
+
+func (Exporter) Name() string             { return "epub" }
+func (Exporter) Extension() string        { return "epub" }
+func (Exporter) MIMEType() string         { return "application/epub+zip" }
+func (Exporter) Options() []format.Option { return nil }
+func (Exporter) UsesCover() bool          { return true }
+
+// 中文排版：两端对齐、首行缩进两字、较宽行距，标点按严格规则避头尾
+const stylesheet = `body { margin: 0 0.5em; line-height: 1.8; text-align: justify; font-family: "Noto Serif CJK SC", "Source Han Serif SC", "Songti SC", "SimSun", serif; line-break: strict; -epub-line-break: strict; word-break: normal; }
+h1 { margin: 1em 0 1.5em; font-size: 1.3em; line-height: 1.4; font-weight: bold; text-align: center; text-indent: 0; page-break-after: avoid; }
+p { margin: 0 0 0.4em; text-indent: 2em; }
+p.img { text-indent: 0; text-align: center; }
+p.img img { max-width: 100%; }
+div.cover { margin: 0; padding: 0; text-align: center; }
+div.cover img { max-width: 100%; max-height: 100%; }
+`
+
+const container = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="EPUB/package.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// Export 生成 EPUB。Identifier 为空时随机生成，Language 默认 zh。
+func (x Exporter) Export(w io.Writer, b *format.Book, opts format.Options) error {
+    cw, err := x.NewWriter(w, opts)
+    if err != nil { return err }
+    return format.WriteBook(cw, b)
+}
+
+// NewWriter 返回流式写入器，写入顺序与 EPUB 要求一致：mimetype 排第一且不压缩。
+func (Exporter) NewWriter(w io.Writer, _ format.Options) (format.ChapterWriter, error) {
+    return &writer{zw: zip.NewWriter(w)}, nil
+}
+
+// item 是 package.opf 清单中的一项
+type item struct { id, href, mime, props string }
+
+// entry 是目录中的一项；分卷的章节挂在 children 下
+type entry struct {
+    title, href string
+    children    []entry
+}
+
+type writer struct {
+    zw       *zip.Writer
+    meta     format.Meta
+    manifest []item
+    spine    []string
+    toc      []entry
+    inVolume bool
+    sections int
+    chapters int
+    images   int
+    finished bool
+}
+
+func (x *writer) Begin(b *format.Book) error {
+    x.meta = b.Meta
+    if x.meta.Language == "" { x.meta.Language = "zh" }
+    if x.meta.Identifier == "" { x.meta.Identifier = "urn:uuid:" + uuid() }
+    if x.meta.Title == "" { x.meta.Title = "未命名" }
+    f, err := x.zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: time.Now()})
+    if err != nil { return err }
+    if _, err := io.WriteString(f, "application/epub+zip"); err != nil { return err }
+    if err := x.file("META-INF/container.xml", container); err != nil { return err }
+    if err := x.add("css", "css/book.css", "text/css", "", stylesheet); err != nil { return err }
+    if b.Cover == nil || len(b.Cover.Data) == 0 { return nil }
+    href := "images/cover" + imageExt(b.Cover.MIME)
+    if err := x.add("cover-image", href, b.Cover.MIME, "cover-image", string(b.Cover.Data)); err != nil { return err }
+    body := `<div class="cover"><img src="../` + href + `" alt="` + esc(x.meta.Title) + `"/></div>` + "\n"
+    if err := x.add("cover", "xhtml/cover.xhtml", "application/xhtml+xml", "", page(x.meta, x.meta.Title, "../css/book.css", body)); err != nil { return err }
+    x.spine = append(x.spine, "cover")
+    return nil
+}
 
-func Save(path string, meta Meta, chapters []Chapter) error {
-    book := e.NewEpub(meta.Title)
-    if meta.Author != "" { book.SetAuthor(meta.Author) }
-    for _, ch := range chapters {
-        // 简单章节包装；可根据需要加样式
-        _, err := book.AddSection(fmt.Sprintf("<h1>%s</h1><p>%s</p>", ch.Title, ch.Content), ch.Title, "", "")
-        if err != nil { return err }
+// BeginVolume 写入卷名页，卷名作为一级目录，之后的章节挂在卷下。
+func (x *writer) BeginVolume(title string) error {
+    href, err := x.section("<h1>" + esc(title) + "</h1>\n", title)
+    if err != nil { return err }
+    x.toc = append(x.toc, entry{title: title, href: href})
+    x.inVolume = true
+    return nil
+}
+
+func (x *writer) WriteChapter(ch format.Chapter) error {
+    x.chapters++
+    // 目录（nav / ncx）使用章节标题，空标题按序号命名
+    title := strings.TrimSpace(ch.Title)
+    if title == "" { title = fmt.Sprintf("第 %d 章", x.chapters) }
+    body, err := chapterBody(title, ch.Paragraphs, x.addImage)
+    if err != nil { return err }
+    href, err := x.section(body, title)
+    if err != nil { return err }
+    e := entry{title: title, href: href}
+    if x.inVolume {
+        v := &x.toc[len(x.toc)-1]
+        v.children = append(v.children, e)
+    } else {
+        x.toc = append(x.toc, e)
     }
-    return book.Write(path)
+    return nil
+}
+
+// Finish 写出目录与 package.opf 并关闭 zip；重复调用无效果。
+func (x *writer) Finish() error {
+    if x.finished { return nil }
+    x.finished = true
+    if err := x.file("EPUB/nav.xhtml", x.nav()); err != nil { return err }
+    x.manifest = append(x.manifest, item{"nav", "nav.xhtml", "application/xhtml+xml", "nav"})
+    if err := x.file("EPUB/toc.ncx", x.ncx()); err != nil { return err }
+    x.manifest = append(x.manifest, item{"ncx", "toc.ncx", "application/x-dtbncx+xml", ""})
+    if err := x.file("EPUB/package.opf", x.opf()); err != nil { return err }
+    return x.zw.Close()
+}
+
+// section 写入一个正文页面并加入清单与书脊，返回相对 EPUB 目录的路径。
+func (x *writer) section(body, title string) (string, error) {
+    x.sections++
+    id := fmt.Sprintf("section%04d", x.sections)
+    href := "xhtml/" + id + ".xhtml"
+    if err := x.add(id, href, "application/xhtml+xml", "", page(x.meta, title, "../css/book.css", body)); err != nil { return "", err }
+    x.spine = append(x.spine, id)
+    return href, nil
+}
+
+// addImage 写入正文插图，返回章节页面引用它的相对路径。
+func (x *writer) addImage(im *format.Image) (string, error) {
+    x.images++
+    id := fmt.Sprintf("img%04d", x.images)
+    href := "images/" + id + imageExt(im.MIME)
+    if err := x.add(id, href, im.MIME, "", string(im.Data)); err != nil { return "", err }
+    return "../" + href, nil
+}
+
+func (x *writer) add(id, href, mime, props, data string) error {
+    if err := x.file("EPUB/"+href, data); err != nil { return err }
+    x.manifest = append(x.manifest, item{id, href, mime, props})
+    return nil
+}
+
+func (x *writer) file(name, data string) error {
+    f, err := x.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+    if err != nil { return err }
+    _, err = io.WriteString(f, data)
+    return err
+}
+
+func (x *writer) nav() string {
+    var b strings.Builder
+    b.WriteString(`<nav epub:type="toc" id="toc"><h1>目录</h1>` + "\n")
+    var list func([]entry, string)
+    list = func(es []entry, indent string) {
+        b.WriteString(indent + "<ol>\n")
+        for _, e := range es {
+            b.WriteString(indent + `  <li><a href="` + e.href + `">` + esc(e.title) + "</a>")
+            if len(e.children) > 0 { b.WriteString("\n"); list(e.children, indent+"    "); b.WriteString(indent + "  ") }
+            b.WriteString("</li>\n")
+        }
+        b.WriteString(indent + "</ol>\n")
+    }
+    if len(x.toc) > 0 { list(x.toc, "") }
+    b.WriteString("</nav>\n")
+    return page(x.meta, "目录", "css/book.css", b.String())
+}
+
+func (x *writer) ncx() string {
+    var b strings.Builder
+    b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+<head><meta name="dtb:uid" content="` + esc(x.meta.Identifier) + `"/></head>
+<docTitle><text>` + esc(x.meta.Title) + `</text></docTitle>
+<navMap>
+`)
+    n := 0
+    var points func([]entry)
+    points = func(es []entry) {
+        for _, e := range es {
+            n++
+            fmt.Fprintf(&b, `<navPoint id="navPoint-%d" playOrder="%d"><navLabel><text>%s</text></navLabel><content src="%s"/>`, n, n, esc(e.title), e.href)
+            b.WriteString("\n")
+            points(e.children)
+            b.WriteString("</navPoint>\n")
+        }
+    }
+    points(x.toc)
+    b.WriteString("</navMap>\n</ncx>\n")
+    return b.String()
+}
+
+func (x *writer) opf() string {
+    m := x.meta
+    var b strings.Builder
+    b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="pub-id" xml:lang="` + esc(m.Language) + `">
+<metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:identifier id="pub-id">` + esc(m.Identifier) + `</dc:identifier>
+<dc:title>` + esc(m.Title) + `</dc:title>
+<dc:language>` + esc(m.Language) + "</dc:language>\n")
+    if m.Author != "" { b.WriteString("<dc:creator>" + esc(m.Author) + "</dc:creator>\n") }
+    if m.Description != "" { b.WriteString("<dc:description>" + esc(m.Description) + "</dc:description>\n") }
+    if m.Publisher != "" { b.WriteString("<dc:publisher>" + esc(m.Publisher) + "</dc:publisher>\n") }
+    b.WriteString(`<meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + "</meta>\n")
+    for _, it := range x.manifest {
+        // EPUB 2 阅读器通过 <meta name="cover"> 识别封面
+        if it.props == "cover-image" { b.WriteString(`<meta name="cover" content="` + it.id + `"/>` + "\n") }
+    }
+    b.WriteString("</metadata>\n<manifest>\n")
+    for _, it := range x.manifest {
+        props := ""
+        if it.props != "" { props = ` properties="` + it.props + `"` }
+        b.WriteString(`<item id="` + it.id + `" href="` + it.href + `" media-type="` + it.mime + `"` + props + "/>\n")
+    }
+    b.WriteString("</manifest>\n<spine toc=\"ncx\">\n")
+    for _, id := range x.spine { b.WriteString(`<itemref idref="` + id + `"/>` + "\n") }
+    b.WriteString("</spine>\n</package>\n")
+    return b.String()
+}
+
+// page 生成完整的 XHTML 页面；css 是样式表相对页面的路径（目录页在 EPUB 根目录，其余页面在 xhtml/ 下）。
+func page(m format.Meta, title, css, body string) string {
+    return `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="` + esc(m.Language) + `" lang="` + esc(m.Language) + `">
+<head>
+<meta charset="utf-8"/>
+<title>` + esc(title) + `</title>
+<link rel="stylesheet" type="text/css" href="` + css + `"/>
+</head>
+<body>
+` + body + `</body>
+</html>
+`
+}
+
+// chapterBody 生成章节 XHTML 正文：转义标题与正文，每段一个 <p>，插图通过 addImage 加入书中。
+func chapterBody(title string, paras []format.Paragraph, addImage func(*format.Image) (string, error)) (string, error) {
+    var b strings.Builder
+    b.WriteString("<h1>" + esc(title) + "</h1>\n")
+    for _, p := range paras {
+        if p.Image != nil {
+            src, err := addImage(p.Image)
+            if err != nil { return "", err }
+            b.WriteString(`<p class="img"><img src="` + esc(src) + `" alt=""/></p>` + "\n")
+            continue
+        }
+        b.WriteString("<p>" + esc(p.Text) + "</p>\n")
+    }
+    return b.String(), nil
+}
+
+// esc 转义 XML 特殊字符，并去掉 XML 1.0 不允许的控制字符（网页正文里偶尔会混入）。
+func esc(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\t' && r != '\n' && r != '\r' { return -1 }
+        if r == 0xFFFE || r == 0xFFFF { return -1 }
+        return r
+    }, s)
+    return html.EscapeString(s)
+}
+
+func imageExt(mime string) string {
+    switch mime {
+    case "image/png": return ".png"
+    case "image/gif": return ".gif"
+    }
+    return ".jpg"
+}
+
+// uuid 生成随机的版本 4 UUID，作为没有 Identifier 时的书籍标识。
+func uuid() string {
+    var u [16]byte
+    rand.Read(u[:])
+    u[6] = u[6]&0x0f | 0x40
+    u[8] = u[8]&0x3f | 0x80
+    return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
 }
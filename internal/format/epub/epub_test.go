@@ -0,0 +1,121 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+// parse 检查 data 是格式良好的 XML，返回全部字符数据。
+func parse(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var sb strings.Builder
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: invalid XML: %v\n%s", name, err, data)
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			sb.Write(cd)
+		}
+	}
+	return sb.String()
+}
+
+func TestExportWellFormed(t *testing.T) {
+	ch := format.NewChapter
+	tests := []struct {
+		name     string
+		book     *format.Book
+		wantText []string
+	}{
+		{
+			name:     "empty book",
+			book:     &format.Book{},
+			wantText: nil,
+		},
+		{
+			name: "escaping and control characters",
+			book: &format.Book{
+				Meta: format.Meta{Title: "<书>\x00 & \"名\"", Author: "某'作\x0b者", Description: "简介\x1f <b>粗</b>", Publisher: "A&B", Identifier: "urn:x\x00"},
+				Volumes: []format.Volume{{Chapters: []format.Chapter{
+					ch("第1章 <开始>\x0b & 结束", "a < b && c > d\n\x00控制\x0b字符\x1f￾\n\"引号\" '单引号'"),
+					ch("", ""),
+				}}},
+			},
+			wantText: []string{`<书> & "名"`, "某'作者", "第1章 <开始> & 结束", "a < b && c > d", "控制字符", `"引号" '单引号'`},
+		},
+		{
+			name: "volumes",
+			book: &format.Book{
+				Meta: format.Meta{Title: "分卷"},
+				Volumes: []format.Volume{
+					{Chapters: []format.Chapter{ch("楔子", "正文")}},
+					{Title: "第一卷\x00 <上>", Chapters: []format.Chapter{ch("第1章", "正文")}},
+					{Title: "空卷"},
+				},
+			},
+			wantText: []string{"第一卷 <上>", "空卷"},
+		},
+		{
+			name: "cover and inline image",
+			book: &format.Book{
+				Meta:  format.Meta{Title: "插图\x0b"},
+				Cover: &format.Image{Data: []byte{0xff, 0xd8, 0xff}, MIME: "image/jpeg"},
+				Volumes: []format.Volume{{Chapters: []format.Chapter{
+					{Title: "第1章", Paragraphs: []format.Paragraph{{Text: "前"}, {Image: &format.Image{Data: []byte{0x89, 'P', 'N', 'G'}, MIME: "image/png"}}, {Text: "后"}}},
+				}}},
+			},
+			wantText: []string{"插图", "前", "后"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (Exporter{}).Export(&buf, tt.book, nil); err != nil {
+				t.Fatal(err)
+			}
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(zr.File) == 0 || zr.File[0].Name != "mimetype" {
+				t.Fatal("first entry is not mimetype")
+			}
+			var text strings.Builder
+			for _, f := range zr.File {
+				switch path.Ext(f.Name) {
+				case ".xhtml", ".opf", ".ncx", ".xml":
+				default:
+					continue
+				}
+				r, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := io.ReadAll(r)
+				r.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				text.WriteString(parse(t, f.Name, data))
+			}
+			for _, s := range tt.wantText {
+				if !strings.Contains(text.String(), s) {
+					t.Errorf("text does not contain %q", s)
+				}
+			}
+		})
+	}
+}
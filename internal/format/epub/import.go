@@ -0,0 +1,344 @@
+package epub
+
+import (
+    "archive/zip"
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "net/url"
+    "path"
+    "slices"
+    "strings"
+
+    "github.com/PuerkitoBio/goquery"
+    "github.com/sreio/go-novel/internal/format"
+)
+
+// Importer 读取 EPUB 2/3：元数据与封面来自 package.opf，章节按书脊顺序读取，标题与分卷来自目录
+// （nav.xhtml，没有时用 toc.ncx）：有子项的一级目录是卷，其余目录项是章节。
+// 不在目录中的页面以第一个标题作为章节名，没有标题时并入上一章（常见于按大小拆开的长章节），
+// 没有文字的页面（封面、插图页）被跳过。
+type Importer struct{}
+
+func (Importer) Name() string             { return "epub" }
+func (Importer) Extensions() []string     { return []string{"epub"} }
+func (Importer) Options() []format.Option { return nil }
+
+// opfPackage 是 package.opf 中用到的部分；不带命名空间的标签匹配任意命名空间（dc:title 等）
+type opfPackage struct {
+    UniqueID string `xml:"unique-identifier,attr"`
+    Metadata struct {
+        Title       []string `xml:"title"`
+        Creator     []string `xml:"creator"`
+        Language    []string `xml:"language"`
+        Description []string `xml:"description"`
+        Publisher   []string `xml:"publisher"`
+        Identifier  []struct {
+            ID    string `xml:"id,attr"`
+            Value string `xml:",chardata"`
+        } `xml:"identifier"`
+        Meta []struct {
+            Name    string `xml:"name,attr"`
+            Content string `xml:"content,attr"`
+        } `xml:"meta"`
+    } `xml:"metadata"`
+    Items []struct {
+        ID         string `xml:"id,attr"`
+        Href       string `xml:"href,attr"`
+        MediaType  string `xml:"media-type,attr"`
+        Properties string `xml:"properties,attr"`
+    } `xml:"manifest>item"`
+    Spine struct {
+        Toc  string `xml:"toc,attr"`
+        Refs []struct {
+            IDRef string `xml:"idref,attr"`
+        } `xml:"itemref"`
+    } `xml:"spine"`
+}
+
+type navPoint struct {
+    Label string `xml:"navLabel>text"`
+    Src   struct {
+        Value string `xml:"src,attr"`
+    } `xml:"content"`
+    Points []navPoint `xml:"navPoint"`
+}
+
+// tocNode 是目录中的一项，href 已解析为 zip 内的路径（去掉 #片段）
+type tocNode struct {
+    title, href string
+    children    []tocNode
+}
+
+// tocEntry 是目录对一个页面的解释：卷名页或某卷（可以为空）中的章节
+type tocEntry struct {
+    title, volume string
+    isVolume      bool
+}
+
+// reader 读取 zip 中的文件
+type reader struct {
+    files map[string]*zip.File
+    mime  map[string]string // zip 内路径到清单中的 media-type
+}
+
+func (Importer) Import(data []byte, _ format.Options) (*format.Book, error) {
+    zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+    if err != nil { return nil, err }
+    r := &reader{files: map[string]*zip.File{}, mime: map[string]string{}}
+    for _, f := range zr.File { r.files[f.Name] = f }
+
+    var container struct {
+        Rootfiles []struct {
+            FullPath string `xml:"full-path,attr"`
+        } `xml:"rootfiles>rootfile"`
+    }
+    if err := r.xml("META-INF/container.xml", &container); err != nil { return nil, err }
+    if len(container.Rootfiles) == 0 { return nil, fmt.Errorf("container.xml has no rootfile") }
+    opfPath := container.Rootfiles[0].FullPath
+    var pkg opfPackage
+    if err := r.xml(opfPath, &pkg); err != nil { return nil, err }
+
+    b := &format.Book{Meta: meta(&pkg)}
+    base := path.Dir(opfPath)
+    hrefs := map[string]string{} // 清单 id 到 zip 内路径
+    var navPath, ncxPath, coverPath string
+    for _, it := range pkg.Items {
+        p := resolve(base, it.Href)
+        hrefs[it.ID] = p
+        r.mime[p] = it.MediaType
+        props := strings.Fields(it.Properties)
+        switch {
+        case slices.Contains(props, "nav"): navPath = p
+        case it.MediaType == "application/x-dtbncx+xml" || it.ID == pkg.Spine.Toc: ncxPath = p
+        case slices.Contains(props, "cover-image"): coverPath = p
+        }
+    }
+    if coverPath == "" {
+        for _, m := range pkg.Metadata.Meta {
+            if m.Name == "cover" { coverPath = hrefs[m.Content] }
+        }
+    }
+    if coverPath != "" {
+        if im, err := r.image(coverPath); err == nil { im.Name = "cover" + imageExt(im.MIME); b.Cover = im }
+    }
+
+    var toc []tocNode
+    if navPath != "" { toc, _ = r.nav(navPath) }
+    if len(toc) == 0 && ncxPath != "" { toc, _ = r.ncx(ncxPath) }
+    entries := map[string]tocEntry{}
+    var leaves func(nodes []tocNode, volume string)
+    leaves = func(nodes []tocNode, volume string) {
+        for _, n := range nodes {
+            if _, ok := entries[n.href]; !ok && n.href != "" { entries[n.href] = tocEntry{title: n.title, volume: volume} }
+            leaves(n.children, volume)
+        }
+    }
+    for _, n := range toc {
+        if len(n.children) == 0 { leaves([]tocNode{n}, ""); continue }
+        if n.href != "" { entries[n.href] = tocEntry{title: n.title, isVolume: true} }
+        leaves(n.children, n.title)
+    }
+
+    volume := ""
+    begin := func(title string) {
+        if title == "" || title == volume { return }
+        volume = title
+        b.Volumes = append(b.Volumes, format.Volume{Title: title})
+    }
+    for _, ref := range pkg.Spine.Refs {
+        p := hrefs[ref.IDRef]
+        if p == "" || p == navPath { continue }
+        e, inToc := entries[p]
+        if e.isVolume { begin(e.title); continue }
+        heading, paras, err := r.page(p)
+        if err != nil { return nil, err }
+        if len(paras) == 0 || !inToc && !hasText(paras) { continue }
+        if len(b.Volumes) == 0 { b.Volumes = []format.Volume{{}} }
+        v := &b.Volumes[len(b.Volumes)-1]
+        if !inToc && heading == "" && len(v.Chapters) > 0 {
+            last := &v.Chapters[len(v.Chapters)-1]
+            last.Paragraphs = append(last.Paragraphs, paras...)
+            continue
+        }
+        title := heading
+        if inToc {
+            begin(e.volume)
+            v = &b.Volumes[len(b.Volumes)-1]
+            title = e.title
+        }
+        v.Chapters = append(v.Chapters, format.Chapter{Title: title, Paragraphs: paras})
+    }
+    if len(b.Chapters()) == 0 { return nil, fmt.Errorf("no chapters found") }
+    return b, nil
+}
+
+func meta(pkg *opfPackage) format.Meta {
+    md := pkg.Metadata
+    first := func(v []string) string {
+        for _, s := range v {
+            if s = strings.TrimSpace(s); s != "" { return s }
+        }
+        return ""
+    }
+    m := format.Meta{Title: first(md.Title), Author: strings.Join(md.Creator, "、"), Language: first(md.Language), Publisher: first(md.Publisher)}
+    if m.Language == "" { m.Language = "zh" }
+    for _, id := range md.Identifier {
+        if m.Identifier == "" || id.ID == pkg.UniqueID { m.Identifier = strings.TrimSpace(id.Value) }
+    }
+    // 描述中可能含有 HTML 标签
+    if d := first(md.Description); d != "" {
+        if doc, err := goquery.NewDocumentFromReader(strings.NewReader(d)); err == nil { d = strings.TrimSpace(doc.Text()) }
+        m.Description = d
+    }
+    return m
+}
+
+func (r *reader) open(name string) ([]byte, error) {
+    f, ok := r.files[name]
+    if !ok { return nil, fmt.Errorf("missing %s", name) }
+    rc, err := f.Open()
+    if err != nil { return nil, err }
+    defer rc.Close()
+    return io.ReadAll(rc)
+}
+
+func (r *reader) xml(name string, v any) error {
+    data, err := r.open(name)
+    if err != nil { return err }
+    if err := xml.Unmarshal(data, v); err != nil { return fmt.Errorf("%s: %w", name, err) }
+    return nil
+}
+
+func (r *reader) doc(name string) (*goquery.Document, error) {
+    data, err := r.open(name)
+    if err != nil { return nil, err }
+    return goquery.NewDocumentFromReader(bytes.NewReader(data))
+}
+
+func (r *reader) image(name string) (*format.Image, error) {
+    data, err := r.open(name)
+    if err != nil { return nil, err }
+    mime := r.mime[name]
+    if !strings.HasPrefix(mime, "image/") {
+        switch strings.ToLower(path.Ext(name)) {
+        case ".png": mime = "image/png"
+        case ".gif": mime = "image/gif"
+        default: mime = "image/jpeg"
+        }
+    }
+    return &format.Image{Name: path.Base(name), MIME: mime, Data: data}, nil
+}
+
+// nav 读取 EPUB 3 目录页中 epub:type="toc" 的 <nav>。
+func (r *reader) nav(name string) ([]tocNode, error) {
+    doc, err := r.doc(name)
+    if err != nil { return nil, err }
+    nav := doc.Find("nav").FilterFunction(func(_ int, s *goquery.Selection) bool { return slices.Contains(strings.Fields(s.AttrOr("epub:type", "")), "toc") })
+    if nav.Length() == 0 { nav = doc.Find("nav") }
+    dir := path.Dir(name)
+    var list func(ol *goquery.Selection) []tocNode
+    list = func(ol *goquery.Selection) []tocNode {
+        var out []tocNode
+        ol.ChildrenFiltered("li").Each(func(_ int, li *goquery.Selection) {
+            label := li.ChildrenFiltered("a, span").First()
+            n := tocNode{title: collapse(label.Text()), children: list(li.ChildrenFiltered("ol").First())}
+            if href, ok := label.Attr("href"); ok { n.href = resolve(dir, href) }
+            out = append(out, n)
+        })
+        return out
+    }
+    return list(nav.First().ChildrenFiltered("ol").First()), nil
+}
+
+// ncx 读取 EPUB 2 的 toc.ncx。
+func (r *reader) ncx(name string) ([]tocNode, error) {
+    var ncx struct {
+        Points []navPoint `xml:"navMap>navPoint"`
+    }
+    if err := r.xml(name, &ncx); err != nil { return nil, err }
+    dir := path.Dir(name)
+    var conv func([]navPoint) []tocNode
+    conv = func(ps []navPoint) []tocNode {
+        var out []tocNode
+        for _, p := range ps { out = append(out, tocNode{title: collapse(p.Label), href: resolve(dir, p.Src.Value), children: conv(p.Points)}) }
+        return out
+    }
+    return conv(ncx.Points), nil
+}
+
+// page 提取页面正文：块级元素各成一段，插图按出现位置成为图片段落；
+// 出现在任何正文之前的第一个标题作为页面标题返回，不计入正文。
+func (r *reader) page(name string) (string, []format.Paragraph, error) {
+    doc, err := r.doc(name)
+    if err != nil { return "", nil, err }
+    dir := path.Dir(name)
+    var heading string
+    var paras []format.Paragraph
+    var line strings.Builder
+    flush := func() {
+        if t := collapse(line.String()); t != "" { paras = append(paras, format.Paragraph{Text: t}) }
+        line.Reset()
+    }
+    addImage := func(src string) {
+        if src == "" || strings.HasPrefix(src, "data:") { return }
+        if im, err := r.image(resolve(dir, src)); err == nil { paras = append(paras, format.Paragraph{Image: im}) }
+    }
+    var walk func(s *goquery.Selection)
+    walk = func(s *goquery.Selection) {
+        s.Contents().Each(func(_ int, c *goquery.Selection) {
+            switch goquery.NodeName(c) {
+            case "#text":
+                line.WriteString(c.Text())
+            case "script", "style", "head", "title", "#comment":
+            case "br":
+                flush()
+            case "h1", "h2", "h3", "h4", "h5", "h6":
+                flush()
+                if t := collapse(c.Text()); heading == "" && len(paras) == 0 {
+                    heading = t
+                } else if t != "" {
+                    paras = append(paras, format.Paragraph{Text: t})
+                }
+            case "pre":
+                flush()
+                for _, l := range strings.Split(c.Text(), "\n") {
+                    if l = strings.TrimSpace(l); l != "" { paras = append(paras, format.Paragraph{Text: l}) }
+                }
+            case "img":
+                flush()
+                addImage(c.AttrOr("src", ""))
+            case "image": // <svg> 中的 <image xlink:href>
+                flush()
+                addImage(c.AttrOr("href", ""))
+            case "p", "div", "li", "blockquote", "section", "article", "tr", "dd", "dt", "figure", "figcaption", "table", "ul", "ol", "body", "hr":
+                flush()
+                walk(c)
+                flush()
+            default:
+                walk(c)
+            }
+        })
+    }
+    walk(doc.Find("body"))
+    flush()
+    return heading, paras, nil
+}
+
+// resolve 把相对 dir 的链接转为 zip 内的路径，去掉 #片段并解码 %xx。
+func resolve(dir, href string) string {
+    href, _, _ = strings.Cut(href, "#")
+    if href == "" { return "" }
+    if u, err := url.PathUnescape(href); err == nil { href = u }
+    return strings.TrimPrefix(path.Join(dir, href), "/")
+}
+
+func collapse(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+func hasText(paras []format.Paragraph) bool {
+    for _, p := range paras {
+        if p.Image == nil { return true }
+    }
+    return false
+}
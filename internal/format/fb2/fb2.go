@@ -0,0 +1,210 @@
+package fb2
+
+import (
+    "archive/zip"
+    "bufio"
+    "encoding/base64"
+    "fmt"
+    "html"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/sreio/go-novel/internal/format"
+)
+
+func init() {
+    format.Register(Exporter{})
+    format.Register(Exporter{Zip: true})
+}
+
+// Exporter 导出 FictionBook 2：title-info 元数据、每章一个 section（有分卷时章节嵌套在卷的 section 中）、
+// base64 内嵌封面。Zip 为 true 时是 fb2.zip 变体：zip 中只有一个 .fb2 文件，多数阅读器可以直接打开。
+// 正文按章流式写出；FB2 要求图片放在文档末尾，插图数据会保留到 Finish 再写入。
+type Exporter struct{ Zip bool }
+
+func (x Exporter) Name() string {
+    if x.Zip { return "fb2.zip" }
+    return "fb2"
+}
+
+func (x Exporter) Extension() string { return x.Name() }
+
+func (x Exporter) MIMEType() string {
+    if x.Zip { return "application/x-zip-compressed-fb2" }
+    return "application/x-fictionbook+xml"
+}
+
+func (Exporter) Options() []format.Option { return nil }
+func (Exporter) UsesCover() bool          { return true }
+
+func (x Exporter) Export(w io.Writer, b *format.Book, opts format.Options) error {
+    cw, err := x.NewWriter(w, opts)
+    if err != nil { return err }
+    return format.WriteBook(cw, b)
+}
+
+func (x Exporter) NewWriter(w io.Writer, _ format.Options) (format.ChapterWriter, error) {
+    fw := &writer{}
+    if x.Zip { fw.zw = zip.NewWriter(w) } else { fw.w = bufio.NewWriter(w) }
+    return fw, nil
+}
+
+// binary 是文档末尾的 <binary> 图片
+type binary struct {
+    id  string
+    img *format.Image
+}
+
+type writer struct {
+    zw       *zip.Writer // fb2.zip 变体
+    w        *bufio.Writer
+    binaries []binary
+    inVolume bool
+    volume   int // 当前卷的章节数
+    chapters int
+    finished bool
+}
+
+func (x *writer) Begin(b *format.Book) error {
+    if x.zw != nil {
+        f, err := x.zw.CreateHeader(&zip.FileHeader{Name: fileName(b.Title) + ".fb2", Method: zip.Deflate, Modified: time.Now()})
+        if err != nil { return err }
+        x.w = bufio.NewWriter(f)
+    }
+    lang := b.Language
+    if lang == "" { lang = "zh" }
+    title := b.Title
+    if title == "" { title = "未命名" }
+    var s strings.Builder
+    s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">
+<description>
+<title-info>
+<genre>prose_contemporary</genre>
+`)
+    // 中文姓名不拆分为 first-name / last-name，整体写入 nickname
+    s.WriteString(author(b.Author))
+    s.WriteString("<book-title>" + esc(title) + "</book-title>\n")
+    if b.Description != "" { s.WriteString("<annotation><p>" + esc(b.Description) + "</p></annotation>\n") }
+    if b.Cover != nil && len(b.Cover.Data) > 0 {
+        id := "cover" + imageExt(b.Cover.MIME)
+        x.binaries = append(x.binaries, binary{id, b.Cover})
+        s.WriteString(`<coverpage><image l:href="#` + id + `"/></coverpage>` + "\n")
+    }
+    s.WriteString("<lang>" + esc(lang) + "</lang>\n</title-info>\n<document-info>\n")
+    s.WriteString("<author><nickname>go-novel</nickname></author>\n<program-used>go-novel</program-used>\n")
+    today := time.Now().Format("2006-01-02")
+    s.WriteString(`<date value="` + today + `">` + today + "</date>\n")
+    id := b.Identifier
+    if id == "" { id = fmt.Sprintf("go-novel-%d", time.Now().UnixNano()) }
+    s.WriteString("<id>" + esc(id) + "</id>\n<version>1.0</version>\n</document-info>\n")
+    if b.Publisher != "" { s.WriteString("<publish-info><publisher>" + esc(b.Publisher) + "</publisher></publish-info>\n") }
+    s.WriteString("</description>\n<body>\n<title><p>" + esc(title) + "</p>")
+    if b.Author != "" { s.WriteString("<p>" + esc(b.Author) + "</p>") }
+    s.WriteString("</title>\n")
+    return x.write(s.String())
+}
+
+// BeginVolume 开始卷的 section，之后的章节作为它的子 section，直到下一卷或 Finish。
+func (x *writer) BeginVolume(title string) error {
+    if err := x.endVolume(); err != nil { return err }
+    x.inVolume, x.volume = true, 0
+    return x.write("<section>\n<title><p>" + esc(title) + "</p></title>\n")
+}
+
+func (x *writer) WriteChapter(ch format.Chapter) error {
+    x.chapters++
+    x.volume++
+    title := strings.TrimSpace(ch.Title)
+    if title == "" { title = fmt.Sprintf("第 %d 章", x.chapters) }
+    var s strings.Builder
+    s.WriteString("<section>\n<title><p>" + esc(title) + "</p></title>\n")
+    n := 0
+    for _, p := range ch.Paragraphs {
+        if p.Image != nil {
+            id := fmt.Sprintf("img%04d%s", len(x.binaries)+1, imageExt(p.Image.MIME))
+            x.binaries = append(x.binaries, binary{id, p.Image})
+            s.WriteString(`<image l:href="#` + id + `"/>` + "\n")
+            n++
+            continue
+        }
+        s.WriteString("<p>" + esc(p.Text) + "</p>\n")
+        n++
+    }
+    // section 不能只有标题
+    if n == 0 { s.WriteString("<empty-line/>\n") }
+    s.WriteString("</section>\n")
+    return x.write(s.String())
+}
+
+// Finish 关闭 body，写入封面与插图，重复调用无效果。
+func (x *writer) Finish() error {
+    if x.finished { return nil }
+    x.finished = true
+    if err := x.endVolume(); err != nil { return err }
+    if x.chapters == 0 {
+        // body 至少要有一个 section
+        if err := x.write("<section><empty-line/></section>\n"); err != nil { return err }
+    }
+    if err := x.write("</body>\n"); err != nil { return err }
+    for _, bin := range x.binaries {
+        if err := x.write(`<binary id="` + bin.id + `" content-type="` + bin.img.MIME + `">`); err != nil { return err }
+        enc := base64.NewEncoder(base64.StdEncoding, x.w)
+        if _, err := enc.Write(bin.img.Data); err != nil { return err }
+        if err := enc.Close(); err != nil { return err }
+        if err := x.write("</binary>\n"); err != nil { return err }
+    }
+    if err := x.write("</FictionBook>\n"); err != nil { return err }
+    if err := x.w.Flush(); err != nil { return err }
+    if x.zw != nil { return x.zw.Close() }
+    return nil
+}
+
+func (x *writer) endVolume() error {
+    if !x.inVolume { return nil }
+    x.inVolume = false
+    // section 不能只有标题
+    if x.volume == 0 { return x.write("<empty-line/>\n</section>\n") }
+    return x.write("</section>\n")
+}
+
+// write 写入一段 XML；每章写完即刷新，中断时已写出的章节在文件中。
+func (x *writer) write(s string) error {
+    if _, err := x.w.WriteString(s); err != nil { return err }
+    if x.zw != nil { return nil } // zip 条目在 Finish 时整体结束，缓冲交给 zip 压缩器
+    return x.w.Flush()
+}
+
+func author(name string) string {
+    if name == "" { return "<author><nickname>佚名</nickname></author>\n" }
+    return "<author><nickname>" + esc(name) + "</nickname></author>\n"
+}
+
+// esc 转义 XML 特殊字符，并去掉 XML 1.0 不允许的控制字符（网页正文里偶尔会混入）。
+func esc(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\t' && r != '\n' && r != '\r' { return -1 }
+        if r == 0xFFFE || r == 0xFFFF { return -1 }
+        return r
+    }, s)
+    return html.EscapeString(s)
+}
+
+// fileName 返回 zip 内 .fb2 文件的名字：书名去掉路径分隔符等不安全字符。
+func fileName(title string) string {
+    title = strings.Map(func(r rune) rune {
+        if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 { return '_' }
+        return r
+    }, strings.TrimSpace(title))
+    if title == "" { return "book" }
+    return title
+}
+
+func imageExt(mime string) string {
+    switch mime {
+    case "image/png": return ".png"
+    case "image/gif": return ".gif"
+    }
+    return ".jpg"
+}
@@ -0,0 +1,137 @@
+package fb2
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+// parse 检查 data 是格式良好的 XML，返回根元素名、section 数与全部字符数据。
+func parse(t *testing.T, data []byte) (root string, sections int, text string) {
+	t.Helper()
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var sb strings.Builder
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, data)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if root == "" {
+				root = tok.Name.Local
+			}
+			if tok.Name.Local == "section" {
+				sections++
+			}
+		case xml.CharData:
+			sb.Write(tok)
+		}
+	}
+	return root, sections, sb.String()
+}
+
+func TestExportWellFormed(t *testing.T) {
+	ch := format.NewChapter
+	img := &format.Image{Data: []byte{0x89, 'P', 'N', 'G'}, MIME: "image/png"}
+	tests := []struct {
+		name         string
+		book         *format.Book
+		wantSections int
+		wantText     []string
+	}{
+		{
+			name:         "empty book",
+			book:         &format.Book{},
+			wantSections: 1,
+			wantText:     []string{"未命名", "佚名"},
+		},
+		{
+			name: "escaping and control characters",
+			book: &format.Book{
+				Meta: format.Meta{Title: `<书> & "名"`, Author: "某'作者", Description: "简介 <b>粗</b>", Publisher: "A&B"},
+				Volumes: []format.Volume{{Chapters: []format.Chapter{
+					ch("第1章 <开始> & 结束", "a < b && c > d\n\x00控制\x0b字符\x1f\n\"引号\" '单引号'"),
+					ch("", ""),
+				}}},
+			},
+			wantSections: 2,
+			wantText:     []string{`<书> & "名"`, "某'作者", "第1章 <开始> & 结束", "a < b && c > d", "控制字符", `"引号" '单引号'`, "第 2 章"},
+		},
+		{
+			name: "volumes with an empty one",
+			book: &format.Book{
+				Meta: format.Meta{Title: "分卷"},
+				Volumes: []format.Volume{
+					{Chapters: []format.Chapter{ch("楔子", "正文")}},
+					{Title: "第一卷", Chapters: []format.Chapter{ch("第1章", "正文"), ch("第2章", "正文")}},
+					{Title: "空卷"},
+					{Title: "第二卷", Chapters: []format.Chapter{ch("第3章", "正文")}},
+				},
+			},
+			wantSections: 7,
+			wantText:     []string{"第一卷", "空卷", "第二卷", "第3章"},
+		},
+		{
+			name: "cover and inline image",
+			book: &format.Book{
+				Meta:  format.Meta{Title: "插图"},
+				Cover: &format.Image{Data: []byte{0xff, 0xd8, 0xff}, MIME: "image/jpeg"},
+				Volumes: []format.Volume{{Chapters: []format.Chapter{
+					{Title: "第1章", Paragraphs: []format.Paragraph{{Text: "前"}, {Image: img}, {Text: "后"}}},
+					{Title: "只有插图", Paragraphs: []format.Paragraph{{Image: img}}},
+				}}},
+			},
+			wantSections: 2,
+			wantText:     []string{"前", "后"},
+		},
+	}
+	for _, tt := range tests {
+		for _, x := range []Exporter{{}, {Zip: true}} {
+			t.Run(tt.name+"/"+x.Name(), func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := x.Export(&buf, tt.book, nil); err != nil {
+					t.Fatal(err)
+				}
+				data := buf.Bytes()
+				if x.Zip {
+					zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if len(zr.File) != 1 || !strings.HasSuffix(zr.File[0].Name, ".fb2") {
+						t.Fatalf("zip entries = %v, want a single .fb2", zr.File)
+					}
+					f, err := zr.File[0].Open()
+					if err != nil {
+						t.Fatal(err)
+					}
+					if data, err = io.ReadAll(f); err != nil {
+						t.Fatal(err)
+					}
+				}
+				root, sections, text := parse(t, data)
+				if root != "FictionBook" {
+					t.Errorf("root element = %q, want FictionBook", root)
+				}
+				if sections != tt.wantSections {
+					t.Errorf("sections = %d, want %d", sections, tt.wantSections)
+				}
+				for _, s := range tt.wantText {
+					if !strings.Contains(text, s) {
+						t.Errorf("text does not contain %q", s)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,348 @@
+// Package format 定义导出使用的书籍模型与导出器注册表。
+//
+// 每种格式在自己的子包中实现 Exporter，并在 init 中调用 Register；
+// 命令行与 Web 服务通过 internal/format/all 引入全部格式，按名称查找导出器。
+// 可以读回的格式另外实现 Importer 并调用 RegisterImporter，用于格式转换。
+package format
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Book 是导出用的整本书：元数据、封面与按卷组织的章节。
+type Book struct {
+	Meta
+	Cover   *Image // 封面，可为空
+	Volumes []Volume
+}
+
+// Meta 是书籍元数据。
+type Meta struct {
+	Title, Author string
+	Language      string // 默认 zh
+	Identifier    string // 如 urn:go-novel:<书籍ID>，同一本书重新导出时保持不变
+	Description   string
+	Publisher     string // 书源名称
+}
+
+// Volume 是一卷；书源没有分卷时整本书只有一个标题为空的卷。
+type Volume struct {
+	Title    string
+	Chapters []Chapter
+}
+
+// Chapter 是一章，正文按段落保存。
+type Chapter struct {
+	Title      string
+	Paragraphs []Paragraph
+}
+
+// Paragraph 是一个段落：Image 非空时为插图，否则为一段文字。
+type Paragraph struct {
+	Text  string
+	Image *Image
+}
+
+// Image 是书中的图片（封面或插图）。
+type Image struct {
+	Name string // 文件名，如 cover.png
+	MIME string // image/jpeg 或 image/png
+	Data []byte
+}
+
+// NewChapter 把抓取到的正文拆成段落：按行分段，去掉首尾空白（包括全角空格缩进）与空行。
+func NewChapter(title, content string) Chapter {
+	ch := Chapter{Title: title}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			ch.Paragraphs = append(ch.Paragraphs, Paragraph{Text: line})
+		}
+	}
+	return ch
+}
+
+// Text 返回章节的纯文本正文，段落之间用换行分隔，插图被忽略。
+func (c Chapter) Text() string {
+	var lines []string
+	for _, p := range c.Paragraphs {
+		if p.Image == nil {
+			lines = append(lines, p.Text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Chapters 按顺序返回所有卷中的章节。
+func (b *Book) Chapters() []Chapter {
+	var out []Chapter
+	for _, v := range b.Volumes {
+		out = append(out, v.Chapters...)
+	}
+	return out
+}
+
+// HasVolumes 判断是否有带标题的分卷；没有时导出器按平铺的章节列表生成目录。
+func (b *Book) HasVolumes() bool {
+	for _, v := range b.Volumes {
+		if v.Title != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Exporter 把 Book 导出为一种文件格式。
+type Exporter interface {
+	Name() string      // 格式名，即 -f / format 参数，小写
+	Extension() string // 文件扩展名，不含点，如 epub
+	MIMEType() string
+	Options() []Option // 支持的导出选项
+	Export(w io.Writer, b *Book, opts Options) error
+}
+
+// CoverExporter 由会嵌入封面的导出器实现；导出纯文本等格式时不必获取或生成封面。
+type CoverExporter interface {
+	Exporter
+	UsesCover() bool
+}
+
+// Checker 由依赖运行环境的导出器实现，如 PDF 需要可以嵌入的中文字体；Validate 会调用 Check，
+// 在开始下载前发现问题，而不是抓完整本书才导出失败。
+type Checker interface {
+	Exporter
+	Check(opts Options) error
+}
+
+// MIMETyper 由媒体类型取决于导出选项的导出器实现，如按 charset 选项编码的 TXT。
+type MIMETyper interface {
+	Exporter
+	MIMETypeFor(opts Options) string
+}
+
+// MIMEType 返回按 opts 导出的文件的媒体类型。
+func MIMEType(e Exporter, opts Options) string {
+	if m, ok := e.(MIMETyper); ok {
+		return m.MIMETypeFor(opts)
+	}
+	return e.MIMEType()
+}
+
+// UsesCover 判断导出器是否使用封面。
+func UsesCover(e Exporter) bool {
+	c, ok := e.(CoverExporter)
+	return ok && c.UsesCover()
+}
+
+// Option 描述一个导出选项。
+type Option struct {
+	Name    string   `json:"name"`
+	Default string   `json:"default,omitempty"`
+	Usage   string   `json:"usage"`
+	Values  []string `json:"values,omitempty"` // 可选值，为空时不限制
+}
+
+// Options 是 key=value 形式的导出选项；导出器只读取自己声明的键，其它键被忽略。
+type Options map[string]string
+
+// ParseOptions 解析 key=value 列表（命令行 --option 或查询参数 option）。
+func ParseOptions(kvs []string) (Options, error) {
+	o := Options{}
+	for _, kv := range kvs {
+		k, v, ok := strings.Cut(kv, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("invalid option %q, want key=value", kv)
+		}
+		o[k] = strings.TrimSpace(v)
+	}
+	return o, nil
+}
+
+// Merge 返回合并后的选项，over 中的值优先。
+func (o Options) Merge(over Options) Options {
+	out := Options{}
+	for k, v := range o {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
+
+// String 返回选项值，未设置或为空时返回 def。
+func (o Options) String(key, def string) string {
+	if v := o[key]; v != "" {
+		return v
+	}
+	return def
+}
+
+// Float 返回浮点数选项，未设置时返回 def。
+func (o Options) Float(key string, def float64) (float64, error) {
+	v := o[key]
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("option %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// Int 返回整数选项，未设置时返回 def。
+func (o Options) Int(key string, def int) (int, error) {
+	v := o[key]
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("option %s: %w", key, err)
+	}
+	return n, nil
+}
+
+// Bool 返回布尔选项（1/true/yes/on），未设置时返回 def。
+func (o Options) Bool(key string, def bool) (bool, error) {
+	switch strings.ToLower(o[key]) {
+	case "":
+		return def, nil
+	case "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("option %s: invalid bool %q", key, o[key])
+}
+
+// Validate 检查导出器声明了可选值的选项与拆分规则，并调用 Checker，在开始下载前发现拼写错误与缺少的字体等问题。
+func Validate(e Exporter, opts Options) error {
+	if err := validate(e.Name(), e.Options(), opts); err != nil {
+		return err
+	}
+	if _, err := splitRule(e, opts); err != nil {
+		return err
+	}
+	if c, ok := e.(Checker); ok {
+		return c.Check(opts)
+	}
+	return nil
+}
+
+// ValidateImport 检查导入器声明了可选值的选项。
+func ValidateImport(i Importer, opts Options) error {
+	return validate(i.Name(), i.Options(), opts)
+}
+
+func validate(name string, decl []Option, opts Options) error {
+	for _, opt := range decl {
+		v := strings.ToLower(opts[opt.Name])
+		if v == "" || len(opt.Values) == 0 || slices.Contains(opt.Values, v) {
+			continue
+		}
+		return fmt.Errorf("invalid %s option %s=%s (%s)", name, opt.Name, opts[opt.Name], strings.Join(opt.Values, "|"))
+	}
+	return nil
+}
+
+// For 返回导出器声明过的选项，用于记录一次导出实际使用的选项；没有时返回 nil。
+func (o Options) For(e Exporter) Options {
+	var out Options
+	for _, opt := range e.Options() {
+		if v := o[opt.Name]; v != "" {
+			if out == nil {
+				out = Options{}
+			}
+			out[opt.Name] = v
+		}
+	}
+	return out
+}
+
+var (
+	mu        sync.RWMutex
+	exporters = map[string]Exporter{}
+)
+
+// Register 注册导出器，通常在格式包的 init 中调用；名称重复时 panic。
+func Register(e Exporter) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := strings.ToLower(e.Name())
+	if _, dup := exporters[name]; dup {
+		panic("format: Register called twice for " + name)
+	}
+	exporters[name] = e
+}
+
+// Lookup 按名称（不区分大小写）查找导出器。
+func Lookup(name string) (Exporter, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	e, ok := exporters[strings.ToLower(strings.TrimSpace(name))]
+	return e, ok
+}
+
+// Names 返回已注册的格式名，按字母排序。
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(exporters))
+	for n := range exporters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save 把书导出到 path；导出失败时删除不完整的文件。按选项拆分的格式写出多个文件，见 SplitPath；
+// 上次导出留下的多余拆分文件会被删除。
+func Save(path string, e Exporter, b *Book, opts Options) error {
+	if Splits(e, opts) {
+		return saveSplit(path, e, b, opts)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Export(f, b, opts); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	removeSplits(path, 1)
+	return nil
+}
+
+func saveSplit(path string, e Exporter, b *Book, opts Options) error {
+	f, err := Create(path, e, b, opts)
+	if err != nil {
+		return err
+	}
+	for _, v := range b.Volumes {
+		if v.Title != "" {
+			err = f.BeginVolume(v.Title)
+		}
+		for _, ch := range v.Chapters {
+			if err == nil {
+				err = f.WriteChapter(ch)
+			}
+		}
+		if err != nil {
+			f.Abort()
+			return err
+		}
+	}
+	return f.Close()
+}
@@ -0,0 +1,167 @@
+// Package html 导出单文件 HTML：样式与图片全部内嵌，离线用浏览器即可阅读，也便于归档与全文搜索。
+package html
+
+import (
+    "bufio"
+    "encoding/base64"
+    "fmt"
+    stdhtml "html"
+    "io"
+    "strings"
+
+    "github.com/sreio/go-novel/internal/format"
+)
+
+func init() { format.Register(Exporter{}) }
+
+// Exporter 导出单文件 HTML：书名、作者与简介，可点击的目录（有分卷时两级），每章一个锚点（#ch1、#ch2…），
+// 插图与封面以 data URI 内嵌。目录在正文之前，需要全部章节，因此不是流式导出。
+type Exporter struct{}
+
+func (Exporter) Name() string      { return "html" }
+func (Exporter) Extension() string { return "html" }
+func (Exporter) MIMEType() string  { return "text/html; charset=utf-8" }
+func (Exporter) UsesCover() bool   { return true }
+
+func (Exporter) Options() []format.Option {
+    return []format.Option{
+        {Name: "theme", Default: "auto", Usage: "配色，auto 跟随系统的深色模式", Values: Themes},
+    }
+}
+
+// Themes 是支持的配色。
+var Themes = []string{"auto", "light", "dark"}
+
+// 浅色与深色配色，通过 CSS 变量切换
+const (
+    light = `--fg: #222; --bg: #fdfdf8; --muted: #777; --link: #2a5db0; --line: #ddd;`
+    dark  = `--fg: #ccc; --bg: #1b1b1d; --muted: #888; --link: #8ab4f8; --line: #333;`
+)
+
+// 中文排版：限制行宽、首行缩进两字、较宽行距；目录与章节末尾的“返回目录”不缩进
+const stylesheet = `body { margin: 0 auto; max-width: 42em; padding: 1em 1.2em 4em; color: var(--fg); background: var(--bg); line-height: 1.8; text-align: justify; font-family: "Noto Serif CJK SC", "Source Han Serif SC", "Songti SC", "SimSun", serif; font-size: 1.1em; }
+a { color: var(--link); text-decoration: none; }
+a:hover { text-decoration: underline; }
+header { text-align: center; margin: 2em 0; }
+header h1 { font-size: 2em; margin: 0.5em 0; }
+header .author { color: var(--muted); }
+header .description { text-align: left; text-indent: 2em; color: var(--muted); }
+header img { max-width: 60%; max-height: 24em; }
+nav { border-top: 1px solid var(--line); border-bottom: 1px solid var(--line); padding: 1em 0; }
+nav h2 { text-align: center; }
+nav ol { list-style: none; padding-left: 0; }
+nav ol ol { padding-left: 2em; }
+nav li { margin: 0.2em 0; }
+nav .volume { font-weight: bold; margin-top: 0.8em; }
+section { margin-top: 3em; }
+h2 { font-size: 1.4em; line-height: 1.4; text-align: center; margin: 1em 0 1.5em; }
+h2.volume { font-size: 1.7em; margin: 3em 0 1em; }
+p { margin: 0 0 0.4em; text-indent: 2em; }
+p.img { text-indent: 0; text-align: center; }
+p.img img { max-width: 100%; }
+p.back { text-indent: 0; text-align: right; font-size: 0.85em; margin-top: 1.5em; }
+`
+
+// themeCSS 返回配色对应的 CSS 变量；auto 默认浅色，系统为深色模式时切换为深色。
+func themeCSS(theme string) string {
+    switch theme {
+    case "light": return ":root { " + light + " }\n"
+    case "dark": return ":root { " + dark + " color-scheme: dark; }\n"
+    }
+    return ":root { " + light + " color-scheme: light dark; }\n@media (prefers-color-scheme: dark) { :root { " + dark + " } }\n"
+}
+
+func (Exporter) Export(w io.Writer, b *format.Book, opts format.Options) error {
+    theme := strings.ToLower(opts.String("theme", "auto"))
+    lang := b.Language
+    if lang == "" { lang = "zh" }
+    title := b.Title
+    if title == "" { title = "未命名" }
+    bw := bufio.NewWriter(w)
+    var s strings.Builder
+    s.WriteString("<!DOCTYPE html>\n<html lang=\"" + esc(lang) + "\">\n<head>\n<meta charset=\"utf-8\">\n")
+    s.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1">` + "\n")
+    s.WriteString("<title>" + esc(title) + "</title>\n")
+    if b.Author != "" { s.WriteString(`<meta name="author" content="` + esc(b.Author) + `">` + "\n") }
+    if b.Description != "" { s.WriteString(`<meta name="description" content="` + esc(b.Description) + `">` + "\n") }
+    s.WriteString("<style>\n" + themeCSS(theme) + stylesheet + "</style>\n</head>\n<body>\n<header>\n")
+    if b.Cover != nil && len(b.Cover.Data) > 0 { s.WriteString(`<p><img src="` + dataURI(b.Cover) + `" alt="封面"></p>` + "\n") }
+    s.WriteString("<h1>" + esc(title) + "</h1>\n")
+    if b.Author != "" { s.WriteString(`<p class="author">` + esc(b.Author) + "</p>\n") }
+    if b.Publisher != "" { s.WriteString(`<p class="author">来源：` + esc(b.Publisher) + "</p>\n") }
+    for _, line := range strings.Split(b.Description, "\n") {
+        if line = strings.TrimSpace(line); line != "" { s.WriteString(`<p class="description">` + esc(line) + "</p>\n") }
+    }
+    s.WriteString("</header>\n")
+    s.WriteString(toc(b))
+    if _, err := bw.WriteString(s.String()); err != nil { return err }
+
+    n, vol := 0, 0
+    for _, v := range b.Volumes {
+        if v.Title != "" {
+            vol++
+            if _, err := fmt.Fprintf(bw, "<h2 class=\"volume\" id=\"vol%d\">%s</h2>\n", vol, esc(v.Title)); err != nil { return err }
+        }
+        for _, ch := range v.Chapters {
+            n++
+            if _, err := bw.WriteString(chapter(n, ch)); err != nil { return err }
+        }
+    }
+    if _, err := bw.WriteString("</body>\n</html>\n"); err != nil { return err }
+    return bw.Flush()
+}
+
+// toc 生成目录：有分卷时卷名下嵌套章节列表，链接指向各卷与章节的锚点。
+func toc(b *format.Book) string {
+    var s strings.Builder
+    s.WriteString("<nav id=\"toc\">\n<h2>目录</h2>\n<ol>\n")
+    n, vol := 0, 0
+    for _, v := range b.Volumes {
+        if v.Title != "" {
+            vol++
+            fmt.Fprintf(&s, "<li class=\"volume\"><a href=\"#vol%d\">%s</a>\n<ol>\n", vol, esc(v.Title))
+        }
+        for _, ch := range v.Chapters {
+            n++
+            fmt.Fprintf(&s, "<li><a href=\"#ch%d\">%s</a></li>\n", n, esc(chapterTitle(n, ch)))
+        }
+        if v.Title != "" { s.WriteString("</ol>\n</li>\n") }
+    }
+    s.WriteString("</ol>\n</nav>\n")
+    return s.String()
+}
+
+// chapter 生成第 n 章的 section：标题、逐段的 <p>、内嵌插图与返回目录的链接。
+func chapter(n int, ch format.Chapter) string {
+    var s strings.Builder
+    fmt.Fprintf(&s, "<section id=\"ch%d\">\n<h2>%s</h2>\n", n, esc(chapterTitle(n, ch)))
+    for _, p := range ch.Paragraphs {
+        if p.Image != nil {
+            s.WriteString(`<p class="img"><img src="` + dataURI(p.Image) + `" alt=""></p>` + "\n")
+            continue
+        }
+        s.WriteString("<p>" + esc(p.Text) + "</p>\n")
+    }
+    s.WriteString("<p class=\"back\"><a href=\"#toc\">返回目录</a></p>\n</section>\n")
+    return s.String()
+}
+
+func chapterTitle(n int, ch format.Chapter) string {
+    if title := strings.TrimSpace(ch.Title); title != "" { return title }
+    return fmt.Sprintf("第 %d 章", n)
+}
+
+func dataURI(im *format.Image) string {
+    mime := im.MIME
+    if mime == "" { mime = "image/jpeg" }
+    return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(im.Data)
+}
+
+// esc 转义 HTML 特殊字符，并去掉网页正文里偶尔混入的控制字符。
+func esc(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\t' && r != '\n' && r != '\r' { return -1 }
+        return r
+    }, s)
+    return stdhtml.EscapeString(s)
+}
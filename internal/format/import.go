@@ -0,0 +1,78 @@
+package format
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Importer 把已有的电子书读回 Book，用于在格式之间转换（novel convert），无需重新抓取。
+type Importer interface {
+	Name() string         // 格式名，即 --from 参数，小写
+	Extensions() []string // 识别的文件扩展名，不含点
+	Options() []Option    // 支持的导入选项
+	Import(data []byte, opts Options) (*Book, error)
+}
+
+var importers = map[string]Importer{}
+
+// RegisterImporter 注册导入器，通常与同格式的导出器一起在 init 中调用；名称重复时 panic。
+func RegisterImporter(i Importer) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := strings.ToLower(i.Name())
+	if _, dup := importers[name]; dup {
+		panic("format: RegisterImporter called twice for " + name)
+	}
+	importers[name] = i
+}
+
+// LookupImporter 按名称（不区分大小写）查找导入器。
+func LookupImporter(name string) (Importer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	i, ok := importers[strings.ToLower(strings.TrimSpace(name))]
+	return i, ok
+}
+
+// ImporterFor 按文件扩展名查找导入器。
+func ImporterFor(path string) (Importer, bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, i := range importers {
+		for _, e := range i.Extensions() {
+			if e == ext {
+				return i, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// ImporterNames 返回已注册的导入格式名，按字母排序。
+func ImporterNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(importers))
+	for n := range importers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load 用导入器读取 path。
+func Load(path string, i Importer, opts Options) (*Book, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := i.Import(data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("import %s: %w", filepath.Base(path), err)
+	}
+	return b, nil
+}
@@ -0,0 +1,227 @@
+// Package markdown 导出 Markdown：单个 .md 文件，或每章一个文件加目录页的文件夹（打包为 .md.zip）。
+package markdown
+
+import (
+    "archive/zip"
+    "bufio"
+    "encoding/base64"
+    "fmt"
+    "io"
+    "strings"
+    "time"
+
+    "github.com/sreio/go-novel/internal/format"
+)
+
+func init() {
+    format.Register(Exporter{})
+    format.Register(Exporter{Split: true})
+}
+
+// Exporter 导出 Markdown。单文件时开头是书名、简介与目录，卷与章节标题前放 <a id="chN"></a> 锚点，
+// 插图以 data URI 内嵌。Split 为 true 时是 md.zip 变体：zip 中是以书名命名的文件夹，
+// index.md 为目录页，每章一个 0001.md 这样的文件（带上一章/目录/下一章链接），图片放在 images/ 下。
+type Exporter struct{ Split bool }
+
+func (x Exporter) Name() string {
+    if x.Split { return "md.zip" }
+    return "md"
+}
+
+func (x Exporter) Extension() string { return x.Name() }
+
+func (x Exporter) MIMEType() string {
+    if x.Split { return "application/zip" }
+    return "text/markdown; charset=utf-8"
+}
+
+func (Exporter) Options() []format.Option { return nil }
+func (Exporter) UsesCover() bool          { return true }
+
+func (x Exporter) Export(w io.Writer, b *format.Book, _ format.Options) error {
+    if x.Split { return exportDir(w, b) }
+    cover := ""
+    if hasCover(b) { cover = dataURI(b.Cover) }
+    bw := bufio.NewWriter(w)
+    head := header(b, cover) + toc(b, func(n int) string { return fmt.Sprintf("#ch%d", n) }, func(v int) string { return fmt.Sprintf("#vol%d", v) })
+    if _, err := bw.WriteString(head); err != nil { return err }
+    // 有分卷时卷名为二级标题、章节为三级标题
+    level := "##"
+    if b.HasVolumes() { level = "###" }
+    n, vol := 0, 0
+    for _, v := range b.Volumes {
+        if v.Title != "" {
+            vol++
+            if _, err := fmt.Fprintf(bw, "---\n\n<a id=\"vol%d\"></a>\n\n## %s\n\n", vol, esc(v.Title)); err != nil { return err }
+        }
+        for _, ch := range v.Chapters {
+            n++
+            var s strings.Builder
+            fmt.Fprintf(&s, "<a id=\"ch%d\"></a>\n\n%s %s\n\n", n, level, esc(chapterTitle(n, ch)))
+            s.WriteString(body(ch, dataURI))
+            s.WriteString("[返回目录](#toc)\n\n")
+            if _, err := bw.WriteString(s.String()); err != nil { return err }
+        }
+    }
+    return bw.Flush()
+}
+
+// exportDir 写出 md.zip：书名/index.md、书名/0001.md… 与书名/images/ 下的封面和插图。
+func exportDir(w io.Writer, b *format.Book) error {
+    zw := zip.NewWriter(w)
+    dir := fileName(b.Title) + "/"
+    create := func(name string, data []byte) error {
+        f, err := zw.CreateHeader(&zip.FileHeader{Name: dir + name, Method: zip.Deflate, Modified: time.Now()})
+        if err != nil { return err }
+        _, err = f.Write(data)
+        return err
+    }
+    cover := ""
+    if hasCover(b) {
+        cover = "images/cover" + imageExt(b.Cover.MIME)
+        if err := create(cover, b.Cover.Data); err != nil { return err }
+    }
+    index := header(b, cover) + toc(b, chapterFile, nil)
+    if err := create("index.md", []byte(index)); err != nil { return err }
+
+    var err error
+    images := 0
+    image := func(im *format.Image) string {
+        images++
+        name := fmt.Sprintf("images/img%04d%s", images, imageExt(im.MIME))
+        if err == nil { err = create(name, im.Data) }
+        return name
+    }
+    total, n := len(b.Chapters()), 0
+    for _, v := range b.Volumes {
+        for i, ch := range v.Chapters {
+            n++
+            var s strings.Builder
+            // 卷名写在该卷第一章的开头
+            if v.Title != "" && i == 0 { s.WriteString("*" + esc(v.Title) + "*\n\n") }
+            s.WriteString("# " + esc(chapterTitle(n, ch)) + "\n\n")
+            s.WriteString(body(ch, image))
+            if err != nil { return err }
+            s.WriteString("---\n\n" + pager(n, total) + "\n")
+            if err := create(chapterFile(n), []byte(s.String())); err != nil { return err }
+        }
+    }
+    return zw.Close()
+}
+
+// header 生成书名、作者、来源、封面与简介；cover 为封面的链接，为空时不写封面。
+func header(b *format.Book, cover string) string {
+    title := b.Title
+    if title == "" { title = "未命名" }
+    var s strings.Builder
+    s.WriteString("# " + esc(title) + "\n\n")
+    var info []string
+    if b.Author != "" { info = append(info, "作者："+esc(b.Author)) }
+    if b.Publisher != "" { info = append(info, "来源："+esc(b.Publisher)) }
+    if len(info) > 0 { s.WriteString(strings.Join(info, "  \n") + "\n\n") }
+    if cover != "" { s.WriteString("![封面](" + cover + ")\n\n") }
+    var desc []string
+    for _, line := range strings.Split(b.Description, "\n") {
+        if line = strings.TrimSpace(line); line != "" { desc = append(desc, "> "+esc(line)) }
+    }
+    if len(desc) > 0 { s.WriteString(strings.Join(desc, "\n>\n") + "\n\n") }
+    return s.String()
+}
+
+// toc 生成目录列表：chapter 返回第 n 章的链接；volume 返回第 v 卷的链接，为 nil 时卷名不带链接。
+func toc(b *format.Book, chapter func(n int) string, volume func(v int) string) string {
+    var s strings.Builder
+    s.WriteString("<a id=\"toc\"></a>\n\n## 目录\n\n")
+    n, vol := 0, 0
+    for _, v := range b.Volumes {
+        indent := ""
+        if v.Title != "" {
+            vol++
+            if volume != nil {
+                fmt.Fprintf(&s, "* **[%s](%s)**\n", esc(v.Title), volume(vol))
+            } else {
+                fmt.Fprintf(&s, "* **%s**\n", esc(v.Title))
+            }
+            indent = "  "
+        }
+        for _, ch := range v.Chapters {
+            n++
+            fmt.Fprintf(&s, "%s* [%s](%s)\n", indent, esc(chapterTitle(n, ch)), chapter(n))
+        }
+    }
+    s.WriteString("\n")
+    return s.String()
+}
+
+// body 生成章节正文：段落之间空一行，插图通过 image 取得链接。
+func body(ch format.Chapter, image func(*format.Image) string) string {
+    var s strings.Builder
+    for _, p := range ch.Paragraphs {
+        if p.Image != nil {
+            s.WriteString("![](" + image(p.Image) + ")\n\n")
+            continue
+        }
+        s.WriteString(esc(p.Text) + "\n\n")
+    }
+    return s.String()
+}
+
+// pager 生成分章文件末尾的导航：上一章 · 目录 · 下一章。
+func pager(n, total int) string {
+    links := []string{}
+    if n > 1 { links = append(links, "[上一章]("+chapterFile(n-1)+")") }
+    links = append(links, "[目录](index.md)")
+    if n < total { links = append(links, "[下一章]("+chapterFile(n+1)+")") }
+    return strings.Join(links, " · ") + "\n"
+}
+
+func chapterFile(n int) string { return fmt.Sprintf("%04d.md", n) }
+
+func chapterTitle(n int, ch format.Chapter) string {
+    if title := strings.TrimSpace(ch.Title); title != "" { return title }
+    return fmt.Sprintf("第 %d 章", n)
+}
+
+func hasCover(b *format.Book) bool { return b.Cover != nil && len(b.Cover.Data) > 0 }
+
+func dataURI(im *format.Image) string {
+    mime := im.MIME
+    if mime == "" { mime = "image/jpeg" }
+    return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(im.Data)
+}
+
+// esc 转义 Markdown 语法字符，使正文按原样显示：行内的强调、链接、代码与 HTML 标签，
+// 以及行首会被当作标题、引用、列表的字符；同时去掉控制字符。
+func esc(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\t' { return -1 }
+        return r
+    }, s)
+    s = inline.Replace(s)
+    if s != "" && strings.ContainsRune("#>-+=|", rune(s[0])) { return `\` + s }
+    // 行首的“1.”会被当作有序列表
+    if i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && (s[i] == '.' || s[i] == ')') {
+        return s[:i] + `\` + s[i:]
+    }
+    return s
+}
+
+var inline = strings.NewReplacer(`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "~", `\~`)
+
+// fileName 返回 zip 内文件夹的名字：书名去掉路径分隔符等不安全字符。
+func fileName(title string) string {
+    title = strings.Map(func(r rune) rune {
+        if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 { return '_' }
+        return r
+    }, strings.TrimSpace(title))
+    if title == "" { return "book" }
+    return title
+}
+
+func imageExt(mime string) string {
+    switch mime {
+    case "image/png": return ".png"
+    case "image/gif": return ".gif"
+    }
+    return ".jpg"
+}
@@ -0,0 +1,59 @@
+package mobi
+
+import (
+    "bytes"
+    "image"
+    "image/color"
+    "image/draw"
+    _ "image/gif" // 注册解码器
+    "image/jpeg"
+    _ "image/png"
+    "net/http"
+
+    "github.com/sreio/go-novel/internal/format"
+    xdraw "golang.org/x/image/draw"
+)
+
+// 书架缩略图的尺寸上限，与 Kindle 生成的缩略图相近
+const thumbW, thumbH = 330, 470
+
+// coverImages 返回 JPEG 封面与缩略图：Kindle 书架只认 JPEG 封面，PNG 等格式先转换（透明部分铺白底）。
+func coverImages(data []byte) (cover, thumb []byte, err error) {
+    img, _, err := image.Decode(bytes.NewReader(data))
+    if err != nil { return nil, nil, err }
+    if http.DetectContentType(data) == "image/jpeg" {
+        cover = data
+    } else if cover, err = toJPEG(img); err != nil {
+        return nil, nil, err
+    }
+    b := img.Bounds()
+    scale := min(float64(thumbW)/float64(b.Dx()), float64(thumbH)/float64(b.Dy()), 1)
+    dst := image.NewRGBA(image.Rect(0, 0, max(1, int(float64(b.Dx())*scale)), max(1, int(float64(b.Dy())*scale))))
+    draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+    xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+    thumb, err = toJPEG(dst)
+    return cover, thumb, err
+}
+
+// inlineImage 返回可以嵌入的插图：JPEG、GIF 原样保留，其它格式转为 JPEG；无法解码时跳过。
+func inlineImage(im *format.Image) ([]byte, string, bool) {
+    switch mime := http.DetectContentType(im.Data); mime {
+    case "image/jpeg", "image/gif":
+        return im.Data, mime, true
+    }
+    img, _, err := image.Decode(bytes.NewReader(im.Data))
+    if err != nil { return nil, "", false }
+    data, err := toJPEG(img)
+    if err != nil { return nil, "", false }
+    return data, "image/jpeg", true
+}
+
+func toJPEG(img image.Image) ([]byte, error) {
+    b := img.Bounds()
+    rgba := image.NewRGBA(b)
+    draw.Draw(rgba, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+    draw.Draw(rgba, b, img, b.Min, draw.Over)
+    var buf bytes.Buffer
+    if err := jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: 90}); err != nil { return nil, err }
+    return buf.Bytes(), nil
+}
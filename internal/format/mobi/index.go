@@ -0,0 +1,168 @@
+package mobi
+
+import (
+    "bytes"
+    "encoding/binary"
+    "math/bits"
+)
+
+// KF8 索引（INDX）：一条索引头记录（TAGX 描述各标签，geometry 记录每条索引记录的最后一个键与条目数），
+// 若干索引记录（条目 + IDXT 偏移表），以及存放字符串的 CNCX 记录。
+
+const indxHeaderLen = 192
+
+// tag 描述条目中的一个标签：编号、每项的值个数与控制字节中的掩码
+type tag struct {
+    name         string
+    number, vpe  byte
+    mask         byte
+}
+
+// entry 是一个索引条目：键与各标签的值（按标签顺序写出，缺少的标签不写）
+type entry struct {
+    key    string
+    values map[string][]int
+}
+
+var (
+    skelTags = []tag{{"chunk_count", 1, 1, 3}, {"geometry", 6, 2, 12}}
+    chunkTags = []tag{{"cncx", 2, 1, 1}, {"file", 3, 1, 2}, {"seq", 4, 1, 4}, {"geometry", 6, 2, 8}}
+    ncxTags = []tag{
+        {"offset", 1, 1, 1}, {"length", 2, 1, 2}, {"label", 3, 1, 4}, {"depth", 4, 1, 8},
+        {"parent", 21, 1, 16}, {"first_child", 22, 1, 32}, {"last_child", 23, 1, 64}, {"pos_fid", 6, 2, 128},
+    }
+)
+
+// index 生成一个索引的全部记录：索引头、索引记录与 CNCX 记录。
+func index(tags []tag, entries []entry, cncx [][]byte) [][]byte {
+    // 一条索引记录不能超过 64KB，留出与 kindlegen 相同的余量
+    const limit = 0x10000 - indxHeaderLen - 1048
+    type block struct {
+        data, idxt bytes.Buffer
+        count      int
+        last       string
+    }
+    blocks := []*block{{}}
+    for _, e := range entries {
+        raw := encodeEntry(tags, e)
+        b := blocks[len(blocks)-1]
+        if b.data.Len()+b.idxt.Len()+len(raw)+2 > limit {
+            b = &block{}
+            blocks = append(blocks, b)
+        }
+        binary.Write(&b.idxt, binary.BigEndian, uint16(indxHeaderLen+b.data.Len()))
+        b.data.Write(raw)
+        b.count++
+        b.last = e.key
+    }
+    var records [][]byte
+    for _, b := range blocks {
+        data := align(b.data.Bytes())
+        idxt := align(append([]byte("IDXT"), b.idxt.Bytes()...))
+        var r bytes.Buffer
+        r.WriteString("INDX")
+        be32(&r, indxHeaderLen, 0, 1, 0, uint32(indxHeaderLen+len(data)), uint32(b.count), 0xFFFFFFFF, 0xFFFFFFFF)
+        r.Write(make([]byte, 156))
+        r.Write(data)
+        r.Write(idxt)
+        records = append(records, r.Bytes())
+    }
+
+    tagx := align(tagx(tags))
+    var geometry bytes.Buffer
+    idxt := []byte("IDXT")
+    pos := indxHeaderLen + len(tagx)
+    total := 0
+    for _, b := range blocks {
+        idxt = binary.BigEndian.AppendUint16(idxt, uint16(pos+geometry.Len()))
+        geometry.WriteByte(byte(len(b.last)))
+        geometry.WriteString(b.last)
+        binary.Write(&geometry, binary.BigEndian, uint16(b.count))
+        total += b.count
+    }
+    geo := align(geometry.Bytes())
+    var h bytes.Buffer
+    h.WriteString("INDX")
+    // 类型 2 与 kindlegen 一致；编码 65001 为 UTF-8
+    be32(&h, indxHeaderLen, 0, 0, 2, uint32(indxHeaderLen+len(tagx)+len(geo)), uint32(len(records)), 65001, 0xFFFFFFFF, uint32(total), 0, 0, 0, uint32(len(cncx)))
+    h.Write(make([]byte, 124))
+    be32(&h, indxHeaderLen, 0, 0)
+    h.Write(tagx)
+    h.Write(geo)
+    h.Write(align(idxt))
+    out := append([][]byte{h.Bytes()}, records...)
+    return append(out, cncx...)
+}
+
+// tagx 生成标签表：每个标签 4 字节（编号、值个数、掩码、结束标记），最后是结束标签。
+func tagx(tags []tag) []byte {
+    var body []byte
+    for _, t := range tags { body = append(body, t.number, t.vpe, t.mask, 0) }
+    body = append(body, 0, 0, 0, 1)
+    var b bytes.Buffer
+    b.WriteString("TAGX")
+    be32(&b, uint32(12+len(body)), 1) // 控制字节数为 1
+    b.Write(body)
+    return b.Bytes()
+}
+
+// encodeEntry 编码一个条目：键长度与键、控制字节（各标签的项数按掩码位置写入），再依次写出各值。
+func encodeEntry(tags []tag, e entry) []byte {
+    out := append([]byte{byte(len(e.key))}, e.key...)
+    control := 0
+    for _, t := range tags {
+        n := len(e.values[t.name]) / int(t.vpe)
+        control |= int(t.mask) & (n << bits.TrailingZeros8(t.mask))
+    }
+    out = append(out, byte(control))
+    for _, t := range tags {
+        for _, v := range e.values[t.name] { out = append(out, vwi(v)...) }
+    }
+    return out
+}
+
+// cncxTable 把字符串写入 CNCX 记录（每条为变长长度 + UTF-8），返回各字符串的偏移；
+// 偏移的高 16 位是 CNCX 记录序号。相同的字符串只写一次，过长的截断为 500 字。
+func cncxTable(strs []string) ([][]byte, map[string]int) {
+    const limit = 0x10000 - 1024
+    offsets := map[string]int{}
+    var records [][]byte
+    var buf bytes.Buffer
+    for _, s := range strs {
+        if _, ok := offsets[s]; ok { continue }
+        r := []rune(s)
+        if len(r) > 500 { r = r[:500] }
+        u := []byte(string(r))
+        raw := append(vwi(len(u)), u...)
+        if buf.Len()+len(raw) > limit {
+            records = append(records, align(buf.Bytes()))
+            buf = bytes.Buffer{}
+        }
+        offsets[s] = len(records)<<16 + buf.Len()
+        buf.Write(raw)
+    }
+    if buf.Len() > 0 { records = append(records, align(buf.Bytes())) }
+    return records, offsets
+}
+
+// vwi 编码变长整数：大端，每字节 7 位，最后一个字节最高位置 1。
+func vwi(v int) []byte {
+    var out []byte
+    for {
+        out = append([]byte{byte(v & 0x7F)}, out...)
+        v >>= 7
+        if v == 0 { break }
+    }
+    out[len(out)-1] |= 0x80
+    return out
+}
+
+// align 补 0 到 4 字节对齐。
+func align(b []byte) []byte {
+    if n := len(b) % 4; n != 0 { b = append(b, make([]byte, 4-n)...) }
+    return b
+}
+
+func be32(b *bytes.Buffer, vs ...uint32) {
+    for _, v := range vs { binary.Write(b, binary.BigEndian, v) }
+}
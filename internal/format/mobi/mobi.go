@@ -0,0 +1,215 @@
+// Package mobi 直接生成 Kindle 的 KF8（AZW3）电子书，不依赖 kindlegen 或 Calibre。
+//
+// KF8 文件是一个 PalmDB：记录 0 为 MOBI 头与 EXTH 元数据，之后依次是正文记录、片段/骨架/目录（NCX）索引、
+// 图片资源，以及 FDST、FLIS、FCIS 与结束记录。正文由若干 flow 组成：flow 0 是全部 XHTML，
+// 每个文件拆为骨架（html/head/body 外壳）与插入 body 的片段；flow 1 是样式表。
+package mobi
+
+import (
+    "fmt"
+    "html"
+    "io"
+    "strings"
+
+    "github.com/sreio/go-novel/internal/format"
+)
+
+func init() {
+    format.Register(Exporter{ext: "azw3"})
+    format.Register(Exporter{ext: "mobi"})
+}
+
+// Exporter 导出 KF8 格式的 Kindle 电子书：NCX 目录（有分卷时两级）、元数据与封面。
+// azw3 与 mobi 内容相同，只是扩展名不同；mobi 是只含 KF8 的新式 MOBI，适用于 2012 年以后的 Kindle。
+type Exporter struct{ ext string }
+
+func (x Exporter) Name() string             { return x.ext }
+func (x Exporter) Extension() string        { return x.ext }
+func (Exporter) Options() []format.Option { return nil }
+func (Exporter) UsesCover() bool          { return true }
+
+func (x Exporter) MIMEType() string {
+    if x.ext == "azw3" { return "application/vnd.amazon.mobi8-ebook" }
+    return "application/x-mobipocket-ebook"
+}
+
+// 中文排版：与 EPUB 相同的首行缩进与行距；Kindle 会覆盖字体，不指定字体族
+const stylesheet = `body { margin: 0 0.5em; line-height: 1.8; text-align: justify; }
+h1 { margin: 1em 0 1.5em; font-size: 1.3em; line-height: 1.4; font-weight: bold; text-align: center; text-indent: 0; }
+p { margin: 0 0 0.4em; text-indent: 2em; }
+p.img { text-indent: 0; text-align: center; }
+div.cover { text-align: center; text-indent: 0; }
+div.cover img, p.img img { max-width: 100%; }
+`
+
+// chunkSize 是片段的目标大小，与 kindlegen 相近；单个过长的段落不拆分
+const chunkSize = 8192
+
+func (Exporter) Export(w io.Writer, b *format.Book, _ format.Options) error {
+    k, err := build(b)
+    if err != nil { return err }
+    return k.write(w)
+}
+
+// page 是一个 XHTML 文件：标题与 body 下的顶层元素
+type page struct {
+    title string
+    elems []elem
+}
+
+// elem 是 body 下的一个顶层元素；aid 为元素的定位属性，片段选择器引用它
+type elem struct { aid, html string }
+
+// tocEntry 是 NCX 目录中的一项，file 为目标文件序号，parent 为上级在 toc 中的下标（-1 为顶级）
+type tocEntry struct {
+    label         string
+    depth, file   int
+    parent        int
+    offset, fid   int // 目标在 flow 0 中的绝对位置、所在片段序号
+}
+
+// kf8 是组装好的书：正文 flow、骨架与片段表、目录与图片资源
+type kf8 struct {
+    meta      format.Meta
+    html, css []byte
+    skels     []skel
+    chunks    []chunk
+    toc       []tocEntry
+    resources [][]byte
+    cover     int // 封面在资源中的下标，-1 表示没有
+    thumb     int
+    aid       int
+}
+
+type skel struct{ name string; chunks, start, length int }
+
+type chunk struct {
+    insert, file, seq, start, length int
+    selector                          string
+}
+
+func build(b *format.Book) (*kf8, error) {
+    k := &kf8{meta: b.Meta, css: []byte(stylesheet), cover: -1, thumb: -1}
+    if k.meta.Language == "" { k.meta.Language = "zh" }
+    if k.meta.Title == "" { k.meta.Title = "未命名" }
+    var pages []page
+    if b.Cover != nil && len(b.Cover.Data) > 0 {
+        cover, thumb, err := coverImages(b.Cover.Data)
+        if err != nil { return nil, fmt.Errorf("cover image: %w", err) }
+        k.cover, k.thumb = 0, 1
+        k.resources = append(k.resources, cover, thumb)
+        pages = append(pages, page{k.meta.Title, []elem{k.elem("div", `class="cover"`, `<img src="`+embed(0, "image/jpeg")+`" alt="`+esc(k.meta.Title)+`"/>`)}})
+    }
+    n, parent := 0, -1
+    for _, v := range b.Volumes {
+        parent = -1
+        if v.Title != "" {
+            parent = len(k.toc)
+            k.toc = append(k.toc, tocEntry{label: v.Title, file: len(pages), parent: -1})
+            pages = append(pages, page{v.Title, []elem{k.elem("h1", "", esc(v.Title))}})
+        }
+        for _, ch := range v.Chapters {
+            n++
+            title := strings.TrimSpace(ch.Title)
+            if title == "" { title = fmt.Sprintf("第 %d 章", n) }
+            depth := 0
+            if parent >= 0 { depth = 1 }
+            k.toc = append(k.toc, tocEntry{label: title, depth: depth, file: len(pages), parent: parent})
+            pages = append(pages, page{title, k.chapter(title, ch.Paragraphs)})
+        }
+    }
+    if len(pages) == 0 { pages = append(pages, page{k.meta.Title, []elem{k.elem("h1", "", esc(k.meta.Title))}}) }
+    k.layout(pages)
+    return k, nil
+}
+
+// chapter 生成章节的顶层元素：标题与逐段的 <p>，插图加入资源并以 kindle:embed 引用。
+func (k *kf8) chapter(title string, paras []format.Paragraph) []elem {
+    out := []elem{k.elem("h1", "", esc(title))}
+    for _, p := range paras {
+        if p.Image != nil {
+            data, mime, ok := inlineImage(p.Image)
+            if !ok { continue }
+            k.resources = append(k.resources, data)
+            out = append(out, k.elem("p", `class="img"`, `<img src="`+embed(len(k.resources)-1, mime)+`" alt=""/>`))
+            continue
+        }
+        out = append(out, k.elem("p", "", esc(p.Text)))
+    }
+    return out
+}
+
+// elem 生成带 aid 的元素；aid 在整本书内唯一，按 32 进制递增。
+func (k *kf8) elem(tag, attrs, inner string) elem {
+    aid := k.nextAid()
+    if attrs != "" { attrs = " " + attrs }
+    return elem{aid, "<" + tag + attrs + ` aid="` + aid + `">` + inner + "</" + tag + ">"}
+}
+
+func (k *kf8) nextAid() string {
+    s := base32(k.aid, 0)
+    k.aid++
+    return s
+}
+
+// layout 把页面写入 flow 0：每个文件先写骨架，再写按 chunkSize 分组的片段，并记录目录项的位置。
+// 第一个片段插入 body 开始处（选择器 P-body），之后的片段紧跟前一片段的最后一个元素（选择器 S-元素）。
+func (k *kf8) layout(pages []page) {
+    var text strings.Builder
+    first := make([]int, len(pages)) // 每个文件第一个片段的序号
+    for fi, p := range pages {
+        body := k.nextAid()
+        head := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+            `<html xmlns="http://www.w3.org/1999/xhtml"><head><meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>` +
+            "<title>" + esc(p.title) + `</title><link href="kindle:flow:0001?mime=text/css" rel="stylesheet" type="text/css"/></head><body aid="` + body + `">`
+        skeleton := head + "</body></html>"
+        start := text.Len()
+        text.WriteString(skeleton)
+        first[fi] = len(k.chunks)
+        insert, cp := start+len(head), 0
+        selector := "P-//*[@aid='" + body + "']"
+        for i := 0; i < len(p.elems); {
+            var raw strings.Builder
+            j := i
+            for ; j < len(p.elems) && (j == i || raw.Len()+len(p.elems[j].html) <= chunkSize); j++ { raw.WriteString(p.elems[j].html) }
+            k.chunks = append(k.chunks, chunk{insert: insert, file: fi, seq: len(k.chunks), start: cp, length: raw.Len(), selector: selector})
+            text.WriteString(raw.String())
+            insert += raw.Len()
+            cp += raw.Len()
+            selector = "S-//*[@aid='" + p.elems[j-1].aid + "']"
+            i = j
+        }
+        k.skels = append(k.skels, skel{fmt.Sprintf("SKEL%010d", fi), len(k.chunks) - first[fi], start, len(skeleton)})
+    }
+    k.html = []byte(text.String())
+    for i := range k.toc {
+        c := k.chunks[first[k.toc[i].file]]
+        k.toc[i].offset, k.toc[i].fid = c.insert, c.seq
+    }
+}
+
+// embed 返回资源引用，资源序号从 1 开始按 32 进制写 4 位。
+func embed(i int, mime string) string { return "kindle:embed:" + base32(i+1, 4) + "?mime=" + mime }
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUV"
+
+// base32 按 KF8 的 32 进制（0-9A-V）格式化，不足 width 位时补 0。
+func base32(n, width int) string {
+    s := ""
+    for {
+        s = string(digits[n%32]) + s
+        n /= 32
+        if n == 0 { break }
+    }
+    for len(s) < width { s = "0" + s }
+    return s
+}
+
+// esc 转义 XML 特殊字符，并去掉 XML 不允许的控制字符。
+func esc(s string) string {
+    s = strings.Map(func(r rune) rune {
+        if r < 0x20 && r != '\t' && r != '\n' && r != '\r' { return -1 }
+        return r
+    }, s)
+    return html.EscapeString(s)
+}
@@ -0,0 +1,93 @@
+package mobi
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+func TestExportCover(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 60, 80))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cover   []byte
+		wantErr bool
+	}{
+		{"no cover", nil, false},
+		{"png", buf.Bytes(), false},
+		{"undecodable", []byte("<html>not found</html>"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &format.Book{Meta: format.Meta{Title: "测试之书"}, Volumes: []format.Volume{{Chapters: []format.Chapter{format.NewChapter("第1章", "正文")}}}}
+			if tt.cover != nil {
+				b.Cover = &format.Image{Data: tt.cover, MIME: "image/png"}
+			}
+			err := Exporter{ext: "azw3"}.Export(io.Discard, b, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Export: err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 记录在多字节字符中间结束时，字符剩余的字节附在记录末尾，末字节为附加的字节数
+func TestTextRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		extras []int // 每条记录附加的字节数
+	}{
+		{"short", "第1章 正文", []int{0}},
+		{"exact record", strings.Repeat("a", recordSize), []int{0}},
+		{"boundary between runes", strings.Repeat("a", recordSize-3) + "中文", []int{0, 0}},
+		{"3-byte rune, 1 byte in", strings.Repeat("a", recordSize-1) + "中文", []int{2, 0}},
+		{"3-byte rune, 2 bytes in", strings.Repeat("a", recordSize-2) + "中文", []int{1, 0}},
+		{"4-byte rune, 1 byte in", strings.Repeat("a", recordSize-1) + "𠀀", []int{3, 0}},
+		{"4-byte rune, 3 bytes in", strings.Repeat("a", recordSize-3) + "𠀀", []int{1, 0}},
+		{"all CJK", strings.Repeat("中", 3000), []int{2, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := []byte(tt.text)
+			var body []byte
+			var extras []int
+			for pos := 0; pos < len(text); {
+				r, next := textRecord(text, pos)
+				extra := int(r[len(r)-1])
+				n := len(r) - 1 - extra
+				if n > recordSize {
+					t.Fatalf("record at %d holds %d text bytes, want <= %d", pos, n, recordSize)
+				}
+				if next != pos+n {
+					t.Fatalf("record at %d: next = %d, want %d", pos, next, pos+n)
+				}
+				if !bytes.Equal(r[n:len(r)-1], text[next:next+extra]) {
+					t.Fatalf("record at %d: trailing bytes = %x, want %x", pos, r[n:len(r)-1], text[next:next+extra])
+				}
+				if end := next + extra; end < len(text) && !utf8.RuneStart(text[end]) {
+					t.Fatalf("record at %d ends inside a rune", pos)
+				}
+				body = append(body, r[:n]...)
+				extras = append(extras, extra)
+				pos = next
+			}
+			if !bytes.Equal(body, text) {
+				t.Error("records do not reassemble the text")
+			}
+			if !slices.Equal(extras, tt.extras) {
+				t.Errorf("trailing byte counts = %v, want %v", extras, tt.extras)
+			}
+		})
+	}
+}
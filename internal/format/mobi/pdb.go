@@ -0,0 +1,289 @@
+package mobi
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+const (
+    recordSize = 4096
+    null       = 0xFFFFFFFF
+    mobiHeaderLen = 264 // KF8 的 MOBI 头长度，EXTH 紧随其后（记录 0 的偏移 16+264）
+)
+
+// write 组装全部记录并写出 PalmDB。
+func (k *kf8) write(w io.Writer) error {
+    records := [][]byte{nil} // 记录 0 最后生成
+    text := append(append([]byte{}, k.html...), k.css...)
+    textRecords := 0
+    size := 0
+    for pos := 0; pos < len(text); {
+        r, next := textRecord(text, pos)
+        records = append(records, r)
+        size += len(r)
+        textRecords++
+        pos = next
+    }
+    firstNonText := len(records)
+    // 正文记录之后按 4 字节对齐
+    if size%4 != 0 { records = append(records, make([]byte, 4-size%4)); firstNonText++ }
+
+    chunkIdx := len(records)
+    records = append(records, k.chunkIndex()...)
+    skelIdx := len(records)
+    records = append(records, k.skelIndex()...)
+    ncxIdx := uint32(null)
+    if len(k.toc) > 0 {
+        ncxIdx = uint32(len(records))
+        records = append(records, k.ncxIndex()...)
+    }
+    firstResource := uint32(null)
+    if len(k.resources) > 0 {
+        firstResource = uint32(len(records))
+        records = append(records, k.resources...)
+    }
+    fdstIdx := len(records)
+    var fdst bytes.Buffer
+    fdst.WriteString("FDST")
+    be32(&fdst, 12, 2, 0, uint32(len(k.html)), uint32(len(k.html)), uint32(len(text)))
+    records = append(records, fdst.Bytes())
+    flisIdx := len(records)
+    records = append(records, flis)
+    fcisIdx := len(records)
+    records = append(records, fcis(len(text)))
+    records = append(records, []byte{0xe9, 0x8e, '\r', '\n'}) // 结束记录
+
+    h := header{
+        textLength: len(text), textRecords: textRecords, firstNonText: firstNonText, firstResource: firstResource,
+        chunkIdx: chunkIdx, skelIdx: skelIdx, ncxIdx: ncxIdx, fdstIdx: fdstIdx, flisIdx: flisIdx, fcisIdx: fcisIdx,
+    }
+    records[0] = k.record0(h)
+    return writePDB(w, k.meta.Title, records)
+}
+
+// textRecord 取从 pos 开始的一条正文记录（最多 4096 字节）。记录在多字节字符中间结束时，
+// 把字符剩余的字节附在记录末尾，最后一个字节记录附加的字节数（MOBI 头额外数据标志的第 0 位）。
+func textRecord(text []byte, pos int) ([]byte, int) {
+    end := pos + recordSize
+    if end > len(text) { end = len(text) }
+    extra := 0
+    // 回退到记录末尾所在字符的起始字节，计算它超出记录的字节数
+    for i := end - 1; i >= pos && i >= end-4; i-- {
+        if !utf8.RuneStart(text[i]) { continue }
+        _, n := utf8.DecodeRune(text[i:])
+        if i+n > end { extra = i + n - end }
+        break
+    }
+    r := append([]byte{}, text[pos:end]...)
+    r = append(r, text[end:end+extra]...)
+    return append(r, byte(extra)), end
+}
+
+func (k *kf8) chunkIndex() [][]byte {
+    sels := make([]string, len(k.chunks))
+    for i, c := range k.chunks { sels[i] = c.selector }
+    cncx, off := cncxTable(sels)
+    entries := make([]entry, len(k.chunks))
+    for i, c := range k.chunks {
+        entries[i] = entry{fmt.Sprintf("%010d", c.insert), map[string][]int{
+            "cncx": {off[c.selector]}, "file": {c.file}, "seq": {c.seq}, "geometry": {c.start, c.length},
+        }}
+    }
+    return index(chunkTags, entries, cncx)
+}
+
+func (k *kf8) skelIndex() [][]byte {
+    entries := make([]entry, len(k.skels))
+    for i, s := range k.skels {
+        // 与 kindlegen 一致，每个值重复写两遍
+        entries[i] = entry{s.name, map[string][]int{
+            "chunk_count": {s.chunks, s.chunks}, "geometry": {s.start, s.length, s.start, s.length},
+        }}
+    }
+    return index(skelTags, entries, nil)
+}
+
+// ncxIndex 生成目录索引。Kindle 要求条目按（层级，位置）排序，上下级关系用排序后的下标表示；
+// 长度为到下一个同级或更高级条目（没有时到正文末尾）的距离。
+func (k *kf8) ncxIndex() [][]byte {
+    n := len(k.toc)
+    length := make([]int, n)
+    for i, e := range k.toc {
+        end := len(k.html)
+        for j := i + 1; j < n; j++ {
+            if k.toc[j].depth <= e.depth { end = k.toc[j].offset; break }
+        }
+        length[i] = end - e.offset
+    }
+    order := make([]int, n)
+    for i := range order { order[i] = i }
+    sort.SliceStable(order, func(a, b int) bool {
+        ea, eb := k.toc[order[a]], k.toc[order[b]]
+        if ea.depth != eb.depth { return ea.depth < eb.depth }
+        return ea.offset < eb.offset
+    })
+    pos := make([]int, n) // 原下标 -> 排序后下标
+    for i, o := range order { pos[o] = i }
+    first, last := make([]int, n), make([]int, n)
+    for i := range first { first[i] = -1 }
+    for i, e := range k.toc {
+        if e.parent < 0 { continue }
+        if first[e.parent] < 0 { first[e.parent] = pos[i] }
+        last[e.parent] = pos[i]
+    }
+    labels := make([]string, n)
+    for i, e := range k.toc { labels[i] = e.label }
+    cncx, off := cncxTable(labels)
+    width := len(fmt.Sprint(n - 1))
+    if width < 4 { width = 4 }
+    entries := make([]entry, n)
+    for i, o := range order {
+        e := k.toc[o]
+        v := map[string][]int{
+            "offset": {e.offset}, "length": {length[o]}, "label": {off[e.label]}, "depth": {e.depth}, "pos_fid": {e.fid, 0},
+        }
+        if e.parent >= 0 { v["parent"] = []int{pos[e.parent]} }
+        if first[o] >= 0 { v["first_child"], v["last_child"] = []int{first[o]}, []int{last[o]} }
+        entries[i] = entry{fmt.Sprintf("%0*d", width, i), v}
+    }
+    return index(ncxTags, entries, cncx)
+}
+
+type header struct {
+    textLength, textRecords, firstNonText            int
+    chunkIdx, skelIdx, fdstIdx, flisIdx, fcisIdx     int
+    ncxIdx, firstResource                            uint32
+}
+
+// record0 生成 PalmDOC 头、MOBI 头（KF8，版本 8）、EXTH 与完整书名。
+func (k *kf8) record0(h header) []byte {
+    exth := k.exth()
+    title := []byte(k.meta.Title)
+    var b bytes.Buffer
+    // PalmDOC 头：不压缩、正文长度、正文记录数、记录大小
+    binary.Write(&b, binary.BigEndian, []uint16{1, 0})
+    be32(&b, uint32(h.textLength))
+    binary.Write(&b, binary.BigEndian, []uint16{uint16(h.textRecords), recordSize, 0, 0})
+    b.WriteString("MOBI")
+    be32(&b, mobiHeaderLen, 2, 65001, uid(), 8) // 头长度、书籍类型、UTF-8、唯一 ID、文件版本
+    for i := 0; i < 10; i++ { be32(&b, null) } // orth、infl 与 8 个额外索引
+    be32(&b, uint32(h.firstNonText), uint32(16+mobiHeaderLen+len(exth)), uint32(len(title)), langCode(k.meta.Language), 0, 0, 8, h.firstResource)
+    be32(&b, 0, 0, 0, 0) // huffman
+    be32(&b, 0x50)       // EXTH 标志
+    b.Write(make([]byte, 32))
+    be32(&b, null, null, 0, 0, 0) // 未知索引与 DRM
+    b.Write(make([]byte, 8))
+    be32(&b, uint32(h.fdstIdx), 2, uint32(h.fcisIdx), 1, uint32(h.flisIdx), 1)
+    b.Write(make([]byte, 8))
+    be32(&b, null, 0, null, null) // SRCS 与未知字段
+    be32(&b, 1)                   // 额外数据标志：正文记录末尾有多字节字符的续接字节
+    be32(&b, h.ncxIdx, uint32(h.chunkIdx), uint32(h.skelIdx), null, null) // NCX、片段、骨架、DATP、guide 索引
+    be32(&b, null, 0, null, 0)
+    b.Write(exth)
+    b.Write(title)
+    b.Write(make([]byte, 2))
+    return align(b.Bytes())
+}
+
+// exth 生成 EXTH 元数据：作者、出版者（书源）、简介、语言、标识与封面。
+func (k *kf8) exth() []byte {
+    var recs bytes.Buffer
+    n := 0
+    str := func(t uint32, s string) {
+        if s == "" { return }
+        be32(&recs, t, uint32(8+len(s)))
+        recs.WriteString(s)
+        n++
+    }
+    num := func(t, v uint32) { be32(&recs, t, 12, v); n++ }
+    m := k.meta
+    str(100, m.Author)
+    str(101, m.Publisher)
+    str(103, m.Description)
+    str(106, time.Now().Format("2006-01-02"))
+    id := m.Identifier
+    if id == "" { id = fmt.Sprintf("%08x", uid()) }
+    str(113, id)
+    str(504, id)
+    str(501, "EBOK") // 按书籍而不是个人文档归类
+    str(503, m.Title)
+    str(524, m.Language)
+    str(525, "horizontal-lr")
+    num(125, uint32(len(k.resources)))
+    if k.cover >= 0 {
+        num(201, uint32(k.cover))
+        num(203, 0)
+        num(202, uint32(k.thumb))
+        str(129, "kindle:embed:"+base32(k.thumb, 4))
+    }
+    for i, v := range []uint32{201, 2, 9, 0} { num(uint32(204+i), v) } // 生成工具，与 kindlegen 2.9 相同
+    var b bytes.Buffer
+    b.WriteString("EXTH")
+    be32(&b, uint32(12+recs.Len()), uint32(n))
+    b.Write(recs.Bytes())
+    // 至少补 1 个字节再对齐
+    b.Write(make([]byte, 4-b.Len()%4))
+    return b.Bytes()
+}
+
+var unsafeName = regexp.MustCompile(`[^-A-Za-z0-9]+`)
+
+// writePDB 写出 PalmDB 头、记录偏移表与全部记录。
+func writePDB(w io.Writer, title string, records [][]byte) error {
+    name := strings.Trim(unsafeName.ReplaceAllString(title, "_"), "_")
+    if name == "" { name = "book" }
+    if len(name) > 31 { name = name[:31] }
+    var b bytes.Buffer
+    b.WriteString(name)
+    b.Write(make([]byte, 32-len(name)))
+    now := uint32(time.Now().Unix())
+    binary.Write(&b, binary.BigEndian, []uint16{0, 0})
+    be32(&b, now, now, 0, 0, 0, 0)
+    b.WriteString("BOOKMOBI")
+    be32(&b, uint32(2*len(records)-1), 0)
+    binary.Write(&b, binary.BigEndian, uint16(len(records)))
+    offset := b.Len() + 8*len(records) + 2
+    for i, r := range records {
+        be32(&b, uint32(offset), uint32(2*i)) // 属性字节为 0，唯一 ID 占低 3 字节
+        offset += len(r)
+    }
+    b.Write([]byte{0, 0})
+    if _, err := w.Write(b.Bytes()); err != nil { return err }
+    for _, r := range records {
+        if _, err := w.Write(r); err != nil { return err }
+    }
+    return nil
+}
+
+var flis = []byte("FLIS\x00\x00\x00\x08\x00\x41\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\x00\x01\x00\x03\x00\x00\x00\x03\x00\x00\x00\x01\xff\xff\xff\xff")
+
+func fcis(textLength int) []byte {
+    b := []byte("FCIS\x00\x00\x00\x14\x00\x00\x00\x10\x00\x00\x00\x02\x00\x00\x00\x00")
+    b = binary.BigEndian.AppendUint32(b, uint32(textLength))
+    b = append(b, "\x00\x00\x00\x00\x00\x00\x00\x28\x00\x00\x00\x00\x00\x00\x00\x28\x00\x00\x00\x08\x00\x01\x00\x01\x00\x00\x00\x00"...)
+    return b
+}
+
+// langCode 返回 MOBI 头中的语言代码（主语言 | 地区 << 10），未知语言为 0。
+func langCode(lang string) uint32 {
+    main, region, _ := strings.Cut(strings.ToLower(lang), "-")
+    codes := map[string]uint32{"zh": 0x04, "en": 0x09, "ja": 0x11, "ko": 0x12, "ru": 0x19, "de": 0x07, "fr": 0x0c}
+    regions := map[string]uint32{"cn": 2, "hans": 2, "tw": 1, "hant": 1, "hk": 3, "sg": 4, "us": 1, "gb": 2}
+    c, ok := codes[main]
+    if !ok { return 0 }
+    return c | regions[region]<<10
+}
+
+func uid() uint32 {
+    var b [4]byte
+    rand.Read(b[:])
+    return binary.BigEndian.Uint32(b[:])
+}
@@ -1,23 +1,148 @@
 package pdf
 
 import (
+    "bytes"
+    "fmt"
+    "io"
+    "net/http"
+    "strings"
+
     "github.com/jung-kurt/gofpdf"
+    "github.com/sreio/go-novel/internal/fonts"
+    "github.com/sreio/go-novel/internal/format"
 )
 
-type Meta struct { Title, Author string }
-type Chapter struct { Title, Content string }
+func init() { format.Register(Exporter{}) }
+
+// Exporter 导出 PDF：嵌入中文字体，每章另起一页，章节（与分卷）标题写入书签。
+type Exporter struct{}
+
+func (Exporter) Name() string      { return "pdf" }
+func (Exporter) Extension() string { return "pdf" }
+func (Exporter) MIMEType() string  { return "application/pdf" }
+func (Exporter) UsesCover() bool   { return true }
+
+func (Exporter) Options() []format.Option {
+    return []format.Option{
+        {Name: "font", Usage: "嵌入的中文字体（TrueType 轮廓的 TTF/TTC），默认取 NOVEL_FONT 或系统字体"},
+        {Name: "page", Default: "a4", Usage: "页面预设，6in 为 6 寸墨水屏", Values: PageSizes},
+        {Name: "margin", Usage: "页边距（毫米），默认使用页面预设"},
+        {Name: "font-size", Usage: "正文字号（pt），默认使用页面预设"},
+        {Name: "line-spacing", Default: "1.6", Usage: "行距，字号的倍数"},
+    }
+}
+
+// page 是页面预设：纸张尺寸、默认边距（毫米）与正文字号（pt）
+type page struct { w, h, margin, fontSize float64 }
+
+var pages = map[string]page{
+    "a4":  {210, 297, 20, 12},
+    "a5":  {148, 210, 15, 11},
+    "6in": {90.6, 122.4, 5, 10}, // 6 寸 600×800 墨水屏
+}
+
+// PageSizes 是支持的页面预设。
+var PageSizes = []string{"a4", "a5", "6in"}
+
+const ptToMM = 25.4 / 72
+
+// Check 确认能找到可以嵌入的中文字体：没有字体时 PDF 里的中文全部显示为空白，不如在下载前报错。
+func (Exporter) Check(opts format.Options) error {
+    _, err := cjkFont(opts)
+    return err
+}
+
+func cjkFont(opts format.Options) ([]byte, error) {
+    _, font, err := fonts.TrueType(opts["font"])
+    if err != nil { return nil, fmt.Errorf("pdf: %w (or --option font=<file>)", err) }
+    return font, nil
+}
 
-func Save(path string, meta Meta, chapters []Chapter) error {
-    pdf := gofpdf.New("P", "mm", "A4", "")
-    pdf.SetTitle(meta.Title, false)
-    pdf.SetAuthor(meta.Author, false)
-    for _, ch := range chapters {
+func (Exporter) Export(w io.Writer, b *format.Book, opts format.Options) error {
+    name := strings.ToLower(opts.String("page", "a4"))
+    size, ok := pages[name]
+    if !ok { return fmt.Errorf("unknown page size: %s", name) }
+    margin, err := opts.Float("margin", size.margin)
+    if err != nil { return err }
+    fontSize, err := opts.Float("font-size", size.fontSize)
+    if err != nil { return err }
+    spacing, err := opts.Float("line-spacing", 1.6)
+    if err != nil { return err }
+    if margin <= 0 { margin = size.margin }
+    if fontSize <= 0 { fontSize = size.fontSize }
+    if spacing <= 0 { spacing = 1.6 }
+
+    pdf := gofpdf.NewCustom(&gofpdf.InitType{OrientationStr: "P", UnitStr: "mm", Size: gofpdf.SizeType{Wd: size.w, Ht: size.h}})
+    pdf.SetMargins(margin, margin, margin)
+    pdf.SetAutoPageBreak(true, margin)
+    pdf.SetTitle(b.Title, true)
+    pdf.SetAuthor(b.Author, true)
+    pdf.SetCreator("go-novel", false)
+    // 中文字体没有粗体，标题只放大字号
+    font, err := cjkFont(opts)
+    if err != nil { return err }
+    pdf.AddUTF8FontFromBytes("cjk", "", font)
+    if pdf.Err() { return pdf.Error() }
+    family, titleStyle, indent := "cjk", "", "　　"
+    if b.Cover != nil && len(b.Cover.Data) > 0 { addCover(pdf, b.Cover.Data) }
+
+    titleSize := fontSize * 1.4
+    lineH := fontSize * spacing * ptToMM
+    heading := func(title string, level int) {
         pdf.AddPage()
-        pdf.SetFont("Arial", "B", 16)
-        pdf.MultiCell(0, 10, ch.Title, "", "L", false)
-        pdf.Ln(4)
-        pdf.SetFont("Arial", "", 12)
-        pdf.MultiCell(0, 6, ch.Content, "", "L", false)
+        pdf.SetFont(family, titleStyle, titleSize)
+        // 书签（大纲）要在设置字体之后添加，UTF-8 字体下标题才会按 UTF-16 写入
+        pdf.Bookmark(title, level, -1)
+        pdf.MultiCell(0, titleSize*1.5*ptToMM, title, "", "C", false)
+    }
+    n, images := 0, 0
+    for _, v := range b.Volumes {
+        level := 0
+        if v.Title != "" { heading(v.Title, 0); level = 1 }
+        for _, ch := range v.Chapters {
+            n++
+            title := strings.TrimSpace(ch.Title)
+            if title == "" { title = fmt.Sprintf("第 %d 章", n) }
+            heading(title, level)
+            pdf.Ln(lineH)
+            pdf.SetFont(family, "", fontSize)
+            for _, p := range ch.Paragraphs {
+                if p.Image != nil { images++; addImage(pdf, fmt.Sprintf("img%d", images), p.Image.Data); continue }
+                pdf.MultiCell(0, lineH, indent+p.Text, "", "L", false)
+            }
+        }
     }
-    return pdf.OutputFileAndClose(path)
+    return pdf.Output(w)
+}
+
+// addImage 插入正文插图：宽度不超过版心，按比例缩放；图片损坏时跳过。
+func addImage(pdf *gofpdf.Fpdf, name string, data []byte) {
+    typ := imageType(data)
+    info := pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: typ}, bytes.NewReader(data))
+    if pdf.Err() { pdf.ClearError(); return }
+    if info == nil || info.Width() == 0 { return }
+    left, _, right, _ := pdf.GetMargins()
+    pw, _ := pdf.GetPageSize()
+    w := pw - left - right
+    if iw := info.Width() * 25.4 / 96; iw < w { w = iw }
+    pdf.ImageOptions(name, left+(pw-left-right-w)/2, -1, w, 0, true, gofpdf.ImageOptions{ImageType: typ}, 0, "")
+}
+
+func imageType(data []byte) string {
+    if http.DetectContentType(data) == "image/png" { return "PNG" }
+    return "JPG"
+}
+
+// addCover 把封面图片按比例缩放、居中放在第一页。
+func addCover(pdf *gofpdf.Fpdf, data []byte) {
+    typ := imageType(data)
+    info := pdf.RegisterImageOptionsReader("cover", gofpdf.ImageOptions{ImageType: typ}, bytes.NewReader(data))
+    if pdf.Err() { pdf.ClearError(); return } // 封面损坏时跳过，不影响正文
+    if info == nil || info.Width() == 0 || info.Height() == 0 { return }
+    pdf.AddPage()
+    pw, ph := pdf.GetPageSize()
+    scale := pw / info.Width()
+    if s := ph / info.Height(); s < scale { scale = s }
+    w, h := info.Width()*scale, info.Height()*scale
+    pdf.ImageOptions("cover", (pw-w)/2, (ph-h)/2, w, h, false, gofpdf.ImageOptions{ImageType: typ}, 0, "")
 }
@@ -0,0 +1,299 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChapterWriter 逐章写出一本书：Begin 写入元数据与封面，之后按顺序调用 BeginVolume / WriteChapter，
+// 最后调用 Finish 收尾（写目录等）。写入的内容立即交给底层 io.Writer，不在内存中保留正文。
+type ChapterWriter interface {
+	Begin(b *Book) error // 只使用元数据与封面，b.Volumes 被忽略
+	BeginVolume(title string) error
+	WriteChapter(ch Chapter) error
+	Finish() error
+}
+
+// StreamExporter 由支持流式导出的格式实现：章节到达即写出，内存占用与书的大小无关。
+type StreamExporter interface {
+	Exporter
+	NewWriter(w io.Writer, opts Options) (ChapterWriter, error)
+}
+
+// Splitter 由可以把一本书拆分为多个文件的流式格式实现，如按章节数或大小拆分的 TXT。
+type Splitter interface {
+	StreamExporter
+	// Split 返回 opts 对应的拆分规则，不拆分时返回 nil；选项有误时返回错误。
+	Split(opts Options) (*SplitRule, error)
+}
+
+// SplitWriter 是 Splitter 的写入器可以实现的接口：拆分时 File 先调用 Finish 结束当前文件，
+// 再用 Reset 把同一个写入器切换到下一个文件并重新调用 Begin，章节序号、当前卷名等状态得以延续。
+// 没有实现时每个文件使用 NewWriter 创建的新写入器。
+type SplitWriter interface {
+	ChapterWriter
+	Reset(w io.Writer)
+}
+
+// SplitRule 是拆分规则：写下一章或开始新的一卷之前，满足任一条件就换到新文件。
+type SplitRule struct {
+	Chapters int   // 每个文件的章节数
+	Bytes    int64 // 每个文件的大小，达到后在章节边界切换，单个文件可能略微超出
+	Volume   bool  // 每卷一个文件
+}
+
+// Splits 判断按 opts 导出时是否会拆分为多个文件。
+func Splits(e Exporter, opts Options) bool {
+	rule, err := splitRule(e, opts)
+	return err == nil && rule != nil
+}
+
+func splitRule(e Exporter, opts Options) (*SplitRule, error) {
+	if s, ok := e.(Splitter); ok {
+		return s.Split(opts)
+	}
+	return nil, nil
+}
+
+// SplitPath 返回拆分导出的第 n 个文件（从 1 开始）的路径：第一个文件沿用 path，
+// 之后在扩展名前加序号，如 书名_002.txt。
+func SplitPath(path string, n int) string {
+	if n <= 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// WriteBook 用 ChapterWriter 写出整本书；流式导出器的 Export 可以直接基于它实现。
+func WriteBook(cw ChapterWriter, b *Book) error {
+	if err := cw.Begin(b); err != nil {
+		return err
+	}
+	for _, v := range b.Volumes {
+		if v.Title != "" {
+			if err := cw.BeginVolume(v.Title); err != nil {
+				return err
+			}
+		}
+		for _, ch := range v.Chapters {
+			if err := cw.WriteChapter(ch); err != nil {
+				return err
+			}
+		}
+	}
+	return cw.Finish()
+}
+
+// File 是正在导出的文件。流式格式的章节写入后即落盘（先写到 PartPath，完成后改名，
+// 中断时不会覆盖已有的完整文件）；其它格式先在内存中收集章节，Close 时一次导出。
+// 按 SplitRule 拆分时依次写入 SplitPath 对应的多个文件，Close 时一起改名。
+type File struct {
+	path  string
+	part  string // 当前正在写入的未完成文件
+	parts []string
+	e     Exporter
+	opts  Options
+	f     *os.File
+	w     *countingWriter
+	cw    ChapterWriter // 流式格式
+	meta  *Book         // 流式格式的元数据与封面，拆分出的每个文件都重新写入
+	rule  *SplitRule
+	n     int   // 当前文件已写入的章节数
+	book  *Book // 非流式格式收集的章节
+}
+
+// Create 开始把书导出到 path；b 只提供元数据与封面，章节通过 WriteChapter 按顺序写入。
+func Create(path string, e Exporter, b *Book, opts Options) (*File, error) {
+	file := &File{path: path, e: e, opts: opts}
+	se, ok := e.(StreamExporter)
+	if !ok {
+		book := *b
+		book.Volumes = nil
+		file.book = &book
+		return file, nil
+	}
+	rule, err := splitRule(e, opts)
+	if err != nil {
+		return nil, err
+	}
+	file.meta, file.rule = b, rule
+	if err := file.next(se); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// next 开始写入下一个文件。
+func (f *File) next(se StreamExporter) error {
+	part := PartPath(SplitPath(f.path, len(f.parts)+1))
+	out, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	w := &countingWriter{w: out}
+	var cw ChapterWriter
+	if sw, ok := f.cw.(SplitWriter); ok {
+		sw.Reset(w)
+		cw = sw
+	} else {
+		cw, err = se.NewWriter(w, f.opts)
+	}
+	if err == nil {
+		err = cw.Begin(f.meta)
+	}
+	if err != nil {
+		out.Close()
+		os.Remove(part)
+		return err
+	}
+	f.f, f.w, f.cw, f.part, f.n = out, w, cw, part, 0
+	f.parts = append(f.parts, part)
+	return nil
+}
+
+// split 在满足拆分规则时结束当前文件并开始下一个；volume 表示即将开始新的一卷。
+func (f *File) split(volume bool) error {
+	r := f.rule
+	if r == nil || f.n == 0 {
+		return nil
+	}
+	if !(volume && r.Volume) && !(r.Chapters > 0 && f.n >= r.Chapters) && !(r.Bytes > 0 && f.w.n >= r.Bytes) {
+		return nil
+	}
+	if err := f.finish(); err != nil {
+		return err
+	}
+	return f.next(f.e.(StreamExporter))
+}
+
+// PartPath 返回流式导出时未完成文件的路径：扩展名前插入 .part，如 书名.part.epub，阅读器仍能按格式打开。
+func PartPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".part" + ext
+}
+
+// Streaming 判断章节是否边写边落盘。
+func (f *File) Streaming() bool { return f.cw != nil }
+
+// BeginVolume 开始新的一卷。
+func (f *File) BeginVolume(title string) error {
+	if f.cw != nil {
+		if err := f.split(true); err != nil {
+			return err
+		}
+		return f.cw.BeginVolume(title)
+	}
+	f.book.Volumes = append(f.book.Volumes, Volume{Title: title})
+	return nil
+}
+
+// WriteChapter 写入下一章。
+func (f *File) WriteChapter(ch Chapter) error {
+	if f.cw != nil {
+		if err := f.split(false); err != nil {
+			return err
+		}
+		f.n++
+		return f.cw.WriteChapter(ch)
+	}
+	if len(f.book.Volumes) == 0 {
+		f.book.Volumes = []Volume{{}}
+	}
+	v := &f.book.Volumes[len(f.book.Volumes)-1]
+	v.Chapters = append(v.Chapters, ch)
+	return nil
+}
+
+// Close 完成导出：流式格式收尾后把未完成文件改名为目标路径，非流式格式此时才导出。
+// 同一路径上次拆分出的文件比这次多时，多出的旧文件会被删除。
+func (f *File) Close() error {
+	if f.cw == nil {
+		return Save(f.path, f.e, f.book, f.opts)
+	}
+	if err := f.finish(); err != nil {
+		f.remove()
+		return err
+	}
+	for i, part := range f.parts {
+		if err := os.Rename(part, SplitPath(f.path, i+1)); err != nil {
+			return err
+		}
+	}
+	removeSplits(f.path, len(f.parts))
+	return nil
+}
+
+// removeSplits 删除 path 第 n 个之后的拆分文件，即上次导出拆分得更多时留下的 SplitPath(path, n+1)、n+2…
+func removeSplits(path string, n int) {
+	for i := max(n, 1) + 1; ; i++ {
+		if err := os.Remove(SplitPath(path, i)); err != nil {
+			return
+		}
+	}
+}
+
+// Paths 返回导出的文件路径；拆分导出时依次为各个文件，否则只有目标路径。
+func (f *File) Paths() []string {
+	if len(f.parts) <= 1 {
+		return []string{f.path}
+	}
+	out := make([]string, len(f.parts))
+	for i := range f.parts {
+		out[i] = SplitPath(f.path, i+1)
+	}
+	return out
+}
+
+// Interrupt 在下载失败或中断时调用，返回不完整文件的路径：流式格式照常收尾，
+// 已写入的章节组成一个可以打开的 PartPath 文件；非流式格式不生成文件，返回空串。
+// 拆分导出时返回最后一个未完成文件，之前的文件同样以 .part 结尾保留在同一目录。
+// 两种情况下目标路径上已有的文件都保持不变。
+func (f *File) Interrupt() (string, error) {
+	if f.cw == nil {
+		return "", nil
+	}
+	if err := f.finish(); err != nil {
+		f.remove()
+		return "", err
+	}
+	return f.part, nil
+}
+
+func (f *File) finish() error {
+	err := f.cw.Finish()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Abort 放弃导出并删除未完成的文件，用于导出本身出错的情况。
+func (f *File) Abort() {
+	if f.cw == nil {
+		return
+	}
+	f.finish()
+	f.remove()
+}
+
+func (f *File) remove() {
+	for _, part := range f.parts {
+		os.Remove(part)
+	}
+}
+
+// countingWriter 统计写入的字节数，用于按大小拆分
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,109 @@
+package txt
+
+import (
+    "bytes"
+    "fmt"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/sreio/go-novel/internal/format"
+)
+
+// 默认的章节与卷标题：行首为“第…章/回/节”“第…卷/部/集”，数字可以是阿拉伯数字或中文数字
+const (
+    DefaultTitlePattern  = `^(第[0-9０-９零〇一二两三四五六七八九十百千万]+[章回节]|序章|楔子|引子|尾声|番外)`
+    DefaultVolumePattern = `^第[0-9０-９零〇一二两三四五六七八九十百千万]+[卷部集]`
+)
+
+// Importer 读取 TXT：按标题正则识别章节与卷，正文按行分段（去掉全角空格等缩进）。
+// 第一个标题之前的内容作为“前言”一章。适用于本项目导出的 TXT，也适用于常见的网络小说 TXT。
+type Importer struct{}
+
+func (Importer) Name() string         { return "txt" }
+func (Importer) Extensions() []string { return []string{"txt"} }
+
+func (Importer) Options() []format.Option {
+    return []format.Option{
+        {Name: "charset", Default: "auto", Usage: "文件编码，auto 按 BOM 与内容识别 UTF-8 或 GB18030（兼容 GBK）", Values: append([]string{"auto"}, Charsets...)},
+        {Name: "title-pattern", Default: DefaultTitlePattern, Usage: "章节标题的正则表达式，对去掉首尾空白的每一行匹配，如 ^第.+章；含命名分组 title 时只取该分组作为标题，如 ^=+ (?P<title>.+) =+$"},
+        {Name: "volume-pattern", Default: DefaultVolumePattern, Usage: "卷名的正则表达式，为 none 时不识别分卷；命名分组 title 同上"},
+        {Name: "max-title", Default: "40", Usage: "标题行的最大字数，更长的行即使匹配也视为正文"},
+    }
+}
+
+func (Importer) Import(data []byte, opts format.Options) (*format.Book, error) {
+    text, err := decode(data, strings.ToLower(opts.String("charset", "auto")))
+    if err != nil { return nil, err }
+    title, err := regexp.Compile(opts.String("title-pattern", DefaultTitlePattern))
+    if err != nil { return nil, fmt.Errorf("option title-pattern: %w", err) }
+    var volume *regexp.Regexp
+    if p := opts.String("volume-pattern", DefaultVolumePattern); p != "none" {
+        if volume, err = regexp.Compile(p); err != nil { return nil, fmt.Errorf("option volume-pattern: %w", err) }
+    }
+    maxTitle, err := opts.Int("max-title", 40)
+    if err != nil { return nil, err }
+
+    b := &format.Book{Meta: format.Meta{Language: "zh"}}
+    var heading string // 当前章节的标题
+    var body []string
+    started := false
+    flush := func() {
+        content := strings.Join(body, "\n")
+        body = nil
+        if !started && strings.TrimSpace(content) == "" { return }
+        if !started { heading = "前言" }
+        if len(b.Volumes) == 0 { b.Volumes = []format.Volume{{}} }
+        v := &b.Volumes[len(b.Volumes)-1]
+        v.Chapters = append(v.Chapters, format.NewChapter(heading, content))
+    }
+    chapters := 0
+    for _, line := range strings.Split(text, "\n") {
+        t := strings.TrimSpace(line)
+        if t != "" && utf8.RuneCountInString(t) <= maxTitle {
+            if title.MatchString(t) {
+                flush()
+                heading, started = titleOf(title, t), true
+                chapters++
+                continue
+            }
+            if volume != nil && volume.MatchString(t) {
+                flush()
+                // 卷名之后、下一章之前的内容同样作为“前言”一章
+                started = false
+                b.Volumes = append(b.Volumes, format.Volume{Title: titleOf(volume, t)})
+                continue
+            }
+        }
+        body = append(body, line)
+    }
+    flush()
+    if chapters == 0 { return nil, fmt.Errorf("no chapter title matches %q, set the title-pattern option", title) }
+    return b, nil
+}
+
+// titleOf 返回标题行中的标题：正则含命名分组 title 且匹配到内容时取该分组，去掉导出模板加上的装饰；否则取整行。
+func titleOf(re *regexp.Regexp, line string) string {
+    i := re.SubexpIndex("title")
+    if i < 0 { return line }
+    if m := re.FindStringSubmatch(line); m != nil && strings.TrimSpace(m[i]) != "" { return strings.TrimSpace(m[i]) }
+    return line
+}
+
+// decode 按编码转为 UTF-8 并统一换行符；UTF-8 BOM 总是优先。
+func decode(data []byte, charset string) (string, error) {
+    if bom := []byte("\uFEFF"); bytes.HasPrefix(data, bom) {
+        data, charset = data[len(bom):], "utf-8"
+    }
+    if charset == "auto" {
+        charset = "gb18030"
+        if utf8.Valid(data) { charset = "utf-8" }
+    }
+    if enc, ok := encodings[charset]; ok {
+        out, err := enc.NewDecoder().Bytes(data)
+        if err != nil { return "", fmt.Errorf("decode %s: %w", charset, err) }
+        data = out
+    }
+    text := strings.ReplaceAll(string(data), "\r\n", "\n")
+    return strings.ReplaceAll(text, "\r", "\n"), nil
+}
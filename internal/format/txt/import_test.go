@@ -0,0 +1,73 @@
+package txt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+// 本项目导出的 TXT 重新导入后得到同样的卷、章节与段落
+func TestImportRoundTrip(t *testing.T) {
+	ch := func(title string) format.Chapter {
+		return format.NewChapter(title, "这是"+title+"的正文\n第二段 <标记> & 符号")
+	}
+	book := &format.Book{Volumes: []format.Volume{
+		{Chapters: []format.Chapter{ch("楔子")}},
+		{Title: "第一卷 初入江湖", Chapters: []format.Chapter{ch("第1章 出山"), ch("第2章 下山")}},
+		{Title: "第二卷 名动天下", Chapters: []format.Chapter{ch("第3章 归来"), ch("番外 旧事")}},
+	}}
+	tests := []struct {
+		name       string
+		exportOpts format.Options
+		importOpts format.Options
+	}{
+		{"defaults", nil, nil},
+		{
+			name:       "title template and fullwidth indent",
+			exportOpts: format.Options{"title": "==== {n}. {title} ====", "indent": "fullwidth"},
+			importOpts: format.Options{"title-pattern": `^==== \d+\. (?P<title>.+) ====$`},
+		},
+		{
+			name:       "volume template",
+			exportOpts: format.Options{"title": "【{title}】", "volume-title": "卷{n}：{title}", "indent": "fullwidth", "newline": "crlf"},
+			importOpts: format.Options{"title-pattern": `^【(?P<title>.+)】$`, "volume-pattern": `^卷\d+：(?P<title>.+)$`},
+		},
+		{
+			name:       "gbk with auto detection",
+			exportOpts: format.Options{"charset": "gbk", "indent": "fullwidth"},
+			importOpts: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (Exporter{}).Export(&buf, book, tt.exportOpts); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Importer{}.Import(buf.Bytes(), tt.importOpts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Volumes) != len(book.Volumes) {
+				t.Fatalf("imported %d volumes, want %d:\n%s", len(got.Volumes), len(book.Volumes), buf.Bytes())
+			}
+			for i, want := range book.Volumes {
+				v := got.Volumes[i]
+				if v.Title != want.Title {
+					t.Errorf("volume %d title = %q, want %q", i, v.Title, want.Title)
+				}
+				if len(v.Chapters) != len(want.Chapters) {
+					t.Errorf("volume %d has %d chapters, want %d", i, len(v.Chapters), len(want.Chapters))
+					continue
+				}
+				for j, wc := range want.Chapters {
+					c := v.Chapters[j]
+					if c.Title != wc.Title || c.Text() != wc.Text() {
+						t.Errorf("volume %d chapter %d = %q %q, want %q %q", i, j, c.Title, c.Text(), wc.Title, wc.Text())
+					}
+				}
+			}
+		})
+	}
+}
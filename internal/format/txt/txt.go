@@ -1,17 +1,213 @@
 package txt
 
 import (
+    "bufio"
+    "fmt"
+    "io"
     "os"
+    "strconv"
+    "strings"
+
+    "github.com/sreio/go-novel/internal/format"
+    "golang.org/x/text/encoding"
+    "golang.org/x/text/encoding/simplifiedchinese"
+    "golang.org/x/text/encoding/traditionalchinese"
 )
 
-type Chapter struct { Title, Content string }
+func init() {
+    format.Register(Exporter{})
+    format.RegisterImporter(Importer{})
+}
+
+// Exporter 导出纯文本：每章为标题、空行、正文，章节之间空一行；有分卷时卷名单独成行。
+// 编码、换行符、段首缩进与标题样式可以通过选项调整，较大的书可以按章节数、大小或分卷拆成多个文件。
+type Exporter struct{}
+
+func (Exporter) Name() string      { return "txt" }
+func (Exporter) Extension() string { return "txt" }
+func (Exporter) MIMEType() string  { return "text/plain; charset=utf-8" }
 
-func Save(path string, chapters []Chapter) error {
-    f, err := os.Create(path)
+// MIMETypeFor 返回带实际输出编码的媒体类型。
+func (Exporter) MIMETypeFor(opts format.Options) string {
+    cs := strings.ToLower(opts.String("charset", "utf-8"))
+    if encodings[cs] == nil { cs = "utf-8" }
+    return "text/plain; charset=" + cs
+}
+
+func (Exporter) Options() []format.Option {
+    return []format.Option{
+        {Name: "charset", Default: "utf-8", Usage: "输出编码，GBK 等编码无法表示的字符写为 ?", Values: Charsets},
+        {Name: "bom", Default: "false", Usage: "UTF-8 文件开头写入 BOM（部分 Windows 阅读器需要）"},
+        {Name: "newline", Default: "lf", Usage: "换行符", Values: []string{"lf", "crlf"}},
+        {Name: "indent", Default: "none", Usage: "段首缩进，fullwidth 为两个全角空格", Values: []string{"none", "fullwidth"}},
+        {Name: "title", Default: "{title}", Usage: "章节标题模板，{title} 为标题、{n} 为章节序号、{volume} 为卷名，如 ==== {title} ===="},
+        {Name: "volume-title", Default: "{title}", Usage: "卷名模板，{title} 为卷名、{n} 为卷序号"},
+        {Name: "split", Default: "none", Usage: "拆分为多个文件：按章节数、大小或分卷", Values: []string{"none", "chapters", "size", "volume"}},
+        {Name: "split-every", Usage: "拆分间隔：split=chapters 时为每个文件的章节数（默认 500），split=size 时为文件大小如 10MB（默认 10MB）"},
+    }
+}
+
+// Charsets 是支持的输出编码。
+var Charsets = []string{"utf-8", "gbk", "gb18030", "big5"}
+
+var encodings = map[string]encoding.Encoding{
+    "gbk":     simplifiedchinese.GBK,
+    "gb18030": simplifiedchinese.GB18030,
+    "big5":    traditionalchinese.Big5,
+}
+
+func (x Exporter) Export(w io.Writer, b *format.Book, opts format.Options) error {
+    cw, err := x.NewWriter(w, opts)
     if err != nil { return err }
-    defer f.Close()
-    for _, ch := range chapters {
-        if _, err := f.WriteString(ch.Title + "\n\n" + ch.Content + "\n\n"); err != nil { return err }
+    return format.WriteBook(cw, b)
+}
+
+// NewWriter 返回流式写入器：每章写完即刷新到 w，中断时文件里是已完成的章节。
+func (Exporter) NewWriter(w io.Writer, opts format.Options) (format.ChapterWriter, error) {
+    s, err := parse(opts)
+    if err != nil { return nil, err }
+    return &writer{w: bufio.NewWriter(w), s: s}, nil
+}
+
+// Split 返回拆分规则；split=size 时 split-every 支持 KB、MB 后缀（按 1024 换算）。
+func (Exporter) Split(opts format.Options) (*format.SplitRule, error) {
+    if _, err := parse(opts); err != nil { return nil, err }
+    every := opts.String("split-every", "")
+    switch strings.ToLower(opts.String("split", "none")) {
+    case "chapters":
+        if every == "" { return &format.SplitRule{Chapters: 500}, nil }
+        n, err := strconv.Atoi(every)
+        if err != nil || n <= 0 { return nil, fmt.Errorf("option split-every: invalid chapter count %q", every) }
+        return &format.SplitRule{Chapters: n}, nil
+    case "size":
+        if every == "" { return &format.SplitRule{Bytes: 10 << 20}, nil }
+        n, err := parseSize(every)
+        if err != nil { return nil, fmt.Errorf("option split-every: %w", err) }
+        return &format.SplitRule{Bytes: n}, nil
+    case "volume":
+        return &format.SplitRule{Volume: true}, nil
+    }
+    return nil, nil
+}
+
+func parseSize(s string) (int64, error) {
+    u := strings.ToUpper(strings.TrimSpace(s))
+    mul := int64(1)
+    for _, suf := range []struct {
+        s string
+        n int64
+    }{{"MB", 1 << 20}, {"M", 1 << 20}, {"KB", 1 << 10}, {"K", 1 << 10}, {"B", 1}} {
+        if strings.HasSuffix(u, suf.s) {
+            u, mul = strings.TrimSpace(strings.TrimSuffix(u, suf.s)), suf.n
+            break
+        }
     }
+    n, err := strconv.ParseFloat(u, 64)
+    if err != nil || n <= 0 { return 0, fmt.Errorf("invalid size %q", s) }
+    return int64(n * float64(mul)), nil
+}
+
+// settings 是解析后的选项
+type settings struct {
+    enc           encoding.Encoding // nil 为 UTF-8
+    bom, crlf     bool
+    indent        string
+    title, volume string
+}
+
+func parse(opts format.Options) (*settings, error) {
+    s := &settings{title: opts.String("title", "{title}"), volume: opts.String("volume-title", "{title}")}
+    s.enc = encodings[strings.ToLower(opts.String("charset", "utf-8"))]
+    var err error
+    if s.bom, err = opts.Bool("bom", false); err != nil { return nil, err }
+    s.crlf = strings.EqualFold(opts["newline"], "crlf")
+    if strings.EqualFold(opts["indent"], "fullwidth") { s.indent = "　　" }
+    return s, nil
+}
+
+// chapter 按选项排版一章：标题、空行、逐行的段落（插图被忽略）与章节之间的空行。
+func (s *settings) chapter(n int, volume string, ch format.Chapter) string {
+    var b strings.Builder
+    b.WriteString(strings.NewReplacer("{title}", ch.Title, "{n}", strconv.Itoa(n), "{volume}", volume).Replace(s.title) + "\n\n")
+    for _, p := range ch.Paragraphs {
+        if p.Image == nil { b.WriteString(s.indent + p.Text + "\n") }
+    }
+    b.WriteString("\n")
+    return b.String()
+}
+
+// encode 转换换行符与编码；目标编码无法表示的字符写为 ?。
+func (s *settings) encode(text string) string {
+    if s.crlf { text = strings.ReplaceAll(text, "\n", "\r\n") }
+    if s.enc == nil { return text }
+    e := s.enc.NewEncoder()
+    if out, err := e.String(text); err == nil { return out }
+    var b strings.Builder
+    for _, r := range text {
+        out, err := e.String(string(r))
+        if err != nil { out = "?" }
+        b.WriteString(out)
+    }
+    return b.String()
+}
+
+type writer struct {
+    w       *bufio.Writer
+    s       *settings
+    n       int // 已写入的章节数，拆分后继续累加
+    volumes int
+    volume  string
+}
+
+func (t *writer) Begin(*format.Book) error {
+    if t.s.bom && t.s.enc == nil { return t.write("\uFEFF") }
     return nil
 }
+
+func (t *writer) BeginVolume(title string) error {
+    t.volumes++
+    t.volume = title
+    return t.write(strings.NewReplacer("{title}", title, "{n}", strconv.Itoa(t.volumes)).Replace(t.s.volume) + "\n\n")
+}
+
+func (t *writer) WriteChapter(ch format.Chapter) error {
+    t.n++
+    return t.write(t.s.chapter(t.n, t.volume, ch))
+}
+
+func (t *writer) Finish() error { return t.w.Flush() }
+
+// Reset 切换到拆分出的下一个文件，章节序号与当前卷名保持不变。
+func (t *writer) Reset(w io.Writer) { t.w.Reset(w) }
+
+func (t *writer) write(s string) error {
+    if _, err := t.w.WriteString(t.s.encode(s)); err != nil { return err }
+    return t.w.Flush()
+}
+
+// Appendable 判断按 opts 导出的 TXT 能否直接追加章节：拆分为多个文件时需要整体重新生成。
+func Appendable(opts format.Options) bool { return !format.Splits(Exporter{}, opts) }
+
+// Position 是已有 TXT 末尾的位置：已写入的章节数、卷数与最后一卷的卷名，续写时用于标题模板中的 {n}、{volume}。
+type Position struct {
+    Chapters, Volumes int
+    Volume            string
+}
+
+// Append 把分卷追加到已有 TXT 末尾（连载更新时无需重写整本）；opts 应与生成该文件时相同，以保持编码与排版一致。
+// 与整本导出相同，有卷名的卷先写卷名，没有卷名的卷接在 at 所在的卷之后。
+func Append(path string, volumes []format.Volume, at Position, opts format.Options) error {
+    s, err := parse(opts)
+    if err != nil { return err }
+    f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+    if err != nil { return err }
+    t := &writer{w: bufio.NewWriter(f), s: s, n: at.Chapters, volumes: at.Volumes, volume: at.Volume}
+    for _, v := range volumes {
+        if v.Title != "" { err = t.BeginVolume(v.Title) }
+        for _, ch := range v.Chapters {
+            if err == nil { err = t.WriteChapter(ch) }
+        }
+        if err != nil { f.Close(); return err }
+    }
+    return f.Close()
+}
@@ -0,0 +1,139 @@
+package txt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+func TestAppend(t *testing.T) {
+	ch := func(title string) format.Chapter { return format.NewChapter(title, title+"正文\n第二段") }
+	full := []format.Volume{
+		{Chapters: []format.Chapter{ch("楔子")}},
+		{Title: "初入江湖", Chapters: []format.Chapter{ch("第1章 出山"), ch("第2章 下山")}},
+		{Title: "名动天下", Chapters: []format.Chapter{ch("第3章 归来")}},
+	}
+	opts := format.Options{"title": "{n}. {title}（{volume}）", "volume-title": "第{n}卷 {title}"}
+	tests := []struct {
+		name   string
+		before []format.Volume // 已有文件的内容
+		add    []format.Volume // 追加的内容
+		at     Position
+		opts   format.Options
+	}{
+		{"before first volume", full[:1], full[1:], Position{Chapters: 1}, opts},
+		{
+			"inside a volume",
+			[]format.Volume{full[0], {Title: "初入江湖", Chapters: full[1].Chapters[:1]}},
+			[]format.Volume{{Chapters: full[1].Chapters[1:]}, full[2]},
+			Position{Chapters: 2, Volumes: 1, Volume: "初入江湖"},
+			opts,
+		},
+		{"at volume end", full[:2], full[2:], Position{Chapters: 3, Volumes: 1, Volume: "初入江湖"}, opts},
+		{"gbk crlf indent", full[:2], full[2:], Position{Chapters: 3, Volumes: 1, Volume: "初入江湖"}, opts.Merge(format.Options{"charset": "gbk", "newline": "crlf", "indent": "fullwidth"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := (Exporter{}).Export(&want, &format.Book{Volumes: full}, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "book.txt")
+			if err := format.Save(path, Exporter{}, &format.Book{Volumes: tt.before}, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := Append(path, tt.add, tt.at, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("appended file differs from a full export\ngot:\n%s\nwant:\n%s", got, want.Bytes())
+			}
+		})
+	}
+}
+
+func TestMIMEType(t *testing.T) {
+	tests := []struct {
+		opts format.Options
+		want string
+	}{
+		{nil, "text/plain; charset=utf-8"},
+		{format.Options{"charset": "utf-8", "bom": "true"}, "text/plain; charset=utf-8"},
+		{format.Options{"charset": "GBK"}, "text/plain; charset=gbk"},
+		{format.Options{"charset": "gb18030"}, "text/plain; charset=gb18030"},
+		{format.Options{"charset": "big5"}, "text/plain; charset=big5"},
+	}
+	for _, tt := range tests {
+		if got := format.MIMEType(Exporter{}, tt.opts); got != tt.want {
+			t.Errorf("MIMEType(%v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+// 重新导出时拆分得更少（或不再拆分），上次多出的拆分文件应被删除
+func TestSaveRemovesStaleSplits(t *testing.T) {
+	var chs []format.Chapter
+	for _, title := range []string{"第1章", "第2章", "第3章", "第4章"} {
+		chs = append(chs, format.NewChapter(title, title+"正文"))
+	}
+	book := &format.Book{Volumes: []format.Volume{{Chapters: chs}}}
+	every := func(n string) format.Options { return format.Options{"split": "chapters", "split-every": n} }
+	tests := []struct {
+		name   string
+		before format.Options
+		after  format.Options
+		stream bool // 用 Create/WriteChapter/Close 导出，而不是 Save
+		want   []string
+	}{
+		{"fewer parts", every("1"), every("2"), false, []string{"book.txt", "book_002.txt"}},
+		{"same parts", every("2"), every("2"), false, []string{"book.txt", "book_002.txt"}},
+		{"more parts", every("2"), every("1"), false, []string{"book.txt", "book_002.txt", "book_003.txt", "book_004.txt"}},
+		{"no longer split", every("1"), nil, false, []string{"book.txt"}},
+		{"no longer split, streamed", every("1"), nil, true, []string{"book.txt"}},
+		{"fewer parts, streamed", every("1"), every("3"), true, []string{"book.txt", "book_002.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "book.txt")
+			if err := format.Save(path, Exporter{}, book, tt.before); err != nil {
+				t.Fatal(err)
+			}
+			if tt.stream {
+				f, err := format.Create(path, Exporter{}, &format.Book{}, tt.after)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, ch := range chs {
+					if err := f.WriteChapter(ch); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := f.Close(); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := format.Save(path, Exporter{}, book, tt.after); err != nil {
+				t.Fatal(err)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Name())
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("files = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package library
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+)
+
+// ErrNotFound 表示书架中没有这本书。
+var ErrNotFound = errors.New("book not in library")
+
+// Export 是一次导出记录。
+type Export struct {
+	Format    string            `json:"format"`
+	Path      string            `json:"path"`
+	Chapters  int               `json:"chapters"`
+	Failed    int               `json:"failed,omitempty"`  // 以占位内容导出的章节数
+	Options   map[string]string `json:"options,omitempty"` // 导出时使用的该格式选项，更新时沿用
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Book 是书架上的一本书。章节目录与正文保存在检查点目录中，这里只记录元数据与导出历史。
+type Book struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Source    string    `json:"source"`
+	Title     string    `json:"title"`
+	Author    string    `json:"author"`
+	Category  string    `json:"category,omitempty"`
+	Chapters  int       `json:"chapters"` // 最近一次获取的目录章节数
+	Exports   []Export  `json:"exports,omitempty"`
+	AddedAt   time.Time `json:"addedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Files 返回每种格式最近一次导出且文件仍存在的记录，按格式排序。
+func (b Book) Files() []Export {
+	var out []Export
+	seen := make(map[string]bool)
+	for i := len(b.Exports) - 1; i >= 0; i-- {
+		e := b.Exports[i]
+		if seen[e.Format] {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		seen[e.Format] = true
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Format < out[j].Format })
+	return out
+}
+
+// Library 是基于文件的书架：
+//
+//	<dir>/books.json     书籍元数据与导出历史
+//	<workDir>/<hash>/... 每本书的检查点（目录与已抓取正文），见 checkpoint 包
+//
+// 每次操作都在 books.json.lock 的文件锁内重新读取 books.json 并原子写回，
+// CLI 与 Web 服务等多个进程可以共用同一个书架。
+type Library struct {
+	mu      sync.Mutex // 同一进程内的操作先在这里排队，再竞争文件锁
+	dir     string
+	workDir string
+}
+
+// Open 打开（必要时创建）书架目录，workDir 为检查点根目录。
+func Open(dir, workDir string) (*Library, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Library{dir: dir, workDir: workDir}, nil
+}
+
+// ID 返回 bookURL 对应的书籍 ID。
+func ID(bookURL string) string {
+	h := sha1.Sum([]byte(bookURL))
+	return hex.EncodeToString(h[:])[:12]
+}
+
+// List 按最近更新时间倒序返回全部书籍。
+func (l *Library) List() ([]Book, error) {
+	release, err := l.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	books, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(books, func(i, j int) bool { return books[i].UpdatedAt.After(books[j].UpdatedAt) })
+	return books, nil
+}
+
+// Get 按 ID 或书籍 URL 查找。
+func (l *Library) Get(ref string) (Book, error) {
+	release, err := l.acquire()
+	if err != nil {
+		return Book{}, err
+	}
+	defer release()
+	books, err := l.load()
+	if err != nil {
+		return Book{}, err
+	}
+	i := find(books, ref)
+	if i < 0 {
+		return Book{}, ErrNotFound
+	}
+	return books[i], nil
+}
+
+// Put 新增或更新一本书（按 URL 匹配）。已有记录的加入时间与导出历史保持不变，空字段不覆盖旧值。
+func (l *Library) Put(b Book) (Book, error) {
+	release, err := l.acquire()
+	if err != nil {
+		return Book{}, err
+	}
+	defer release()
+	books, err := l.load()
+	if err != nil {
+		return Book{}, err
+	}
+	now := time.Now()
+	b.ID = ID(b.URL)
+	b.UpdatedAt = now
+	if i := find(books, b.ID); i >= 0 {
+		old := books[i]
+		b.AddedAt = old.AddedAt
+		b.Exports = old.Exports
+		b.Source = orDefault(b.Source, old.Source)
+		b.Title = orDefault(b.Title, old.Title)
+		b.Author = orDefault(b.Author, old.Author)
+		b.Category = orDefault(b.Category, old.Category)
+		if b.Chapters == 0 {
+			b.Chapters = old.Chapters
+		}
+		books[i] = b
+	} else {
+		b.AddedAt = now
+		books = append(books, b)
+	}
+	return b, l.save(books)
+}
+
+// AddExport 记录一次导出；书不在书架上时返回 ErrNotFound。
+func (l *Library) AddExport(ref string, e Export) error {
+	release, err := l.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	books, err := l.load()
+	if err != nil {
+		return err
+	}
+	i := find(books, ref)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	books[i].Exports = append(books[i].Exports, e)
+	books[i].UpdatedAt = e.CreatedAt
+	return l.save(books)
+}
+
+// Remove 从书架移除；purge 为 true 时同时删除检查点（目录与已抓取正文）。导出的文件不会删除。
+func (l *Library) Remove(ref string, purge bool) (Book, error) {
+	release, err := l.acquire()
+	if err != nil {
+		return Book{}, err
+	}
+	defer release()
+	books, err := l.load()
+	if err != nil {
+		return Book{}, err
+	}
+	i := find(books, ref)
+	if i < 0 {
+		return Book{}, ErrNotFound
+	}
+	b := books[i]
+	books = append(books[:i], books[i+1:]...)
+	if err := l.save(books); err != nil {
+		return Book{}, err
+	}
+	if purge {
+		if err := os.RemoveAll(checkpoint.Dir(l.workDir, b.URL)); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
+
+// Checkpoint 打开书籍的检查点。
+func (l *Library) Checkpoint(b Book) (*checkpoint.Store, error) {
+	return checkpoint.Open(l.workDir, b.URL)
+}
+
+// Status 返回书籍的检查点目录与已抓取章节数；尚未获取目录时 m.Chapters 为空。
+func (l *Library) Status(b Book) (m checkpoint.Manifest, fetched int, err error) {
+	store, err := l.Checkpoint(b)
+	if err != nil {
+		return m, 0, err
+	}
+	m, err = store.LoadManifest()
+	if err != nil {
+		if checkpoint.IsNotExist(err) {
+			return m, 0, nil
+		}
+		return m, 0, err
+	}
+	return m, len(m.Chapters) - len(store.Missing(m.Chapters)), nil
+}
+
+func find(books []Book, ref string) int {
+	ref = strings.TrimSpace(ref)
+	for i, b := range books {
+		if b.ID == ref || b.URL == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func (l *Library) path() string { return filepath.Join(l.dir, "books.json") }
+
+// acquire 依次取得进程内的锁与 books.json 的文件锁，返回释放函数；读改写 books.json 期间持有。
+func (l *Library) acquire() (release func(), err error) {
+	l.mu.Lock()
+	unlock, err := lockFile(l.path() + ".lock")
+	if err != nil {
+		l.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		l.mu.Unlock()
+	}, nil
+}
+
+func (l *Library) load() ([]Book, error) {
+	b, err := os.ReadFile(l.path())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var books []Book
+	if err := json.Unmarshal(b, &books); err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+func (l *Library) save(books []Book) error {
+	if books == nil {
+		books = []Book{}
+	}
+	b, err := json.MarshalIndent(books, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(l.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path())
+}
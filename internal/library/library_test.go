@@ -0,0 +1,48 @@
+package library
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// 两个 Library 实例模拟 CLI 与 Web 服务两个进程：进程内的锁互不相干，只靠文件锁保证读改写不丢记录
+func TestConcurrentAddExport(t *testing.T) {
+	dir, work := t.TempDir(), t.TempDir()
+	libs := make([]*Library, 2)
+	for i := range libs {
+		l, err := Open(dir, work)
+		if err != nil {
+			t.Fatal(err)
+		}
+		libs[i] = l
+	}
+	const url = "https://example.com/book/1"
+	if _, err := libs[0].Put(Book{URL: url, Title: "测试之书"}); err != nil {
+		t.Fatal(err)
+	}
+
+	const perLib = 50
+	var wg sync.WaitGroup
+	for i, l := range libs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perLib; j++ {
+				if err := l.AddExport(url, Export{Format: "txt", Path: fmt.Sprintf("%d-%d.txt", i, j)}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := libs[1].Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(b.Exports), len(libs)*perLib; got != want {
+		t.Errorf("len(Exports) = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !unix
+
+package library
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// 锁文件超过 staleLock 未释放视为持有者已异常退出；等待超过 lockTimeout 放弃
+const (
+	staleLock   = 30 * time.Second
+	lockTimeout = time.Minute
+)
+
+// lockFile 在没有 flock 的平台上以独占创建 path 作为排他锁，释放时删除。
+func lockFile(path string) (unlock func(), err error) {
+	start := time.Now()
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Since(start) > lockTimeout {
+			return nil, fmt.Errorf("library: %s is held by another process", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
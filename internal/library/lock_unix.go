@@ -0,0 +1,31 @@
+//go:build unix
+
+package library
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile 用 flock 对 path 加排他锁，阻塞到取得为止；进程退出时系统自动释放。
+func lockFile(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
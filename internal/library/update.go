@@ -0,0 +1,195 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/sreio/go-novel/internal/checkpoint"
+	"github.com/sreio/go-novel/internal/downloader"
+	"github.com/sreio/go-novel/internal/report"
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// UpdateOptions 控制一次连载更新。
+type UpdateOptions struct {
+	Source      *sources.ConfigSource   // 书籍所在书源
+	Sources     []*sources.ConfigSource // 全部书源，用于章节兜底
+	Concurrency int                     // 并发上限（书源未配置 concurrency.max 时使用）
+	Progress    func(downloader.Event)  // 可选：进度回调
+}
+
+// Change 是一个新增章节。
+type Change struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Update 是一次连载更新的结果。
+type Update struct {
+	Book     Book                    `json:"book"`
+	Before   int                     `json:"before"` // 更新前目录章节数
+	After    int                     `json:"after"`  // 更新后目录章节数
+	Added    []Change                `json:"added"`
+	Fetched  []int                   `json:"-"` // 本次实际抓取的章节下标（新增章节及之前缺失的章节）
+	Failures []report.Failure        `json:"failures,omitempty"`
+	Origins  []sources.ChapterOrigin `json:"-"`
+	Chapters []sources.Chapter       `json:"-"` // 更新后的目录，正文在检查点中，见 Text
+
+	store *checkpoint.Store
+}
+
+// Changed 表示有章节被抓取，导出文件需要刷新。
+func (u *Update) Changed() bool { return len(u.Fetched) > 0 }
+
+// Update 重新获取目录，按 URL 与归一化标题和已保存的目录比对，只抓取新增（及之前缺失）的章节。
+// 已有章节即使在站点上换了 URL，只要标题相同仍沿用检查点中的正文。
+func (l *Library) Update(ctx context.Context, ref string, opt UpdateOptions) (*Update, error) {
+	if opt.Source == nil {
+		return nil, errors.New("library: no source")
+	}
+	b, err := l.Get(ref)
+	if err != nil {
+		return nil, err
+	}
+	store, err := l.Checkpoint(b)
+	if err != nil {
+		return nil, err
+	}
+	m, err := store.LoadManifest()
+	if err != nil && !checkpoint.IsNotExist(err) {
+		return nil, err
+	}
+
+	fresh, err := opt.Source.Chapters(ctx, b.URL, b.URL)
+	if err != nil {
+		return nil, err
+	}
+	if len(fresh) == 0 {
+		return nil, errors.New("library: no chapters found")
+	}
+	merged, added := MergeChapters(m.Chapters, fresh)
+
+	u := &Update{Before: len(m.Chapters), After: len(merged), Added: []Change{}, Chapters: merged, store: store}
+	for _, i := range added {
+		u.Added = append(u.Added, Change{Index: i, Title: merged[i].Title, URL: merged[i].URL})
+	}
+	u.Fetched = store.Missing(merged)
+
+	title, author := orDefault(b.Title, m.Title), orDefault(b.Author, m.Author)
+	if err := store.SaveManifest(checkpoint.Manifest{BookURL: b.URL, Source: opt.Source.Name(), Title: title, Author: author, Chapters: merged}); err != nil {
+		return nil, err
+	}
+
+	fb := sources.NewFallback(ctx, opt.Source, opt.Sources, title, author)
+	initial, ceiling := opt.Source.Concurrency(opt.Concurrency)
+	res, err := downloader.Run(ctx, merged, downloader.Options{
+		Source:             opt.Source.Name(),
+		Fetcher:            fb,
+		Concurrency:        ceiling,
+		InitialConcurrency: initial,
+		Tolerant:           true,
+		Store:              store,
+		Progress:           opt.Progress,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	u.Failures, u.Origins = res.Failures, res.Origins
+
+	u.Book, err = l.Put(Book{URL: b.URL, Source: opt.Source.Name(), Chapters: len(merged)})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Targets 返回需要随更新刷新的导出：每个路径取最近一条记录，文件已被删除的跳过。
+func (u *Update) Targets() []Export {
+	var out []Export
+	seen := make(map[string]bool)
+	for i := len(u.Book.Exports) - 1; i >= 0; i-- {
+		e := u.Book.Exports[i]
+		if seen[e.Path] {
+			continue
+		}
+		seen[e.Path] = true
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// CanAppend 判断能否把本次抓取的章节直接追加到已有导出：仅限 TXT，
+// 且原文件恰好包含更新前的全部章节、没有占位内容，本次抓取的章节都排在末尾。
+func (u *Update) CanAppend(e Export) bool {
+	if e.Format != "txt" || e.Failed > 0 || e.Chapters != u.Before {
+		return false
+	}
+	for _, i := range u.Fetched {
+		if i < u.Before {
+			return false
+		}
+	}
+	return true
+}
+
+// Text 从检查点读出更新后第 i 章的正文；抓取失败的章节返回占位内容。
+// 刷新导出时逐章读取，不把整本书读进内存。
+func (u *Update) Text(i int) (report.Text, error) {
+	c := u.Chapters[i]
+	content, err := u.store.Get(c.URL)
+	if errors.Is(err, fs.ErrNotExist) {
+		f := report.Failure{Index: i, Title: c.Title, URL: c.URL, Error: "not downloaded"}
+		for _, x := range u.Failures {
+			if x.Index == i {
+				f = x
+			}
+		}
+		return report.Text{Title: c.Title, Content: report.Placeholder(f)}, nil
+	}
+	if err != nil {
+		return report.Text{}, err
+	}
+	return report.Text{Title: c.Title, Content: content}, nil
+}
+
+// MergeChapters 用新目录替换旧目录，返回合并后的目录与新增章节下标。
+// URL 相同视为同一章；URL 变了但归一化标题相同时沿用旧条目（正文按旧 URL 存在检查点里）。
+func MergeChapters(old, fresh []sources.Chapter) (merged []sources.Chapter, added []int) {
+	byURL := make(map[string]bool, len(old))
+	byTitle := make(map[string]sources.Chapter, len(old))
+	for _, c := range old {
+		byURL[c.URL] = true
+		if k := sources.NormalizeText(c.Title); k != "" {
+			if _, ok := byTitle[k]; !ok {
+				byTitle[k] = c
+			}
+		}
+	}
+	inFresh := make(map[string]bool, len(fresh))
+	for _, c := range fresh {
+		inFresh[c.URL] = true
+	}
+	used := make(map[string]bool, len(old))
+	for _, c := range fresh {
+		if byURL[c.URL] && !used[c.URL] {
+			used[c.URL] = true
+			merged = append(merged, c)
+			continue
+		}
+		// 旧条目的 URL 仍在新目录中时会按 URL 匹配，不能再按标题复用
+		if o, ok := byTitle[sources.NormalizeText(c.Title)]; ok && !used[o.URL] && !inFresh[o.URL] {
+			used[o.URL] = true
+			c.URL, c.ID = o.URL, o.ID
+		} else {
+			added = append(added, len(merged))
+		}
+		merged = append(merged, c)
+	}
+	return merged, added
+}
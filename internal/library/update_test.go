@@ -0,0 +1,129 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+func TestMergeChapters(t *testing.T) {
+	ch := func(url, title string) sources.Chapter { return sources.Chapter{URL: url, Title: title} }
+	tests := []struct {
+		name       string
+		old, fresh []sources.Chapter
+		wantURLs   []string
+		wantAdded  []int
+	}{
+		{
+			name:      "first fetch",
+			fresh:     []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章")},
+			wantURLs:  []string{"/1", "/2"},
+			wantAdded: []int{0, 1},
+		},
+		{
+			name:     "unchanged",
+			old:      []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章")},
+			fresh:    []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章")},
+			wantURLs: []string{"/1", "/2"},
+		},
+		{
+			name:      "appended",
+			old:       []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章")},
+			fresh:     []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章"), ch("/3", "第3章"), ch("/4", "第4章")},
+			wantURLs:  []string{"/1", "/2", "/3", "/4"},
+			wantAdded: []int{2, 3},
+		},
+		{
+			name:      "inserted in the middle",
+			old:       []sources.Chapter{ch("/1", "第1章"), ch("/3", "第3章")},
+			fresh:     []sources.Chapter{ch("/1", "第1章"), ch("/2", "第2章"), ch("/3", "第3章")},
+			wantURLs:  []string{"/1", "/2", "/3"},
+			wantAdded: []int{1},
+		},
+		{
+			name:      "moved URL keeps the stored chapter",
+			old:       []sources.Chapter{ch("/a/1", "第1章 出山"), ch("/a/2", "第2章 下山")},
+			fresh:     []sources.Chapter{ch("/b/1", "第1章　出山！"), ch("/b/2", "第2章 下山"), ch("/b/3", "第3章 归来")},
+			wantURLs:  []string{"/a/1", "/a/2", "/b/3"},
+			wantAdded: []int{2},
+		},
+		{
+			name:      "title reused only while the old URL is gone",
+			old:       []sources.Chapter{ch("/1", "上架感言")},
+			fresh:     []sources.Chapter{ch("/1", "上架感言"), ch("/9", "上架感言")},
+			wantURLs:  []string{"/1", "/9"},
+			wantAdded: []int{1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, added := MergeChapters(tt.old, tt.fresh)
+			var urls []string
+			for _, c := range merged {
+				urls = append(urls, c.URL)
+			}
+			if !slices.Equal(urls, tt.wantURLs) {
+				t.Errorf("merged = %v, want %v", urls, tt.wantURLs)
+			}
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+		})
+	}
+}
+
+func TestCanAppend(t *testing.T) {
+	tests := []struct {
+		name    string
+		export  Export
+		fetched []int
+		want    bool
+	}{
+		{"new chapters at the end", Export{Format: "txt", Chapters: 3}, []int{3, 4}, true},
+		{"nothing fetched", Export{Format: "txt", Chapters: 3}, nil, true},
+		{"not txt", Export{Format: "epub", Chapters: 3}, []int{3}, false},
+		{"file has placeholders", Export{Format: "txt", Chapters: 3, Failed: 1}, []int{3}, false},
+		{"file is behind", Export{Format: "txt", Chapters: 2}, []int{3}, false},
+		{"earlier chapter fetched", Export{Format: "txt", Chapters: 3}, []int{1, 3}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Update{Before: 3, After: 5, Fetched: tt.fetched}
+			if got := u.CanAppend(tt.export); got != tt.want {
+				t.Errorf("CanAppend(%+v) = %v, want %v", tt.export, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargets(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	for _, name := range []string{"a.txt", "a.epub"} {
+		if err := os.WriteFile(path(name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := &Update{Book: Book{Exports: []Export{
+		{Format: "txt", Path: path("a.txt"), Chapters: 1},
+		{Format: "epub", Path: path("a.epub"), Chapters: 1},
+		{Format: "txt", Path: path("a.txt"), Chapters: 2},
+		{Format: "pdf", Path: path("deleted.pdf"), Chapters: 2},
+	}}}
+	got := u.Targets()
+	want := []Export{
+		{Format: "txt", Path: path("a.txt"), Chapters: 2},
+		{Format: "epub", Path: path("a.epub"), Chapters: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Targets() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Format != want[i].Format || got[i].Path != want[i].Path || got[i].Chapters != want[i].Chapters {
+			t.Errorf("Targets()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
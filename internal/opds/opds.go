@@ -0,0 +1,178 @@
+// Package opds 生成 OPDS 1.2 目录（Atom）与 OpenSearch 描述文档。
+package opds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/sreio/go-novel/internal/format"
+)
+
+// 媒体类型
+const (
+	TypeNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	TypeAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	TypeEntry       = "application/atom+xml;type=entry;profile=opds-catalog"
+	TypeOpenSearch  = "application/opensearchdescription+xml"
+	TypeAtom        = "application/atom+xml"
+	TypeEPUB        = "application/epub+zip"
+	TypeOctetStream = "application/octet-stream"
+	namespaceAtom   = "http://www.w3.org/2005/Atom"
+	namespaceOPDS   = "http://opds-spec.org/2010/catalog"
+	namespaceDC     = "http://purl.org/dc/terms/"
+	namespaceOS     = "http://a9.com/-/spec/opensearch/1.1/"
+)
+
+// 链接关系
+const (
+	RelSelf        = "self"
+	RelStart       = "start"
+	RelUp          = "up"
+	RelNext        = "next"
+	RelPrevious    = "previous"
+	RelSearch      = "search"
+	RelSubsection  = "subsection"
+	RelAlternate   = "alternate"
+	RelAcquisition = "http://opds-spec.org/acquisition"
+	RelSortNew     = "http://opds-spec.org/sort/new"
+)
+
+// MimeType 返回按 opts 导出的该格式文件的媒体类型（取自导出器注册表）。
+func MimeType(name string, opts format.Options) string {
+	if e, ok := format.Lookup(name); ok {
+		return format.MIMEType(e, opts)
+	}
+	return TypeOctetStream
+}
+
+// Link 是 Atom 链接。
+type Link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+// Author 是条目作者。
+type Author struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+// Category 是条目分类。
+type Category struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+// Entry 是导航条目或书籍条目。
+type Entry struct {
+	Title      string     `xml:"title"`
+	ID         string     `xml:"id"`
+	Updated    time.Time  `xml:"updated"`
+	Authors    []Author   `xml:"author,omitempty"`
+	Language   string     `xml:"dc:language,omitempty"`
+	Categories []Category `xml:"category,omitempty"`
+	Summary    string     `xml:"summary,omitempty"`
+	Links      []Link     `xml:"link"`
+}
+
+// Feed 是 OPDS 目录页。
+type Feed struct {
+	XMLName      xml.Name  `xml:"feed"`
+	Xmlns        string    `xml:"xmlns,attr"`
+	XmlnsOPDS    string    `xml:"xmlns:opds,attr"`
+	XmlnsDC      string    `xml:"xmlns:dc,attr"`
+	XmlnsOS      string    `xml:"xmlns:opensearch,attr"`
+	ID           string    `xml:"id"`
+	Title        string    `xml:"title"`
+	Updated      time.Time `xml:"updated"`
+	Author       *Author   `xml:"author,omitempty"`
+	Icon         string    `xml:"icon,omitempty"`
+	TotalResults int       `xml:"opensearch:totalResults,omitempty"`
+	ItemsPerPage int       `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int       `xml:"opensearch:startIndex,omitempty"`
+	Links        []Link    `xml:"link"`
+	Entries      []Entry   `xml:"entry"`
+}
+
+// NewFeed 创建目录页。
+func NewFeed(id, title string, updated time.Time) *Feed {
+	return &Feed{
+		Xmlns:     namespaceAtom,
+		XmlnsOPDS: namespaceOPDS,
+		XmlnsDC:   namespaceDC,
+		XmlnsOS:   namespaceOS,
+		ID:        id,
+		Title:     title,
+		Updated:   updated,
+		Author:    &Author{Name: "go-novel"},
+	}
+}
+
+// AddLink 追加一条目录链接。
+func (f *Feed) AddLink(rel, href, typ string) {
+	f.Links = append(f.Links, Link{Rel: rel, Href: href, Type: typ})
+}
+
+// Paginate 设置 OpenSearch 分页信息，并在有上一页/下一页时追加 previous/next 链接；
+// page 从 1 开始，pageHref 返回指定页的地址。
+func (f *Feed) Paginate(total, page, perPage int, typ string, pageHref func(page int) string) {
+	f.TotalResults, f.ItemsPerPage, f.StartIndex = total, perPage, (page-1)*perPage+1
+	if page > 1 {
+		f.AddLink(RelPrevious, pageHref(page-1), typ)
+	}
+	if page*perPage < total {
+		f.AddLink(RelNext, pageHref(page+1), typ)
+	}
+}
+
+// Write 以 XML 写出目录页。
+func (f *Feed) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(f)
+}
+
+// OpenSearch 是 OpenSearch 描述文档。
+type OpenSearch struct {
+	XMLName        xml.Name `xml:"OpenSearchDescription"`
+	Xmlns          string   `xml:"xmlns,attr"`
+	ShortName      string   `xml:"ShortName"`
+	Description    string   `xml:"Description"`
+	InputEncoding  string   `xml:"InputEncoding"`
+	OutputEncoding string   `xml:"OutputEncoding"`
+	URLs           []OSURL  `xml:"Url"`
+}
+
+// OSURL 是 OpenSearch 的查询模板，template 中用 {searchTerms} 表示关键词。
+type OSURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+// NewOpenSearch 创建描述文档，template 为返回 Atom 结果的查询地址。
+func NewOpenSearch(name, description, template string) *OpenSearch {
+	return &OpenSearch{
+		Xmlns:          namespaceOS,
+		ShortName:      name,
+		Description:    description,
+		InputEncoding:  "UTF-8",
+		OutputEncoding: "UTF-8",
+		URLs:           []OSURL{{Type: TypeAtom, Template: template}},
+	}
+}
+
+// Write 以 XML 写出描述文档。
+func (o *OpenSearch) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(o)
+}
@@ -0,0 +1,110 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// placeholderTag 写在占位正文里，便于在导出结果中检索缺失章节
+const placeholderTag = "[go-novel:missing]"
+
+// Failure 是一章在重试后仍然失败的记录。
+type Failure struct {
+	Index int    `json:"index"` // 章节下标（从 0 开始）
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// Report 是一次容错下载的结果汇总，与输出文件一起保存在 <输出文件>.report.json。
+type Report struct {
+	BookURL   string            `json:"bookUrl"`
+	Source    string            `json:"source"`
+	Title     string            `json:"title"`
+	Author    string            `json:"author"`
+	Format    string            `json:"format"`
+	Output    string            `json:"output"`
+	Options   map[string]string `json:"options,omitempty"` // 导出时使用的该格式选项，retry 时沿用
+	Total     int               `json:"total"`
+	Failures  []Failure         `json:"failures"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Text 是已抓取章节的标题与正文。
+type Text struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// Path 返回输出文件对应的报告路径。
+func Path(output string) string { return output + ".report.json" }
+
+// Placeholder 生成失败章节的占位正文。
+func Placeholder(f Failure) string {
+	return fmt.Sprintf("%s 本章下载失败，可使用 novel retry 补全。\n原地址：%s\n错误：%s", placeholderTag, f.URL, f.Error)
+}
+
+// OK 表示没有失败章节。
+func (r *Report) OK() bool { return len(r.Failures) == 0 }
+
+// Save 将报告写到输出文件旁；没有失败章节时清理旧的报告。
+// 已抓取的正文保存在检查点目录中，retry 时从那里重新导出。
+func (r *Report) Save() error {
+	if r.OK() {
+		_ = os.Remove(Path(r.Output))
+		return nil
+	}
+	r.sortFailures()
+	return writeJSON(Path(r.Output), r)
+}
+
+func (r *Report) sortFailures() {
+	sort.Slice(r.Failures, func(i, j int) bool { return r.Failures[i].Index < r.Failures[j].Index })
+}
+
+// Load 读取报告。
+func Load(path string) (*Report, error) {
+	var r Report
+	if err := readJSON(path, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// PrintTable 以表格形式输出失败章节。
+func (r *Report) PrintTable(w io.Writer) {
+	if r.OK() {
+		fmt.Fprintf(w, "全部 %d 章下载成功\n", r.Total)
+		return
+	}
+	r.sortFailures()
+	fmt.Fprintf(w, "共 %d 章，失败 %d 章（已写入占位内容）：\n", r.Total, len(r.Failures))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "序号\t标题\tURL\t错误")
+	for _, f := range r.Failures {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", f.Index+1, f.Title, f.URL, f.Error)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "报告：%s\n", Path(r.Output))
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
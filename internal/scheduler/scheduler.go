@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotSubscribed 表示书籍没有订阅更新。
+var ErrNotSubscribed = errors.New("book not subscribed")
+
+const (
+	maxHistory = 20  // 每本书保留的运行记录数
+	jitterFrac = 0.1 // 下次运行时间在间隔上随机浮动 ±10%，避免同一书源的订阅同时触发
+)
+
+// Run 是一次更新检查的记录。
+type Run struct {
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Added      int       `json:"added"`   // 新增章节数
+	Exports    int       `json:"exports"` // 刷新的导出文件数
+	Error      string    `json:"error,omitempty"`
+}
+
+// Entry 是一本订阅书籍的调度状态。
+type Entry struct {
+	BookID   string    `json:"bookId"`
+	Interval int       `json:"intervalMinutes,omitempty"` // 0 表示使用书源或全局默认间隔
+	Running  bool      `json:"running"`
+	NextRun  time.Time `json:"nextRun"`
+	LastRun  *Run      `json:"lastRun,omitempty"`
+	History  []Run     `json:"history,omitempty"` // 最近的运行记录，最新在前
+	Created  time.Time `json:"createdAt"`
+}
+
+// Result 是 RunFunc 的执行结果。
+type Result struct {
+	Added   int
+	Exports int
+}
+
+// RunFunc 检查一本书的更新。
+type RunFunc func(ctx context.Context, bookID string) (Result, error)
+
+// IntervalFunc 返回书籍的检查间隔；entry.Interval 为 0 时由它决定（书源配置或全局默认值）。
+type IntervalFunc func(bookID string) time.Duration
+
+// Scheduler 按间隔检查订阅书籍的更新。状态保存在 JSON 文件中，重启后按原定时间继续；
+// 停机期间错过的检查在启动后依次补跑。同一时刻只运行一本书，避免对书源造成突发请求。
+type Scheduler struct {
+	mu       sync.Mutex
+	path     string
+	entries  map[string]*Entry
+	run      RunFunc
+	interval IntervalFunc
+	wake     chan struct{}
+}
+
+// Open 读取（不存在时新建）调度状态文件。
+func Open(path string, run RunFunc, interval IntervalFunc) (*Scheduler, error) {
+	s := &Scheduler{path: path, entries: make(map[string]*Entry), run: run, interval: interval, wake: make(chan struct{}, 1)}
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		var list []*Entry
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			e.Running = false // 上次运行中途退出
+			s.entries[e.BookID] = e
+		}
+	}
+	return s, nil
+}
+
+// Start 在后台运行调度循环，ctx 取消后退出。
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.loop(ctx)
+}
+
+// Subscribe 订阅（或修改）一本书的更新检查；interval 为 0 时使用书源或全局默认间隔。
+func (s *Scheduler) Subscribe(bookID string, interval time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[bookID]
+	if !ok {
+		e = &Entry{BookID: bookID, Created: time.Now()}
+		s.entries[bookID] = e
+	}
+	e.Interval = int(interval / time.Minute)
+	e.NextRun = time.Now().Add(s.jittered(e))
+	if err := s.save(); err != nil {
+		return Entry{}, err
+	}
+	s.notify()
+	return s.copy(e), nil
+}
+
+// Unsubscribe 取消订阅。
+func (s *Scheduler) Unsubscribe(bookID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[bookID]; !ok {
+		return ErrNotSubscribed
+	}
+	delete(s.entries, bookID)
+	return s.save()
+}
+
+// RunNow 把书籍的下次检查提前到现在。
+func (s *Scheduler) RunNow(bookID string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[bookID]
+	if !ok {
+		return Entry{}, ErrNotSubscribed
+	}
+	e.NextRun = time.Now()
+	if err := s.save(); err != nil {
+		return Entry{}, err
+	}
+	s.notify()
+	return s.copy(e), nil
+}
+
+// Get 返回一本书的调度状态。
+func (s *Scheduler) Get(bookID string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[bookID]
+	if !ok {
+		return Entry{}, false
+	}
+	return s.copy(e), true
+}
+
+// List 按下次运行时间返回全部订阅。
+func (s *Scheduler) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, s.copy(e))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].NextRun.Before(out[j].NextRun) })
+	return out
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		e, wait := s.next()
+		if e == "" {
+			wait = time.Hour
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-s.wake:
+				t.Stop()
+				continue
+			case <-t.C:
+				continue
+			}
+		}
+		s.runOne(ctx, e)
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next 返回最早到期的书籍及距到期的时间。
+func (s *Scheduler) next() (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var first *Entry
+	for _, e := range s.entries {
+		if first == nil || e.NextRun.Before(first.NextRun) {
+			first = e
+		}
+	}
+	if first == nil {
+		return "", 0
+	}
+	return first.BookID, time.Until(first.NextRun)
+}
+
+func (s *Scheduler) runOne(ctx context.Context, bookID string) {
+	s.mu.Lock()
+	e, ok := s.entries[bookID]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	e.Running = true
+	s.mu.Unlock()
+
+	r := Run{StartedAt: time.Now()}
+	res, err := s.run(ctx, bookID)
+	r.FinishedAt = time.Now()
+	r.Added, r.Exports = res.Added, res.Exports
+	if err != nil {
+		r.Error = err.Error()
+		log.Printf("定时更新 %s 失败: %v", bookID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Running = false
+	if _, ok := s.entries[bookID]; !ok {
+		return // 运行期间被取消订阅
+	}
+	if ctx.Err() != nil {
+		return // 服务退出，保留原定时间，重启后补跑
+	}
+	e.LastRun = &r
+	e.History = append([]Run{r}, e.History...)
+	if len(e.History) > maxHistory {
+		e.History = e.History[:maxHistory]
+	}
+	e.NextRun = r.FinishedAt.Add(s.jittered(e))
+	if err := s.save(); err != nil {
+		log.Printf("保存调度状态失败: %v", err)
+	}
+}
+
+// jittered 返回带随机浮动的检查间隔，调用方需持有 s.mu。
+func (s *Scheduler) jittered(e *Entry) time.Duration {
+	d := time.Duration(e.Interval) * time.Minute
+	if d <= 0 {
+		d = s.interval(e.BookID)
+	}
+	if d <= 0 {
+		d = 6 * time.Hour
+	}
+	j := time.Duration(float64(d) * jitterFrac * (2*rand.Float64() - 1))
+	return d + j
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) copy(e *Entry) Entry {
+	c := *e
+	c.History = append([]Run(nil), e.History...)
+	return c
+}
+
+// save 原子写回状态文件，调用方需持有 s.mu。
+func (s *Scheduler) save() error {
+	list := make([]*Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].BookID < list[j].BookID })
+	b, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,60 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// GB2312 一级汉字按拼音排序，可以用区位码边界推出声母首字母。
+// 二级汉字按部首排序，无法据此推断，直接忽略。
+var gbInitials = []struct {
+	code   int
+	letter byte
+}{
+	{0xB0A1, 'a'}, {0xB0C5, 'b'}, {0xB2C1, 'c'}, {0xB4EE, 'd'}, {0xB6EA, 'e'},
+	{0xB7A2, 'f'}, {0xB8C1, 'g'}, {0xB9FE, 'h'}, {0xBBF7, 'j'}, {0xBFA6, 'k'},
+	{0xC0AC, 'l'}, {0xC2E8, 'm'}, {0xC4C3, 'n'}, {0xC5B6, 'o'}, {0xC5BE, 'p'},
+	{0xC6DA, 'q'}, {0xC8BB, 'r'}, {0xC8F6, 's'}, {0xCBFA, 't'}, {0xCDDA, 'w'},
+	{0xCEF4, 'x'}, {0xD1B9, 'y'}, {0xD4D1, 'z'},
+}
+
+const gbLevel1End = 0xD7F9
+
+// Initials 返回字符串的拼音首字母（小写），ASCII 字母/数字原样保留，其它字符跳过。
+func Initials(s string) string {
+	enc := simplifiedchinese.GBK.NewEncoder()
+	var sb strings.Builder
+	for _, r := range s {
+		if r <= unicode.MaxASCII {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				sb.WriteRune(unicode.ToLower(r))
+			}
+			continue
+		}
+		if !unicode.Is(unicode.Han, r) {
+			continue
+		}
+		b, err := enc.Bytes([]byte(string(r)))
+		if err != nil || len(b) != 2 {
+			continue
+		}
+		if c := initialOf(int(b[0])<<8 | int(b[1])); c != 0 {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func initialOf(code int) byte {
+	if code < gbInitials[0].code || code > gbLevel1End {
+		return 0
+	}
+	for i := len(gbInitials) - 1; i >= 0; i-- {
+		if code >= gbInitials[i].code {
+			return gbInitials[i].letter
+		}
+	}
+	return 0
+}
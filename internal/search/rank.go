@@ -0,0 +1,204 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+// 排序方式
+const (
+	SortRelevance = "relevance" // 相关度（默认）
+	SortSource    = "source"    // 保持书源返回顺序
+	SortUpdate    = "update"    // 按更新时间倒序
+)
+
+// 匹配档位：分值越高越靠前
+const (
+	tierNone          = 0
+	tierPinyin        = 1 // 拼音首字母匹配
+	tierKeywordAuthor = 2 // 关键词里含有作者名（如“作者 书名”一起搜），标题未直接匹配
+	tierFuzzy         = 3 // 模糊匹配（包含 / 子序列）
+	tierAuthor        = 4 // 作者匹配
+	tierPrefix        = 5 // 标题前缀匹配
+	tierExact         = 6 // 标题完全匹配
+)
+
+// Result 是一条带书源信息的搜索结果。
+type Result struct {
+	sources.Book
+	Source string `json:"source"` // 书源名称
+}
+
+// ValidSort 判断排序参数是否合法（空串视为默认）。
+func ValidSort(by string) bool {
+	switch by {
+	case "", SortRelevance, SortSource, SortUpdate:
+		return true
+	}
+	return false
+}
+
+// Rank 按 by 指定的方式对结果排序（原地、稳定）。
+// 相关度排序：标题完全匹配 > 标题前缀 > 作者匹配 > 模糊匹配 > 关键词含作者名 > 拼音首字母，同档再按更新时间倒序。
+func Rank(keyword string, items []Result, by string) {
+	switch by {
+	case SortSource:
+		return
+	case SortUpdate:
+		sort.SliceStable(items, func(i, j int) bool {
+			return parseUpdate(items[i].Update).After(parseUpdate(items[j].Update))
+		})
+		return
+	}
+
+	type scored struct {
+		score int
+		upd   time.Time
+	}
+	keys := make([]scored, len(items))
+	for i, it := range items {
+		keys[i] = scored{score: Score(keyword, it.Book), upd: parseUpdate(it.Update)}
+	}
+	idx := make([]int, len(items))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ka, kb := keys[idx[a]], keys[idx[b]]
+		if ka.score != kb.score {
+			return ka.score > kb.score
+		}
+		return ka.upd.After(kb.upd)
+	})
+	sorted := make([]Result, len(items))
+	for i, k := range idx {
+		sorted[i] = items[k]
+	}
+	copy(items, sorted)
+}
+
+// Score 计算单本书相对关键词的相关度分值：档位 * 1000 + 档内细分（0~999）。
+func Score(keyword string, b sources.Book) int {
+	kw := sources.NormalizeText(keyword)
+	if kw == "" {
+		return 0
+	}
+	title := sources.NormalizeText(b.Title)
+	author := sources.NormalizeText(b.Author)
+
+	switch {
+	case title == kw:
+		return tierExact * 1000
+	case strings.HasPrefix(title, kw):
+		// 前缀越接近全名越靠前
+		return tierPrefix*1000 + ratio(kw, title)
+	case author != "" && strings.Contains(author, kw):
+		return tierAuthor*1000 + ratio(kw, author)
+	case strings.Contains(title, kw):
+		// 越靠前、占比越大越相关
+		pos := utf8.RuneCountInString(title[:strings.Index(title, kw)])
+		return tierFuzzy*1000 + 500 + ratio(kw, title)/2 - min(pos, 100)
+	case isSubsequence(kw, title):
+		return tierFuzzy*1000 + ratio(kw, title)/2
+	case utf8.RuneCountInString(author) >= 2 && strings.Contains(kw, author):
+		// 作者名太短（如单字）时几乎任何关键词都会包含它，不算匹配；其余部分出现在标题里时更相关
+		if rest := strings.Replace(kw, author, "", 1); strings.Contains(title, rest) {
+			return tierKeywordAuthor*1000 + 500 + ratio(rest, title)/2
+		}
+		return tierKeywordAuthor*1000 + ratio(author, kw)/2
+	}
+	if isASCIILetters(kw) {
+		ini := Initials(b.Title)
+		switch {
+		case ini == kw:
+			return tierPinyin*1000 + 500
+		case strings.HasPrefix(ini, kw):
+			return tierPinyin*1000 + ratio(kw, ini)/2
+		}
+	}
+	return tierNone
+}
+
+// ratio 返回 sub 占 s 的比例（0~999）。
+func ratio(sub, s string) int {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return 0
+	}
+	r := utf8.RuneCountInString(sub) * 999 / n
+	if r > 999 {
+		r = 999
+	}
+	return r
+}
+
+// isSubsequence 判断 kw 的字符是否按顺序出现在 s 中。
+func isSubsequence(kw, s string) bool {
+	rs := []rune(s)
+	i := 0
+	for _, r := range kw {
+		for i < len(rs) && rs[i] != r {
+			i++
+		}
+		if i == len(rs) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+func isASCIILetters(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// 常见的更新时间格式；解析失败的视为零值（排在最后）
+var updateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"06-01-02 15:04",
+	"06-01-02",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006年01月02日",
+	"01-02 15:04",
+	"01-02",
+}
+
+func parseUpdate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}
+	}
+	// 去掉“更新时间：”之类的前缀
+	if i := strings.IndexFunc(s, unicode.IsDigit); i > 0 {
+		s = s[i:]
+	}
+	for _, layout := range updateLayouts {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if t.Year() == 0 {
+			// 只有月日：默认今年，若晚于现在则视为去年
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now) {
+				t = t.AddDate(-1, 0, 0)
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
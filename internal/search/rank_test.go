@@ -0,0 +1,73 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/sreio/go-novel/internal/sources"
+)
+
+func TestScoreOrdering(t *testing.T) {
+	tests := []struct {
+		name          string
+		keyword       string
+		better, worse sources.Book
+	}{
+		{"exact over prefix", "斗破苍穹", sources.Book{Title: "斗破苍穹"}, sources.Book{Title: "斗破苍穹之无上之境"}},
+		{"prefix over author", "斗破", sources.Book{Title: "斗破苍穹"}, sources.Book{Title: "武动乾坤", Author: "斗破"}},
+		{"author over title contains", "土豆", sources.Book{Title: "武动乾坤", Author: "天蚕土豆"}, sources.Book{Title: "我的土豆地"}},
+		{"shorter prefix ranks lower", "斗破", sources.Book{Title: "斗破苍穹"}, sources.Book{Title: "斗破苍穹同人合集"}},
+		{"earlier position ranks higher", "苍穹", sources.Book{Title: "苍穹之上"}, sources.Book{Title: "我在苍穹"}},
+		{"contains over subsequence", "苍穹", sources.Book{Title: "斗破苍穹"}, sources.Book{Title: "苍茫的穹顶"}},
+		// 单字作者几乎被任何关键词包含，不能排在标题匹配前面
+		{"title contains over one-rune author", "斗破苍穹", sources.Book{Title: "重生之斗破苍穹"}, sources.Book{Title: "随便", Author: "斗"}},
+		{"title contains over keyword with author", "天蚕土豆斗破", sources.Book{Title: "天蚕土豆斗破"}, sources.Book{Title: "斗破苍穹", Author: "天蚕土豆"}},
+		{"title subsequence over keyword with author", "天蚕土豆斗破", sources.Book{Title: "天蚕的土豆斗破记"}, sources.Book{Title: "斗破苍穹", Author: "天蚕土豆"}},
+		{"keyword with author and title", "天蚕土豆斗破", sources.Book{Title: "斗破苍穹", Author: "天蚕土豆"}, sources.Book{Title: "武动乾坤", Author: "天蚕土豆"}},
+		{"keyword with author over pinyin", "tcs", sources.Book{Title: "某书", Author: "tcs"}, sources.Book{Title: "天蚕书"}},
+		{"pinyin over nothing", "frxxc", sources.Book{Title: "凡人修仙传"}, sources.Book{Title: "武动乾坤"}},
+		{"full initials over prefix", "frxx", sources.Book{Title: "凡人修仙"}, sources.Book{Title: "凡人修仙传"}},
+		{"full-width and case folded", "ＡＢＣ", sources.Book{Title: "abc"}, sources.Book{Title: "abcd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, w := Score(tt.keyword, tt.better), Score(tt.keyword, tt.worse)
+			if b <= w {
+				t.Errorf("Score(%q, %q/%q) = %d, want > %d for %q/%q", tt.keyword, tt.better.Title, tt.better.Author, b, w, tt.worse.Title, tt.worse.Author)
+			}
+		})
+	}
+}
+
+func TestScoreNoMatch(t *testing.T) {
+	tests := []struct {
+		keyword string
+		book    sources.Book
+	}{
+		{"", sources.Book{Title: "斗破苍穹"}},
+		{"凡人修仙", sources.Book{Title: "斗破苍穹", Author: "天蚕土豆"}},
+		{"斗破苍穹", sources.Book{Title: "随便", Author: "斗"}}, // 单字作者
+		{"xyz", sources.Book{Title: "斗破苍穹"}},
+	}
+	for _, tt := range tests {
+		if s := Score(tt.keyword, tt.book); s != tierNone {
+			t.Errorf("Score(%q, %q/%q) = %d, want %d", tt.keyword, tt.book.Title, tt.book.Author, s, tierNone)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	items := []Result{
+		{Book: sources.Book{Title: "随便", Author: "斗"}},
+		{Book: sources.Book{Title: "斗破苍穹同人", Update: "2024-01-01"}},
+		{Book: sources.Book{Title: "斗破苍穹之无上之境", Update: "2024-05-01"}},
+		{Book: sources.Book{Title: "斗破苍穹"}},
+		{Book: sources.Book{Title: "重生之斗破苍穹"}},
+	}
+	Rank("斗破苍穹", items, SortRelevance)
+	want := []string{"斗破苍穹", "斗破苍穹同人", "斗破苍穹之无上之境", "重生之斗破苍穹", "随便"}
+	for i, it := range items {
+		if it.Title != want[i] {
+			t.Errorf("Rank()[%d] = %q, want %q", i, it.Title, want[i])
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrNoCover 表示详情页中没有找到封面。
+var ErrNoCover = errors.New("no cover")
+
+// maxCoverSize 是封面图片的大小上限。
+const maxCoverSize = 10 << 20
+
+// CoverURL 从书籍详情页提取封面地址：优先 book.cover_selector，未配置或没有匹配时使用 og:image。
+func (s *ConfigSource) CoverURL(ctx context.Context, bookURL string) (string, error) {
+	doc, _, err := s.client.DocumentURL(ctx, bookURL, s.cfg.Headers, s.cfg.Charset)
+	if err != nil {
+		return "", err
+	}
+	var raw string
+	if sel := strings.TrimSpace(s.cfg.Book.CoverSelector); sel != "" {
+		attr := s.cfg.Book.CoverAttr
+		if attr == "" {
+			attr = "src"
+		}
+		raw, _ = doc.Find(sel).First().Attr(attr)
+	}
+	if strings.TrimSpace(raw) == "" {
+		raw, _ = doc.Find(`meta[property="og:image"]`).First().Attr("content")
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "data:") {
+		return "", ErrNoCover
+	}
+	base, err := url.Parse(bookURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// Cover 下载详情页中的封面图片（经过书源的限速、重试与熔断），返回原始图片数据。
+func (s *ConfigSource) Cover(ctx context.Context, bookURL string) ([]byte, error) {
+	u, err := s.CoverURL(ctx, bookURL)
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{"Referer": bookURL}
+	for k, v := range s.cfg.Headers {
+		headers[k] = v
+	}
+	b, resp, err := s.client.request(ctx, http.MethodGet, u, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+	if len(b) == 0 || len(b) > maxCoverSize || !strings.HasPrefix(http.DetectContentType(b), "image/") {
+		return nil, ErrNoCover
+	}
+	return b, nil
+}
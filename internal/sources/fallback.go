@@ -0,0 +1,286 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// 书源返回的“占位”正文：内容尚未更新，视同失败
+var placeholderMarkers = []string{
+	"章节内容正在手打中",
+	"正在手打中",
+	"内容更新中",
+}
+
+// ErrPlaceholder 表示书源只返回了占位内容，且没有备用书源提供正文。这样的章节按失败处理，不写入检查点。
+var ErrPlaceholder = errors.New("placeholder content")
+
+// IsPlaceholder 判断正文是否为空或只是站点的占位提示。
+func IsPlaceholder(content string) bool {
+	c := strings.TrimSpace(content)
+	if c == "" {
+		return true
+	}
+	// 占位提示一般很短，避免误伤正文里恰好出现这些字样的章节
+	if len([]rune(c)) > 200 {
+		return false
+	}
+	for _, m := range placeholderMarkers {
+		if strings.Contains(c, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeText 统一全角/半角、大小写，并去掉空白与标点，用于标题/作者比对。
+func NormalizeText(s string) string {
+	s = width.Fold.String(s)
+	var sb strings.Builder
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+var chapterNoRe = regexp.MustCompile(`第\s*([0-9零〇一二两三四五六七八九十百千万]+)\s*[章节回]`)
+
+// ChapterNumber 从章节标题里提取“第 N 章”的序号，取不到返回 0。
+func ChapterNumber(title string) int {
+	m := chapterNoRe.FindStringSubmatch(width.Fold.String(title))
+	if len(m) < 2 {
+		return 0
+	}
+	if n, err := strconv.Atoi(m[1]); err == nil {
+		return n
+	}
+	return parseHanNumber(m[1])
+}
+
+// NormalizeChapterTitle 去掉“第 N 章”前缀后再归一化，不同站点的标题写法差异多在这里。
+func NormalizeChapterTitle(title string) string {
+	t := width.Fold.String(title)
+	if loc := chapterNoRe.FindStringIndex(t); loc != nil {
+		t = t[loc[1]:]
+	}
+	return NormalizeText(t)
+}
+
+func parseHanNumber(s string) int {
+	digits := map[rune]int{'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	units := map[rune]int{'十': 10, '百': 100, '千': 1000}
+	total, section, num := 0, 0, 0
+	for _, r := range s {
+		if d, ok := digits[r]; ok {
+			num = d
+			continue
+		}
+		if u, ok := units[r]; ok {
+			if num == 0 {
+				num = 1 // “十二” = 12
+			}
+			section += num * u
+			num = 0
+			continue
+		}
+		if r == '万' {
+			total += (section + num) * 10000
+			section, num = 0, 0
+		}
+	}
+	return total + section + num
+}
+
+// ChapterOrigin 记录某一章实际来自哪个书源（仅记录使用了备用源的章节）。
+type ChapterOrigin struct {
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// altBook 是同一本书在备用书源上的章节目录。
+type altBook struct {
+	src     *ConfigSource
+	byTitle map[string][]Chapter // 归一化标题可能重复（如“上”“下”），保留全部候选
+	byNo    map[int]Chapter
+}
+
+func newAltBook(src *ConfigSource, chs []Chapter) *altBook {
+	ab := &altBook{src: src, byTitle: make(map[string][]Chapter, len(chs)), byNo: make(map[int]Chapter, len(chs))}
+	for _, c := range chs {
+		if t := NormalizeChapterTitle(c.Title); t != "" {
+			ab.byTitle[t] = append(ab.byTitle[t], c)
+		}
+		if n := ChapterNumber(c.Title); n > 0 {
+			if _, dup := ab.byNo[n]; !dup {
+				ab.byNo[n] = c
+			}
+		}
+	}
+	return ab
+}
+
+// Fallback 在主书源章节失败或返回占位内容时，从其它书源中按“书名+作者”找到同一本书，
+// 再按章节序号或归一化章节标题映射到对应章节抓取正文。
+type Fallback struct {
+	primary *ConfigSource
+	others  []*ConfigSource
+	title   string
+	author  string
+
+	// ctx 是整次下载的 context：备用书源只查找一次，结果供之后所有章节使用，不应受某一章的 context 影响
+	ctx      context.Context
+	mu       sync.Mutex
+	resolved bool          // 各备用书源都已查找完毕（找到或确定没有这本书）
+	retryAt  time.Time     // 查找出错（网络错误等）时，下次重试的时间
+	backoff  time.Duration // 下次出错后的重试间隔，每次翻倍
+	alts     []*altBook
+}
+
+// 备用书源查找出错后的重试间隔
+const (
+	resolveBackoff    = 5 * time.Second
+	resolveMaxBackoff = 2 * time.Minute
+)
+
+// NewFallback 创建备用书源查找器；ctx 为整次下载的 context。title 为空时无法匹配，Content 将直接返回主书源的错误。
+func NewFallback(ctx context.Context, primary *ConfigSource, all []*ConfigSource, title, author string) *Fallback {
+	f := &Fallback{ctx: ctx, primary: primary, title: strings.TrimSpace(title), author: strings.TrimSpace(author), backoff: resolveBackoff}
+	for _, s := range all {
+		if s != nil && s != primary {
+			f.others = append(f.others, s)
+		}
+	}
+	return f
+}
+
+// Content 先从主书源抓取，失败或遇到占位内容时依次尝试备用书源。
+// 返回的 from 为实际提供正文的书源名称；都只有占位内容时返回 ErrPlaceholder。
+func (f *Fallback) Content(ctx context.Context, ch Chapter) (content, from string, err error) {
+	content, err = f.primary.Content(ctx, ch)
+	if err == nil && !IsPlaceholder(content) {
+		return content, f.primary.Name(), nil
+	}
+	if err == nil {
+		err = fmt.Errorf("%s: %w", f.primary.Name(), ErrPlaceholder)
+	}
+	if f.title == "" || len(f.others) == 0 {
+		return "", f.primary.Name(), err
+	}
+
+	// 备用书源的请求不计入下载器对主书源的并发调节
+	actx := WithoutObserver(ctx)
+	for _, alt := range f.alternates() {
+		ach, ok := alt.match(ch)
+		if !ok {
+			continue
+		}
+		c, e := alt.src.Content(actx, ach)
+		if e != nil || IsPlaceholder(c) {
+			continue
+		}
+		return c, alt.src.Name(), nil
+	}
+	return "", f.primary.Name(), fmt.Errorf("%s: %w (no fallback source)", ch.URL, err)
+}
+
+// alternates 返回备用书源上的同一本书。第一次需要时查找；查找出错的书源（网络错误、下载中断等）
+// 不会让空结果缓存到下载结束，而是按退避间隔在之后的章节失败时重试，已找到的书源保留。
+func (f *Fallback) alternates() []*altBook {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.resolved || time.Now().Before(f.retryAt) || f.ctx.Err() != nil {
+		return f.alts
+	}
+	alts, err := f.resolve(f.ctx, f.alts)
+	f.alts = alts
+	if err == nil {
+		f.resolved = true
+		return f.alts
+	}
+	f.retryAt = time.Now().Add(f.backoff)
+	f.backoff = min(f.backoff*2, resolveMaxBackoff)
+	return f.alts
+}
+
+// resolve 在尚未找到这本书的其它书源上搜索同名同作者的书并拉取目录，追加到 found 后返回；
+// 有书源出错时返回的 error 非空，这些书源在重试时再次查找。
+func (f *Fallback) resolve(ctx context.Context, found []*altBook) ([]*altBook, error) {
+	wantTitle := NormalizeText(f.title)
+	wantAuthor := NormalizeText(f.author)
+	done := make(map[*ConfigSource]bool, len(found))
+	for _, ab := range found {
+		done[ab.src] = true
+	}
+	var errs []error
+	for _, s := range f.others {
+		if done[s] {
+			continue
+		}
+		if !s.Available() {
+			errs = append(errs, fmt.Errorf("%s: unavailable", s.Name()))
+			continue
+		}
+		books, err := s.Search(ctx, f.title, 1)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		for _, b := range books {
+			if NormalizeText(b.Title) != wantTitle {
+				continue
+			}
+			if wantAuthor != "" && !strings.Contains(NormalizeText(b.Author), wantAuthor) {
+				continue
+			}
+			chs, err := s.Chapters(ctx, b.ID, b.ID)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+				break
+			}
+			if len(chs) > 0 {
+				found = append(found, newAltBook(s, chs))
+				break
+			}
+		}
+	}
+	return found, errors.Join(errs...)
+}
+
+// match 找到备用书源上的同一章：先按章节序号；再按去掉序号后的标题，此时两边的序号必须一致或有一方没有序号，
+// 且没有序号时标题只能对应唯一的一章，避免“上”“下”“（求月票）”这类短标题串到别的章节。
+func (a *altBook) match(ch Chapter) (Chapter, bool) {
+	n := ChapterNumber(ch.Title)
+	if n > 0 {
+		if c, ok := a.byNo[n]; ok {
+			return c, true
+		}
+	}
+	t := NormalizeChapterTitle(ch.Title)
+	if t == "" {
+		return Chapter{}, false
+	}
+	var found []Chapter
+	for _, c := range a.byTitle[t] {
+		if m := ChapterNumber(c.Title); n == 0 || m == 0 || m == n {
+			found = append(found, c)
+		}
+	}
+	if len(found) != 1 {
+		return Chapter{}, false
+	}
+	return found[0], true
+}
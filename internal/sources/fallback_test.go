@@ -0,0 +1,189 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAltBookMatch(t *testing.T) {
+	alt := newAltBook(nil, []Chapter{
+		{Title: "第1章 出山", URL: "a1"},
+		{Title: "第2章 上", URL: "a2"},
+		{Title: "第3章 上", URL: "a3"},
+		{Title: "第四章 下", URL: "a4"},
+		{Title: "第5章 （求月票）", URL: "a5"},
+		{Title: "第6章 （求月票）", URL: "a6"},
+		{Title: "楔子", URL: "a-prologue"},
+		{Title: "番外 重逢", URL: "a-extra"},
+		{Title: "第 10 章 归来", URL: "a10"},
+	})
+	tests := []struct {
+		title string
+		want  string // 空串表示不应匹配
+	}{
+		{"第1章 出山", "a1"},
+		{"第一章 下山", "a1"},     // 序号优先于标题
+		{"第3章 上", "a3"},      // 标题“上”重复，按序号取第 3 章而不是第一个“上”
+		{"第3章 上（求月票）", "a3"}, // 标题不同但序号相同
+		{"第7章 上", ""},        // 序号不存在时，标题相同但序号不同的章节不算
+		{"上", ""},            // 没有序号且标题对应多章，无法确定
+		{"下", "a4"},          // 没有序号、标题唯一
+		{"第6章 （求月票）", "a6"},
+		{"（求月票）", ""},
+		{"楔子", "a-prologue"},
+		{"第8章 楔子", "a-prologue"}, // 备用书源一方没有序号
+		{"番外：重逢", "a-extra"},
+		{"第十章 归来", "a10"},
+		{"第11章 归来", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := alt.match(Chapter{Title: tt.title})
+		if tt.want == "" {
+			if ok {
+				t.Errorf("match(%q) = %q, want no match", tt.title, got.URL)
+			}
+			continue
+		}
+		if !ok || got.URL != tt.want {
+			t.Errorf("match(%q) = %q, %v, want %q", tt.title, got.URL, ok, tt.want)
+		}
+	}
+}
+
+func TestChapterNumber(t *testing.T) {
+	tests := []struct {
+		title string
+		want  int
+	}{
+		{"第1章 出山", 1},
+		{"第 12 章", 12},
+		{"第十二章", 12},
+		{"第一百零五章 归来", 105},
+		{"第两千三百章", 2300},
+		{"第１２回", 12},
+		{"楔子", 0},
+		{"番外", 0},
+	}
+	for _, tt := range tests {
+		if got := ChapterNumber(tt.title); got != tt.want {
+			t.Errorf("ChapterNumber(%q) = %d, want %d", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackContentObserver(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	var altHits atomic.Int32
+	alt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/search":
+			fmt.Fprint(w, `<ul><li><a href="/book/9">测试之书</a><span>某作者</span></li></ul>`)
+		case r.URL.Path == "/book/9":
+			fmt.Fprint(w, `<ul class="toc"><li><a href="/ch/1">第1章 出山</a></li></ul>`)
+		default:
+			// 备用书源第一次请求正文也返回 503，同样不能计入主书源
+			if altHits.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `<div class="content"><p>正文</p></div>`)
+		}
+	}))
+	defer alt.Close()
+
+	newSource := func(name, base string) *ConfigSource {
+		s, err := NewFromConfig(SourceConfig{
+			ID: name, Name: name, BaseURL: base, Retries: 1,
+			Search:   SearchConfig{Path: "/search", Param: "q", ItemSelector: "li", TitleSelector: "a", AuthorSelector: "span", LinkSelector: "a"},
+			Chapters: ChaptersConfig{ListSelector: "ul.toc li", TitleSelector: "a", URLSelector: "a"},
+			Content:  ContentConfig{ContentSelector: ".content p"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	p, a := newSource("primary", primary.URL), newSource("alt", alt.URL)
+
+	var observed atomic.Int32
+	ctx := WithObserver(context.Background(), func(Observation) { observed.Add(1) })
+	fb := NewFallback(context.Background(), p, []*ConfigSource{p, a}, "测试之书", "某作者")
+	content, from, err := fb.Content(ctx, Chapter{Title: "第1章 出山", URL: primary.URL + "/ch/1"})
+	if err != nil || from != "alt" || content == "" {
+		t.Fatalf("Content() = %q, %q, %v, want content from alt", content, from, err)
+	}
+	// 主书源重试 1 次共 2 次尝试；备用书源的 2 次正文请求不应被观察到
+	if n := observed.Load(); n != 2 {
+		t.Errorf("observed %d attempts, want 2 (primary only)", n)
+	}
+}
+
+// 主书源只有占位内容、备用书源也提供不了正文时返回 ErrPlaceholder，而不是把占位内容当作正文
+func TestFallbackContentPlaceholder(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<div class="content"><p>章节内容正在手打中，请稍后刷新</p></div>`)
+	}))
+	defer primary.Close()
+	newAlt := func(content string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/search":
+				fmt.Fprint(w, `<ul><li><a href="/book/9">测试之书</a><span>某作者</span></li></ul>`)
+			case "/book/9":
+				fmt.Fprint(w, `<ul class="toc"><li><a href="/ch/1">第1章 出山</a></li></ul>`)
+			default:
+				fmt.Fprint(w, content)
+			}
+		}))
+	}
+	altOK, altPlaceholder := newAlt(`<div class="content"><p>正文</p></div>`), newAlt(`<div class="content"></div>`)
+	defer altOK.Close()
+	defer altPlaceholder.Close()
+
+	newSource := func(name, base string) *ConfigSource {
+		s, err := NewFromConfig(SourceConfig{
+			ID: name, Name: name, BaseURL: base, Retries: 1,
+			Search:   SearchConfig{Path: "/search", Param: "q", ItemSelector: "li", TitleSelector: "a", AuthorSelector: "span", LinkSelector: "a"},
+			Chapters: ChaptersConfig{ListSelector: "ul.toc li", TitleSelector: "a", URLSelector: "a"},
+			Content:  ContentConfig{ContentSelector: ".content p"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	p := newSource("primary", primary.URL)
+	tests := []struct {
+		name     string
+		alts     []*ConfigSource
+		title    string
+		wantFrom string
+		wantErr  error
+	}{
+		{"no fallback sources", nil, "测试之书", "primary", ErrPlaceholder},
+		{"no title to match", []*ConfigSource{newSource("ok", altOK.URL)}, "", "primary", ErrPlaceholder},
+		{"fallback also placeholder", []*ConfigSource{newSource("empty", altPlaceholder.URL)}, "测试之书", "primary", ErrPlaceholder},
+		{"fallback has content", []*ConfigSource{newSource("empty", altPlaceholder.URL), newSource("ok", altOK.URL)}, "测试之书", "ok", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := NewFallback(context.Background(), p, append([]*ConfigSource{p}, tt.alts...), tt.title, "某作者")
+			content, from, err := fb.Content(context.Background(), Chapter{Title: "第1章 出山", URL: primary.URL + "/ch/1"})
+			if !errors.Is(err, tt.wantErr) || from != tt.wantFrom {
+				t.Fatalf("Content() = %q, %q, %v, want from %q, err %v", content, from, err, tt.wantFrom, tt.wantErr)
+			}
+			if err != nil && content != "" {
+				t.Errorf("Content() returned %q with an error", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package sources
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	StateClosed   = "closed"    // 正常放行
+	StateOpen     = "open"      // 熔断中，请求直接失败
+	StateHalfOpen = "half-open" // 冷却结束，放行一个探测请求
+)
+
+// ErrCircuitOpen 表示书源处于熔断状态，请求未发出。
+var ErrCircuitOpen = errors.New("source circuit open")
+
+const (
+	healthWindow        = 100              // 健康评分使用的最近请求数
+	breakerWindow       = 20               // 熔断判断使用的最近请求数
+	breakerMinSamples   = 10               // 按失败率熔断前至少需要的样本数
+	breakerFailureRatio = 0.5              // 最近请求失败率达到该值时熔断
+	defaultBreakerFails = 5                // 连续失败达到该值时熔断
+	defaultCooldown     = 30 * time.Second // 熔断后多久进入半开
+)
+
+// BreakerConfig 是书源熔断配置。
+type BreakerConfig struct {
+	Failures        int `yaml:"failures"`         // 连续失败多少次后熔断，默认 5
+	CooldownSeconds int `yaml:"cooldown_seconds"` // 熔断持续时间，默认 30 秒
+}
+
+// Health 是书源的健康快照。
+type Health struct {
+	State               string    `json:"state"`
+	Requests            int       `json:"requests"`    // 统计窗口内的请求数
+	SuccessRate         float64   `json:"successRate"` // 0~1，无样本时为 1
+	P50Ms               int64     `json:"p50Ms"`
+	P95Ms               int64     `json:"p95Ms"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenUntil           time.Time `json:"openUntil,omitzero"`
+}
+
+type sample struct {
+	ok      bool
+	latency time.Duration
+}
+
+// monitor 记录书源最近的请求结果，驱动熔断器（closed → open → half-open → closed）并计算健康评分。
+type monitor struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time // 时钟，测试时可替换
+
+	state     string
+	consec    int       // 连续失败次数
+	openUntil time.Time // 熔断结束时间
+	probing   bool      // 半开状态下是否已有探测请求在途
+
+	samples []sample // 环形缓冲
+	next    int
+
+	onChange func(from, to string) // 状态变化回调，在锁外调用
+}
+
+func newMonitor(cfg BreakerConfig) *monitor {
+	m := &monitor{threshold: cfg.Failures, cooldown: time.Duration(cfg.CooldownSeconds) * time.Second, state: StateClosed, now: time.Now}
+	if m.threshold <= 0 {
+		m.threshold = defaultBreakerFails
+	}
+	if m.cooldown <= 0 {
+		m.cooldown = defaultCooldown
+	}
+	return m
+}
+
+// allow 判断是否放行一次请求；半开状态只放行一个探测请求。
+func (m *monitor) allow() error {
+	m.mu.Lock()
+	from := m.state
+	err := m.allowLocked()
+	m.unlockAndNotify(from)
+	return err
+}
+
+func (m *monitor) allowLocked() error {
+	switch m.state {
+	case StateOpen:
+		if m.now().Before(m.openUntil) {
+			return ErrCircuitOpen
+		}
+		m.state = StateHalfOpen
+		m.probing = true
+		return nil
+	case StateHalfOpen:
+		if m.probing {
+			return ErrCircuitOpen
+		}
+		m.probing = true
+	}
+	return nil
+}
+
+// available 判断书源当前是否可用（熔断中且未到冷却时间则不可用），不改变状态。
+func (m *monitor) available() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state != StateOpen || !m.now().Before(m.openUntil)
+}
+
+// release 归还未产生结果（如被取消）的探测名额。
+func (m *monitor) release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == StateHalfOpen {
+		m.probing = false
+	}
+}
+
+// record 记录一次请求结果。
+func (m *monitor) record(ok bool, latency time.Duration) {
+	m.mu.Lock()
+	from := m.state
+	m.recordLocked(ok, latency)
+	m.unlockAndNotify(from)
+}
+
+// unlockAndNotify 释放锁，状态相对 from 发生变化时调用回调。
+func (m *monitor) unlockAndNotify(from string) {
+	to, fn := m.state, m.onChange
+	m.mu.Unlock()
+	if fn != nil && to != from {
+		fn(from, to)
+	}
+}
+
+func (m *monitor) setOnChange(fn func(from, to string)) {
+	m.mu.Lock()
+	m.onChange = fn
+	m.mu.Unlock()
+}
+
+func (m *monitor) recordLocked(ok bool, latency time.Duration) {
+	s := sample{ok: ok, latency: latency}
+	if len(m.samples) < healthWindow {
+		m.samples = append(m.samples, s)
+	} else {
+		m.samples[m.next] = s
+	}
+	m.next = (m.next + 1) % healthWindow
+
+	if ok {
+		m.consec = 0
+		if m.state == StateHalfOpen {
+			m.state = StateClosed
+			m.probing = false
+		}
+		return
+	}
+	m.consec++
+	switch {
+	case m.state == StateHalfOpen:
+		// 探测失败，重新熔断
+		m.trip()
+	case m.state == StateClosed && (m.consec >= m.threshold || m.recentFailureRatio() >= breakerFailureRatio):
+		m.trip()
+	}
+}
+
+func (m *monitor) trip() {
+	m.state = StateOpen
+	m.probing = false
+	m.openUntil = m.now().Add(m.cooldown)
+}
+
+// recentFailureRatio 返回最近 breakerWindow 次请求的失败率，样本不足时返回 0。
+func (m *monitor) recentFailureRatio() float64 {
+	n := min(len(m.samples), breakerWindow)
+	if n < breakerMinSamples {
+		return 0
+	}
+	fails := 0
+	for k := 1; k <= n; k++ {
+		i := (m.next - k + len(m.samples)) % len(m.samples)
+		if !m.samples[i].ok {
+			fails++
+		}
+	}
+	return float64(fails) / float64(n)
+}
+
+func (m *monitor) snapshot() Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h := Health{State: m.state, Requests: len(m.samples), SuccessRate: 1, ConsecutiveFailures: m.consec}
+	if m.state == StateOpen {
+		if m.now().Before(m.openUntil) {
+			h.OpenUntil = m.openUntil
+		} else {
+			h.State = StateHalfOpen // 冷却已结束，下一次请求即为探测
+		}
+	}
+	if len(m.samples) == 0 {
+		return h
+	}
+	ok := 0
+	var lat []time.Duration
+	for _, s := range m.samples {
+		if s.ok {
+			ok++
+			lat = append(lat, s.latency)
+		}
+	}
+	h.SuccessRate = float64(ok) / float64(len(m.samples))
+	if len(lat) > 0 {
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		h.P50Ms = percentile(lat, 50).Milliseconds()
+		h.P95Ms = percentile(lat, 95).Milliseconds()
+	}
+	return h
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i < 1 {
+		i = 1
+	}
+	return sorted[i-1]
+}
@@ -0,0 +1,125 @@
+package sources
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMonitor 创建使用假时钟的 monitor，返回用于拨动时钟的函数。
+func newTestMonitor(cfg BreakerConfig) (*monitor, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newMonitor(cfg)
+	m.now = func() time.Time { return now }
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreaker(t *testing.T) {
+	cfg := BreakerConfig{Failures: 3, CooldownSeconds: 10}
+	tests := []struct {
+		name        string
+		cfg         BreakerConfig
+		steps       string // 空格分隔：ok/fail 记录结果，allow/deny 断言是否放行，release 归还探测名额，+10s 拨动时钟
+		state       string // snapshot 中的状态
+		transitions string // onChange 依次收到的状态变化
+	}{
+		{"below threshold", cfg, "fail fail allow", StateClosed, ""},
+		{"trips at threshold", cfg, "fail fail fail deny", StateOpen, "closed>open"},
+		{"success resets count", cfg, "fail fail ok fail fail allow", StateClosed, ""},
+		{"default threshold", BreakerConfig{}, "fail fail fail fail allow fail deny", StateOpen, "closed>open"},
+		{"still cooling down", cfg, "fail fail fail +9s deny", StateOpen, "closed>open"},
+		{"cooldown elapsed", cfg, "fail fail fail +10s", StateHalfOpen, "closed>open"},
+		{"default cooldown", BreakerConfig{Failures: 1}, "fail +29s deny +1s allow", StateHalfOpen, "closed>open open>half-open"},
+		{"single probe", cfg, "fail fail fail +10s allow deny deny", StateHalfOpen, "closed>open open>half-open"},
+		{"released probe", cfg, "fail fail fail +10s allow release allow deny", StateHalfOpen, "closed>open open>half-open"},
+		{"probe succeeds", cfg, "fail fail fail +10s allow ok allow allow", StateClosed, "closed>open open>half-open half-open>closed"},
+		{"probe fails", cfg, "fail fail fail +10s allow fail deny +9s deny", StateOpen, "closed>open open>half-open half-open>open"},
+		{"reopened cooldown", cfg, "fail fail fail +10s allow fail +10s allow ok", StateClosed, "closed>open open>half-open half-open>open open>half-open half-open>closed"},
+		// 连续失败未到阈值，但最近 10 次有一半失败
+		{"failure ratio", BreakerConfig{Failures: 100}, "ok fail ok fail ok fail ok fail ok fail deny", StateOpen, "closed>open"},
+		{"too few samples", BreakerConfig{Failures: 100}, "ok fail ok fail ok fail ok fail fail allow", StateClosed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, advance := newTestMonitor(tt.cfg)
+			var got []string
+			m.setOnChange(func(from, to string) { got = append(got, from+">"+to) })
+			for i, step := range strings.Fields(tt.steps) {
+				switch step {
+				case "ok", "fail":
+					m.record(step == "ok", time.Millisecond)
+				case "allow", "deny":
+					err := m.allow()
+					if step == "allow" && err != nil || step == "deny" && !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d (%s): allow() = %v", i+1, step, err)
+					}
+				case "release":
+					m.release()
+				default:
+					d, err := time.ParseDuration(step)
+					if err != nil {
+						t.Fatalf("bad step %q", step)
+					}
+					advance(d)
+				}
+			}
+			h := m.snapshot()
+			if h.State != tt.state {
+				t.Errorf("state = %s, want %s", h.State, tt.state)
+			}
+			if h.State == StateOpen && h.OpenUntil.IsZero() || h.State != StateOpen && !h.OpenUntil.IsZero() {
+				t.Errorf("state %s with openUntil %v", h.State, h.OpenUntil)
+			}
+			if m.available() != (h.State != StateOpen) {
+				t.Errorf("available() = %v in state %s", m.available(), h.State)
+			}
+			if s := strings.Join(got, " "); s != tt.transitions {
+				t.Errorf("transitions = %q, want %q", s, tt.transitions)
+			}
+		})
+	}
+}
+
+func TestHealthSnapshot(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	// oks 返回 n 个成功样本，延迟依次为 step、2*step…毫秒
+	oks := func(n, step int) []sample {
+		var s []sample
+		for i := 1; i <= n; i++ {
+			s = append(s, sample{ok: true, latency: ms(i * step)})
+		}
+		return s
+	}
+	fails := func(n int) []sample {
+		var s []sample
+		for range n {
+			s = append(s, sample{latency: 5 * time.Second}) // 失败请求的耗时不计入分位数
+		}
+		return s
+	}
+	tests := []struct {
+		name    string
+		samples []sample
+		want    Health
+	}{
+		{"empty", nil, Health{State: StateClosed, SuccessRate: 1}},
+		{"single", oks(1, 40), Health{State: StateClosed, Requests: 1, SuccessRate: 1, P50Ms: 40, P95Ms: 40}},
+		{"with failures", append(oks(20, 10), fails(5)...), Health{State: StateClosed, Requests: 25, SuccessRate: 0.8, P50Ms: 100, P95Ms: 190, ConsecutiveFailures: 5}},
+		{"all failed", fails(4), Health{State: StateClosed, Requests: 4, SuccessRate: 0, ConsecutiveFailures: 4}},
+		// 只保留最近 100 个样本：前 50 个慢请求被挤出窗口
+		{"window", append(oks(50, 1000), oks(100, 1)...), Health{State: StateClosed, Requests: 100, SuccessRate: 1, P50Ms: 50, P95Ms: 95}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestMonitor(BreakerConfig{Failures: 1000})
+			for _, s := range tt.samples {
+				m.recordLocked(s.ok, s.latency)
+			}
+			if got := m.snapshot(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("snapshot() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
     "context"
     "crypto/tls"
     "errors"
-    "fmt"
     "io"
     "math"
     "math/rand"
@@ -29,6 +28,7 @@ type HTTPClient struct {
     baseURL  string
     defHeads map[string]string
     charset  string // 默认字符集（可被页面 meta 覆盖）
+    mon      *monitor // 熔断与健康统计
 }
 
 func NewHTTPClient(cfg SourceConfig) (*HTTPClient, error) {
@@ -70,6 +70,7 @@ func NewHTTPClient(cfg SourceConfig) (*HTTPClient, error) {
         baseURL:  strings.TrimRight(cfg.BaseURL, "/"),
         defHeads: map[string]string{"User-Agent": "go-novel/1.0"},
         charset:  strings.ToLower(cfg.Charset),
+        mon:      newMonitor(cfg.Breaker),
     }, nil
 }
 
@@ -80,10 +81,22 @@ func (c *HTTPClient) wait(ctx context.Context) error {
 
 func (c *HTTPClient) do(ctx context.Context, req *http.Request) (*http.Response, error) {
     for k, v := range c.defHeads { if req.Header.Get(k) == "" { req.Header.Set(k, v) } }
+    observe := observerFrom(ctx)
     var lastErr error
     for attempt := 0; attempt <= c.retries; attempt++ {
-        if err := c.wait(ctx); err != nil { return nil, err }
+        // 熔断中直接失败，不再耗尽重试预算
+        if err := c.mon.allow(); err != nil { return nil, err }
+        if err := c.wait(ctx); err != nil { c.mon.release(); return nil, err }
+        start := time.Now()
         resp, err := c.hc.Do(req.WithContext(ctx))
+        o := Observation{Latency: time.Since(start), Err: err}
+        if resp != nil { o.Status = resp.StatusCode }
+        if ctx.Err() != nil {
+            c.mon.release()
+        } else {
+            c.mon.record(err == nil && !o.Overloaded(), o.Latency)
+            if observe != nil { observe(o) }
+        }
         if err == nil && (resp.StatusCode < 500 && resp.StatusCode != 429) {
             return resp, nil
         }
@@ -92,7 +105,7 @@ func (c *HTTPClient) do(ctx context.Context, req *http.Request) (*http.Response,
                 lastErr = err
             } else { return nil, err }
         } else {
-            lastErr = fmt.Errorf("http %d", resp.StatusCode)
+            lastErr = &StatusError{Code: resp.StatusCode}
             io.Copy(io.Discard, resp.Body)
             resp.Body.Close()
         }
@@ -0,0 +1,63 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// StatusError 表示重试耗尽后仍然是 429/5xx 的响应。
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string { return fmt.Sprintf("http %d", e.Code) }
+
+// Observation 是一次 HTTP 请求尝试（含重试中的每一次）的结果。
+type Observation struct {
+	Status  int           // 响应状态码，请求出错时为 0
+	Latency time.Duration // 从发出请求到收到响应头的耗时
+	Err     error
+}
+
+// Overloaded 判断站点是否在“喊停”：429、5xx 或超时。
+func (o Observation) Overloaded() bool {
+	if o.Status == 429 || o.Status >= 500 {
+		return true
+	}
+	return IsOverload(o.Err)
+}
+
+// IsOverload 判断错误是否由站点过载引起（429/5xx/超时）。
+func IsOverload(err error) bool {
+	if err == nil {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code == 429 || se.Code >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+type observerKey struct{}
+
+// WithObserver 返回一个携带观察回调的 context，经由它发出的每次 HTTP 尝试都会回调 fn。
+// 下载器据此在重试耗尽之前就感知到站点压力。
+func WithObserver(ctx context.Context, fn func(Observation)) context.Context {
+	return context.WithValue(ctx, observerKey{}, fn)
+}
+
+// WithoutObserver 去掉 ctx 上的观察回调。备用书源是其它站点，它们的 429/5xx 不应让下载器
+// 降低对主书源的并发。
+func WithoutObserver(ctx context.Context) context.Context {
+	return context.WithValue(ctx, observerKey{}, (func(Observation))(nil))
+}
+
+func observerFrom(ctx context.Context) func(Observation) {
+	fn, _ := ctx.Value(observerKey{}).(func(Observation))
+	return fn
+}
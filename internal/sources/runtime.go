@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -39,6 +40,42 @@ func (s *ConfigSource) Name() string {
 	return s.cfg.BaseURL
 }
 
+// BaseURL 返回书源站点地址。
+func (s *ConfigSource) BaseURL() string { return s.cfg.BaseURL }
+
+// Health 返回书源的熔断状态与最近请求的健康统计（成功率、p50/p95 延迟）。
+func (s *ConfigSource) Health() Health { return s.client.mon.snapshot() }
+
+// Available 判断书源当前是否可用；熔断中的书源在搜索时会被跳过。
+func (s *ConfigSource) Available() bool { return s.client.mon.available() }
+
+// UpdateInterval 返回书源配置的更新检查间隔，未配置时返回 0。
+func (s *ConfigSource) UpdateInterval() time.Duration {
+	return time.Duration(s.cfg.UpdateInterval) * time.Minute
+}
+
+// OnStateChange 注册熔断状态变化回调（closed/open/half-open），回调不应阻塞。
+func (s *ConfigSource) OnStateChange(fn func(from, to string)) { s.client.mon.setOnChange(fn) }
+
+// Concurrency 返回该书源的起步并发与并发上限；def 为未配置上限时使用的全局并发数。
+func (s *ConfigSource) Concurrency(def int) (initial, max int) {
+	max = s.cfg.Concurrency.Max
+	if max <= 0 {
+		max = def
+	}
+	if max <= 0 {
+		max = 1
+	}
+	initial = s.cfg.Concurrency.Initial
+	if initial <= 0 {
+		initial = (max + 1) / 2
+	}
+	if initial > max {
+		initial = max
+	}
+	return initial, max
+}
+
 func (s *ConfigSource) Search(ctx context.Context, keyword string, page int) ([]Book, error) {
 	sc := s.cfg.Search
 	if sc.ItemSelector == "" {
@@ -373,12 +410,16 @@ func (s *ConfigSource) Content(ctx context.Context, ch Chapter) (string, error)
 	if sel == "" {
 		return "", errors.New("content.content_selector empty")
 	}
+	// 保留段落：每个匹配元素、<br> 与内部的块级元素各自成行，正文按行分段
 	var parts []string
 	doc.Find(sel).Each(func(_ int, p *goquery.Selection) {
-		t := strings.TrimSpace(p.Text())
-		if t != "" {
-			parts = append(parts, t)
+		p.Find("br").ReplaceWithHtml("\n")
+		p.Find("p, div").AfterHtml("\n")
+		for _, line := range strings.Split(p.Text(), "\n") {
+			if t := strings.TrimSpace(line); t != "" {
+				parts = append(parts, t)
+			}
 		}
 	})
-	return strings.Join(parts, ""), nil
+	return strings.Join(parts, "\n"), nil
 }
@@ -9,6 +9,13 @@ type RateConfig struct {
 	Burst int     `yaml:"burst"`
 }
 
+// ConcurrencyConfig 是书源的并发上限；下载器从 Initial 起步，响应健康时逐步加到 Max，
+// 遇到 429/5xx/超时则减半。
+type ConcurrencyConfig struct {
+	Initial int `yaml:"initial"` // 起步并发，默认 Max 的一半
+	Max     int `yaml:"max"`     // 并发上限，未配置时使用全局 --concurrency / CONCURRENCY
+}
+
 type SearchConfig struct {
 	// 方式一：新方式（推荐）
 	Path  string `yaml:"path"`  // 例如: /search.php
@@ -63,6 +70,12 @@ type ChaptersConfig struct {
 	} `yaml:"toc"`
 }
 
+// BookConfig 是书籍详情页的配置。
+type BookConfig struct {
+	CoverSelector string `yaml:"cover_selector"` // 封面图片选择器，如 "#fmimg img"；为空时使用 og:image
+	CoverAttr     string `yaml:"cover_attr"`     // 默认 src（懒加载图片可用 data-original 等）
+}
+
 type ContentConfig struct {
 	ContentSelector string `yaml:"content_selector"`
 }
@@ -73,11 +86,15 @@ type SourceConfig struct {
 	BaseURL        string            `yaml:"base_url"`
 	Charset        string            `yaml:"charset"`
 	Rate           RateConfig        `yaml:"rate_limit"`
+	Concurrency    ConcurrencyConfig `yaml:"concurrency"`
+	Breaker        BreakerConfig     `yaml:"circuit_breaker"`
+	UpdateInterval int               `yaml:"update_interval_minutes"` // 订阅书籍检查更新的间隔（分钟），0 表示使用全局默认值
 	Retries        int               `yaml:"retries"`
 	TimeoutSeconds int               `yaml:"timeout_seconds"`
 	Proxy          string            `yaml:"proxy"`
 	Headers        map[string]string `yaml:"headers"`
 	Search         SearchConfig      `yaml:"search"`
+	Book           BookConfig        `yaml:"book"`
 	Chapters       ChaptersConfig    `yaml:"chapters"`
 	Content        ContentConfig     `yaml:"content"`
 }
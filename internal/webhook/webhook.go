@@ -0,0 +1,297 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 事件类型
+const (
+	EventDownloadFinished = "download.finished" // 下载任务完成
+	EventDownloadFailed   = "download.failed"   // 下载任务失败
+	EventChaptersNew      = "chapters.new"      // 连载更新发现新章节
+	EventSourceUnhealthy  = "source.unhealthy"  // 书源熔断
+	EventPing             = "ping"              // 测试
+)
+
+// 请求头
+const (
+	HeaderEvent     = "X-GoNovel-Event"
+	HeaderDelivery  = "X-GoNovel-Delivery"
+	HeaderSignature = "X-GoNovel-Signature" // sha256=<hex(HMAC-SHA256(secret, body))>
+)
+
+const (
+	defaultRetries = 3
+	defaultTimeout = 10 * time.Second
+	queueSize      = 256
+)
+
+// Hook 是一个出站 webhook。
+type Hook struct {
+	Name           string   `yaml:"name" json:"name"`
+	URL            string   `yaml:"url" json:"url"`
+	Secret         string   `yaml:"secret" json:"-"`                       // 为空时不签名
+	Events         []string `yaml:"events" json:"events"`                  // 为空表示全部事件；支持 "download.*" 前缀匹配
+	Retries        int      `yaml:"retries" json:"retries"`                // 失败后的重试次数，默认 3（间隔 1s、2s、4s…）
+	TimeoutSeconds int      `yaml:"timeout_seconds" json:"timeoutSeconds"` // 单次请求超时，默认 10 秒
+}
+
+// Config 是 webhook 配置文件。
+type Config struct {
+	Webhooks []Hook `yaml:"webhooks"`
+}
+
+// Load 读取配置文件；文件不存在时返回空配置。
+func Load(path string) (Config, error) {
+	var cfg Config
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return cfg, err
+	}
+	for i, h := range cfg.Webhooks {
+		if h.URL == "" {
+			return cfg, fmt.Errorf("webhook #%d: missing url", i+1)
+		}
+		if h.Name == "" {
+			cfg.Webhooks[i].Name = h.URL
+		}
+		if h.Events == nil {
+			cfg.Webhooks[i].Events = []string{}
+		}
+		if h.Retries <= 0 {
+			cfg.Webhooks[i].Retries = defaultRetries
+		}
+		if h.TimeoutSeconds <= 0 {
+			cfg.Webhooks[i].TimeoutSeconds = int(defaultTimeout / time.Second)
+		}
+	}
+	return cfg, nil
+}
+
+// Matches 判断 hook 是否订阅了该事件；ping 总是发送。
+func (h Hook) Matches(event string) bool {
+	if len(h.Events) == 0 || event == EventPing {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == "*" || e == event || (strings.HasSuffix(e, ".*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload 是 webhook 请求体。
+type Payload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// Delivery 是一次投递的结果。
+type Delivery struct {
+	Hook     string    `json:"hook"`
+	ID       string    `json:"id"`
+	Event    string    `json:"event"`
+	Status   int       `json:"status,omitempty"` // 最后一次响应的状态码
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// OK 表示投递成功（2xx）。
+func (d Delivery) OK() bool { return d.Error == "" }
+
+// Sign 计算请求体签名。
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// Verify 校验 X-GoNovel-Signature。
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Notifier 异步投递事件：Emit 只入队不阻塞，每个 hook 有独立的队列与投递协程，
+// 某个接收端变慢或重试不会拖住其它 hook。
+type Notifier struct {
+	hooks   []Hook
+	client  *http.Client
+	queues  []chan Payload // 与 hooks 一一对应
+	backoff time.Duration  // 首次重试前的等待，之后每次翻倍
+
+	mu   sync.Mutex
+	last map[string]Delivery // 每个 hook 最近一次投递
+}
+
+// New 创建 Notifier 并启动投递协程。
+func New(hooks []Hook) *Notifier {
+	n := &Notifier{hooks: hooks, client: &http.Client{}, backoff: time.Second, last: make(map[string]Delivery)}
+	for _, h := range hooks {
+		q := make(chan Payload, queueSize)
+		n.queues = append(n.queues, q)
+		go n.worker(h, q)
+	}
+	return n
+}
+
+// Hooks 返回已配置的 webhook。
+func (n *Notifier) Hooks() []Hook { return n.hooks }
+
+// Last 返回 hook 最近一次投递结果。
+func (n *Notifier) Last(name string) (Delivery, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	d, ok := n.last[name]
+	return d, ok
+}
+
+// Emit 把事件投递给订阅了它的 hook；队列已满时丢弃并记录日志。
+func (n *Notifier) Emit(event string, data any) {
+	if n == nil {
+		return
+	}
+	p := newPayload(event, data)
+	for i, h := range n.hooks {
+		if !h.Matches(event) {
+			continue
+		}
+		select {
+		case n.queues[i] <- p:
+		default:
+			log.Printf("webhook %s: 队列已满，丢弃事件 %s", h.Name, event)
+		}
+	}
+}
+
+// Test 同步向 hook 发送 ping（name 为空时发送给全部），返回投递结果。
+func (n *Notifier) Test(ctx context.Context, name string) []Delivery {
+	p := newPayload(EventPing, map[string]string{"message": "go-novel webhook test"})
+	var out []Delivery
+	for _, h := range n.hooks {
+		if name != "" && h.Name != name {
+			continue
+		}
+		out = append(out, n.deliver(ctx, h, p))
+	}
+	return out
+}
+
+func (n *Notifier) worker(h Hook, q chan Payload) {
+	for p := range q {
+		d := n.deliver(context.Background(), h, p)
+		if !d.OK() {
+			log.Printf("webhook %s: 投递 %s 失败（%d 次）: %s", d.Hook, d.Event, d.Attempts, d.Error)
+		}
+	}
+}
+
+// deliver 发送一次事件，失败时按 1s、2s、4s… 退避重试；4xx（408/429 除外）不重试。
+func (n *Notifier) deliver(ctx context.Context, h Hook, p Payload) Delivery {
+	d := Delivery{Hook: h.Name, ID: p.ID, Event: p.Event}
+	body, err := json.Marshal(p)
+	if err != nil {
+		d.Error = err.Error()
+		return n.record(d)
+	}
+	retries := h.Retries
+	if retries <= 0 {
+		retries = defaultRetries
+	}
+	timeout := time.Duration(h.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	backoff := n.backoff
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				d.Error = ctx.Err().Error()
+				return n.record(d)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		d.Attempts++
+		status, err := n.post(ctx, h, p, body, timeout)
+		d.Status = status
+		if err == nil {
+			d.Error = ""
+			return n.record(d)
+		}
+		d.Error = err.Error()
+		if status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests {
+			break
+		}
+	}
+	return n.record(d)
+}
+
+func (n *Notifier) post(ctx context.Context, h Hook, p Payload, body []byte, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-novel-webhook")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(HeaderDelivery, p.ID)
+	if h.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(h.Secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("http %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (n *Notifier) record(d Delivery) Delivery {
+	d.At = time.Now()
+	n.mu.Lock()
+	n.last[d.Hook] = d
+	n.mu.Unlock()
+	return d
+}
+
+func newPayload(event string, data any) Payload {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return Payload{ID: hex.EncodeToString(b), Event: event, CreatedAt: time.Now(), Data: data}
+}
@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newNotifier 创建重试间隔缩短到毫秒级的 Notifier。
+func newNotifier(hooks ...Hook) *Notifier {
+	n := New(hooks)
+	n.backoff = 10 * time.Millisecond
+	return n
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		events []string
+		event  string
+		want   bool
+	}{
+		{nil, EventChaptersNew, true},
+		{[]string{}, EventDownloadFailed, true},
+		{[]string{"*"}, EventSourceUnhealthy, true},
+		{[]string{EventChaptersNew}, EventChaptersNew, true},
+		{[]string{EventChaptersNew}, EventDownloadFinished, false},
+		{[]string{"download.*"}, EventDownloadFinished, true},
+		{[]string{"download.*"}, EventDownloadFailed, true},
+		{[]string{"download.*"}, EventChaptersNew, false},
+		{[]string{"download"}, EventDownloadFinished, false},
+		{[]string{EventChaptersNew}, EventPing, true},
+	}
+	for _, tt := range tests {
+		if got := (Hook{Events: tt.events}).Matches(tt.event); got != tt.want {
+			t.Errorf("Hook{Events: %q}.Matches(%q) = %v, want %v", tt.events, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+	}{
+		{"signed", "s3cret"},
+		{"unsigned", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type received struct {
+				body              []byte
+				sig, event, dlvID string
+			}
+			got := make(chan received, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				got <- received{body, r.Header.Get(HeaderSignature), r.Header.Get(HeaderEvent), r.Header.Get(HeaderDelivery)}
+			}))
+			defer srv.Close()
+
+			n := newNotifier(Hook{Name: "h", URL: srv.URL, Secret: tt.secret})
+			ds := n.Test(context.Background(), "h")
+			if len(ds) != 1 || !ds[0].OK() {
+				t.Fatalf("Test() = %+v, want one successful delivery", ds)
+			}
+			r := <-got
+			var p Payload
+			if err := json.Unmarshal(r.body, &p); err != nil {
+				t.Fatal(err)
+			}
+			if r.event != EventPing || p.Event != EventPing || r.dlvID != p.ID || p.ID != ds[0].ID {
+				t.Errorf("headers event=%q delivery=%q, payload %+v, delivery %+v", r.event, r.dlvID, p, ds[0])
+			}
+			if tt.secret == "" {
+				if r.sig != "" {
+					t.Errorf("unsigned hook sent %s: %q", HeaderSignature, r.sig)
+				}
+				return
+			}
+			if !Verify(tt.secret, r.body, r.sig) {
+				t.Errorf("signature %q does not verify", r.sig)
+			}
+			if Verify("wrong", r.body, r.sig) || Verify(tt.secret, append(r.body, ' '), r.sig) {
+				t.Error("signature verifies with a wrong secret or a modified body")
+			}
+		})
+	}
+}
+
+func TestDeliverRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // 依次返回的状态码，用完后重复最后一个
+		retries      int
+		wantAttempts int
+		wantOK       bool
+		wantStatus   int
+	}{
+		{"ok", []int{200}, 3, 1, true, 200},
+		{"5xx then ok", []int{500, 503, 204}, 3, 3, true, 204},
+		{"5xx exhausts retries", []int{502}, 2, 3, false, 502},
+		{"404 not retried", []int{404}, 3, 1, false, 404},
+		{"401 not retried", []int{401}, 3, 1, false, 401},
+		{"429 retried", []int{429, 200}, 3, 2, true, 200},
+		{"408 retried", []int{408, 200}, 3, 2, true, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(hits.Add(1)) - 1
+				w.WriteHeader(tt.statuses[min(i, len(tt.statuses)-1)])
+			}))
+			defer srv.Close()
+
+			n := newNotifier(Hook{Name: "h", URL: srv.URL, Retries: tt.retries})
+			d := n.Test(context.Background(), "h")[0]
+			if d.Attempts != tt.wantAttempts || int(hits.Load()) != tt.wantAttempts {
+				t.Errorf("attempts = %d, receiver hits = %d, want %d", d.Attempts, hits.Load(), tt.wantAttempts)
+			}
+			if d.OK() != tt.wantOK || d.Status != tt.wantStatus {
+				t.Errorf("delivery = %+v, want ok %v status %d", d, tt.wantOK, tt.wantStatus)
+			}
+			if last, ok := n.Last("h"); !ok || last.ID != d.ID {
+				t.Errorf("Last() = %+v, %v", last, ok)
+			}
+		})
+	}
+}
+
+// 连接失败同样按退避间隔重试：10ms、20ms、40ms
+func TestDeliverRetryConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	n := newNotifier(Hook{Name: "h", URL: url, Retries: 3})
+	start := time.Now()
+	d := n.Test(context.Background(), "h")[0]
+	if d.OK() || d.Attempts != 4 || d.Status != 0 {
+		t.Fatalf("delivery = %+v, want 4 failed attempts without status", d)
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("retries took %v, want at least 70ms of backoff", elapsed)
+	}
+}
+
+// 只订阅了 chapters.new 的 hook 收不到下载事件；同一 hook 的事件按顺序投递
+func TestEmitFilter(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][]string)
+	done := make(chan struct{}, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		got[r.URL.Path] = append(got[r.URL.Path], r.Header.Get(HeaderEvent))
+		mu.Unlock()
+		done <- struct{}{}
+	}))
+	defer srv.Close()
+
+	n := newNotifier(
+		Hook{Name: "chapters", URL: srv.URL + "/chapters", Events: []string{EventChaptersNew}},
+		Hook{Name: "downloads", URL: srv.URL + "/downloads", Events: []string{"download.*"}},
+		Hook{Name: "all", URL: srv.URL + "/all"},
+	)
+	n.Emit(EventDownloadFinished, nil)
+	n.Emit(EventChaptersNew, nil)
+	for i := 0; i < 4; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for deliveries")
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string][]string{
+		"/chapters":  {EventChaptersNew},
+		"/downloads": {EventDownloadFinished},
+		"/all":       {EventDownloadFinished, EventChaptersNew},
+	}
+	for path, events := range want {
+		if len(got[path]) != len(events) {
+			t.Errorf("%s received %q, want %q", path, got[path], events)
+			continue
+		}
+		for i := range events {
+			if got[path][i] != events[i] {
+				t.Errorf("%s received %q, want %q", path, got[path], events)
+				break
+			}
+		}
+	}
+}
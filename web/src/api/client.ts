@@ -19,7 +19,33 @@ export async function apiChapter(url: string, opts?: { limit?: number; full?: bo
   return data
 }
 
-export async function apiDownload(url: string, format: 'txt'|'epub'|'pdf', title?: string, author?: string): Promise<Blob> {
+export async function apiDownload(url: string, format: string, title?: string, author?: string): Promise<Blob> {
   const { data } = await http.get(`/download`, { params: { url, format, title, author }, responseType: 'blob', timeout: 0 })
   return data
 }
+
+export interface Progress { kind: string; index: number; title: string; error?: string; totalChapters: number; completed: number; failed: number; activeThreads: number; concurrency: number; percentage: number }
+export interface Job { id: string; status: 'queued'|'running'|'done'|'failed'|'canceled'; phase?: string; error?: string; progress?: Progress; result?: { name: string; total: number } }
+
+export async function apiCreateJob(url: string, format: string, title?: string, author?: string): Promise<Job> {
+  const { data } = await http.post<Job>('/jobs', { url, format, title, author })
+  return data
+}
+
+export interface FormatOption { name: string; default?: string; usage: string; values?: string[] }
+export interface ExportFormat { name: string; extension: string; mimeType: string; options: FormatOption[] }
+
+export async function apiFormats(): Promise<ExportFormat[]> {
+  const { data } = await http.get<{ formats: ExportFormat[] }>('/formats')
+  return data.formats || []
+}
+
+export async function apiJobFile(id: string): Promise<Blob> {
+  const { data } = await http.get(`/jobs/${id}/file`, { responseType: 'blob', timeout: 0 })
+  return data
+}
+
+export async function apiCancelJob(id: string): Promise<Job> {
+  const { data } = await http.delete<Job>(`/jobs/${id}`)
+  return data
+}